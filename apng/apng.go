@@ -0,0 +1,165 @@
+// Package apng encodes animated PNG images.
+//
+// Frames are encoded by image/png and their image data is moved into the frame chunks.
+//
+// > ref. https://wiki.mozilla.org/APNG_Specification
+package apng
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/png"
+	"io"
+)
+
+// APNG is the frames of an animation as gif.GIF.
+type APNG struct {
+	// Image are the frames. They must have the same bounds and encode to the same header and palette,
+	// e.g. paletted images of the same palette.
+	Image []image.Image
+	// Delay are the delays of the frames in 1/DelayDen second.
+	Delay    []int
+	DelayDen int
+	// LoopCount is the number of plays. 0 loops forever.
+	LoopCount int
+}
+
+var signature = []byte("\x89PNG\r\n\x1a\n")
+
+// chunk is a chunk of a PNG stream.
+type chunk struct {
+	typ  string
+	data []byte
+}
+
+// chunks encodes img and splits the stream into chunks.
+func chunks(img image.Image) ([]chunk, error) {
+	var b bytes.Buffer
+	if e := png.Encode(&b, img); e != nil {
+		return nil, e
+	}
+	buf := b.Bytes()[len(signature):]
+	var cs []chunk
+	for len(buf) >= 12 {
+		n := binary.BigEndian.Uint32(buf)
+		cs = append(cs, chunk{typ: string(buf[4:8]), data: buf[8 : 8+n]})
+		buf = buf[12+n:]
+	}
+	return cs, nil
+}
+
+// EncodeAll writes the frames of a to w.
+func EncodeAll(w io.Writer, a *APNG) error {
+	if len(a.Image) == 0 {
+		return errors.New("apng: no frames")
+	}
+	if len(a.Image) != len(a.Delay) {
+		return errors.New("apng: mismatched image and delay lengths")
+	}
+	if a.DelayDen <= 0 || a.DelayDen > 0xffff {
+		return fmt.Errorf("apng: invalid delay denominator %d", a.DelayDen)
+	}
+	enc := &encoder{w: w}
+	enc.write(signature)
+	bounds := a.Image[0].Bounds()
+	var header []byte
+	// palettes are the PLTE and tRNS of the first frame, which the frames share.
+	palettes := map[string][]byte{}
+	for i, img := range a.Image {
+		if img.Bounds() != bounds {
+			return fmt.Errorf("apng: bounds of frame %d differ from the first", i)
+		}
+		cs, e := chunks(img)
+		if e != nil {
+			return e
+		}
+		for _, c := range cs {
+			switch c.typ {
+			case "IHDR":
+				if i == 0 {
+					header = c.data
+					enc.chunk("IHDR", c.data)
+					actl := make([]byte, 8)
+					binary.BigEndian.PutUint32(actl[0:], uint32(len(a.Image)))
+					binary.BigEndian.PutUint32(actl[4:], uint32(a.LoopCount))
+					enc.chunk("acTL", actl)
+				} else if !bytes.Equal(header, c.data) {
+					return fmt.Errorf("apng: header of frame %d differs from the first", i)
+				}
+				enc.fctl(bounds, a.Delay[i], a.DelayDen)
+			case "IDAT":
+				if i == 0 {
+					enc.chunk("IDAT", c.data)
+				} else {
+					enc.fdat(c.data)
+				}
+			case "PLTE", "tRNS":
+				if i == 0 {
+					palettes[c.typ] = c.data
+					enc.chunk(c.typ, c.data)
+				} else if !bytes.Equal(palettes[c.typ], c.data) {
+					return fmt.Errorf("apng: palette of frame %d differs from the first", i)
+				}
+			case "IEND":
+			default:
+				// ancillary chunks are taken from the first frame.
+				if i == 0 {
+					enc.chunk(c.typ, c.data)
+				}
+			}
+		}
+	}
+	enc.chunk("IEND", nil)
+	return enc.err
+}
+
+// encoder writes chunks keeping the sequence number of the frame chunks and the first error.
+type encoder struct {
+	w   io.Writer
+	seq uint32
+	err error
+}
+
+func (enc *encoder) write(b []byte) {
+	if enc.err != nil {
+		return
+	}
+	_, enc.err = enc.w.Write(b)
+}
+
+func (enc *encoder) chunk(typ string, data []byte) {
+	b := make([]byte, 12+len(data))
+	binary.BigEndian.PutUint32(b, uint32(len(data)))
+	copy(b[4:], typ)
+	copy(b[8:], data)
+	binary.BigEndian.PutUint32(b[8+len(data):], crc32.ChecksumIEEE(b[4:8+len(data)]))
+	enc.write(b)
+}
+
+// fctl writes the frame control chunk of a frame covering bounds. The frame replaces the previous one.
+func (enc *encoder) fctl(bounds image.Rectangle, delay, den int) {
+	if delay > 0xffff {
+		delay = 0xffff
+	}
+	b := make([]byte, 26)
+	binary.BigEndian.PutUint32(b[0:], enc.seq)
+	binary.BigEndian.PutUint32(b[4:], uint32(bounds.Dx()))
+	binary.BigEndian.PutUint32(b[8:], uint32(bounds.Dy()))
+	// x and y offsets are 0, dispose_op is APNG_DISPOSE_OP_NONE and blend_op is APNG_BLEND_OP_SOURCE.
+	binary.BigEndian.PutUint16(b[20:], uint16(delay))
+	binary.BigEndian.PutUint16(b[22:], uint16(den))
+	enc.seq++
+	enc.chunk("fcTL", b)
+}
+
+// fdat writes the image data of a frame after the first one.
+func (enc *encoder) fdat(data []byte) {
+	b := make([]byte, 4, 4+len(data))
+	binary.BigEndian.PutUint32(b, enc.seq)
+	enc.seq++
+	enc.chunk("fdAT", append(b, data...))
+}
@@ -0,0 +1,79 @@
+package apng
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/png"
+	"testing"
+)
+
+var palette = color.Palette{color.Black, color.White}
+
+func frame(p color.Palette, x int) *image.Paletted {
+	img := image.NewPaletted(image.Rect(0, 0, 4, 2), p)
+	img.SetColorIndex(x, 0, 1)
+	return img
+}
+
+// chunkTypes returns the types of the chunks of a PNG stream.
+func chunkTypes(t *testing.T, b []byte) []string {
+	t.Helper()
+	if !bytes.HasPrefix(b, signature) {
+		t.Fatal("no PNG signature")
+	}
+	b = b[len(signature):]
+	var types []string
+	for len(b) >= 12 {
+		n := binary.BigEndian.Uint32(b)
+		types = append(types, string(b[4:8]))
+		b = b[12+n:]
+	}
+	return types
+}
+
+func TestEncodeAll(t *testing.T) {
+	a := &APNG{
+		Image:    []image.Image{frame(palette, 0), frame(palette, 1), frame(palette, 2)},
+		Delay:    []int{1, 2, 3},
+		DelayDen: 60,
+	}
+	var b bytes.Buffer
+	if e := EncodeAll(&b, a); e != nil {
+		t.Fatal(e)
+	}
+	// decoders without APNG support show the first frame.
+	img, e := png.Decode(bytes.NewReader(b.Bytes()))
+	if e != nil {
+		t.Fatal(e)
+	}
+	if r, _, _, _ := img.At(0, 0).RGBA(); r == 0 {
+		t.Error("the default image isn't the first frame")
+	}
+	count := map[string]int{}
+	for _, typ := range chunkTypes(t, b.Bytes()) {
+		count[typ]++
+	}
+	for typ, want := range map[string]int{"IHDR": 1, "acTL": 1, "PLTE": 1, "fcTL": 3, "IDAT": 1, "fdAT": 2, "IEND": 1} {
+		if count[typ] != want {
+			t.Errorf("%d %s chunks; want %d", count[typ], typ, want)
+		}
+	}
+}
+
+func TestEncodeAllRejects(t *testing.T) {
+	other := color.Palette{color.Black, color.RGBA{0xff, 0, 0, 0xff}}
+	for _, tc := range []struct {
+		name  string
+		image []image.Image
+	}{
+		{"palettes", []image.Image{frame(palette, 0), frame(other, 0)}},
+		{"bounds", []image.Image{frame(palette, 0), image.NewPaletted(image.Rect(0, 0, 2, 2), palette)}},
+	} {
+		a := &APNG{Image: tc.image, Delay: []int{1, 1}, DelayDen: 60}
+		if e := EncodeAll(&bytes.Buffer{}, a); e == nil {
+			t.Errorf("frames of different %s are encoded; want an error", tc.name)
+		}
+	}
+}
@@ -0,0 +1,398 @@
+// Package asm assembles the mnemonics of Cowgod's Chip-8 Technical Reference into ROMs.
+//
+// > ref. http://devernay.free.fr/hacks/chip8/C8TECH10.HTM#3.1
+//
+// A line holds an optional label and a statement, and `;` starts a comment:
+//
+//	name EQU 0x10        ; constants (or `name = expr`)
+//	        ORG 0x200    ; the address of the following statements
+//	main:   LD I, sprite
+//	        DRW V0, V1, 5
+//	        JP main
+//	sprite: DB 0x20, 0x60, 0x20, 0x20, 0x70
+//	        DW 0x1234, "AB"
+//
+// Operands are expressions of numbers (decimal, 0x, 0b or 0o, 'c'), labels, constants and `$` for the address
+// of the statement with the operators | ^ & << >> + - * / % and unary - ~.
+// SUPER-CHIP and XO-CHIP instructions are written as by the disasm package, e.g. `SAVE V0 - V3` and `LD I, LONG addr`.
+package asm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/masu-mi/gochip-8/core"
+	"github.com/masu-mi/gochip-8/symbols"
+)
+
+// memorySize is the size of XO-CHIP's memory, the largest one.
+const memorySize = 0x10000
+
+// Error is an error of a line of the source. Line and Col are 1-based.
+type Error struct {
+	File      string
+	Line, Col int
+	Msg       string
+	// Text is the source line.
+	Text string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Col, e.Msg)
+}
+
+// Excerpt returns the source line with a caret under the column.
+func (e *Error) Excerpt() string {
+	pad := []byte(e.Text)
+	if e.Col-1 < len(pad) {
+		pad = pad[:e.Col-1]
+	}
+	// tabs are kept to align the caret in terminals.
+	for i, c := range pad {
+		if c != '\t' {
+			pad[i] = ' '
+		}
+	}
+	return e.Text + "\n" + string(pad) + "^"
+}
+
+// errorf makes an error at col of the current line. The line is filled by the assembler.
+func errorf(col int, format string, args ...interface{}) *Error {
+	return &Error{Col: col, Msg: fmt.Sprintf(format, args...)}
+}
+
+// ErrorList is the errors of a source in the order of lines.
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	msgs := make([]string, len(l))
+	for i, e := range l {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// Program is an assembled ROM.
+type Program struct {
+	// Base is the address where the ROM is loaded.
+	Base uint16
+	ROM  []byte
+	// Symbols has the labels and the source lines of the ROM.
+	Symbols *symbols.Table
+}
+
+// Assemble assembles src of file into a ROM loaded at core.StartOfProgram.
+// The error is an ErrorList when the source has errors.
+func Assemble(file string, src []byte) (*Program, error) {
+	a := &assembler{
+		labels:     map[string]int{},
+		labelLines: map[string]int{},
+		constants:  map[string]*constant{},
+	}
+	lines := strings.Split(string(src), "\n")
+	here := core.StartOfProgram
+	a.placing = true
+	for i, l := range lines {
+		a.line = i + 1
+		s, e := a.parseLine(l, here)
+		if e != nil {
+			a.fail(e)
+			continue
+		}
+		if s == nil {
+			continue
+		}
+		if s.org {
+			here = s.addr
+			continue
+		}
+		a.statements = append(a.statements, s)
+		here += s.size
+	}
+	a.placing = false
+
+	image := make([]byte, memorySize)
+	owner := make([]int, memorySize)
+	end := core.StartOfProgram
+	table := symbols.New()
+	for _, s := range a.statements {
+		a.line = s.line
+		if s.addr+s.size > memorySize {
+			a.fail(errorf(1, "0x%X is out of the memory", s.addr+s.size-1))
+			continue
+		}
+		data, e := s.emit(a, s.addr)
+		if e != nil {
+			a.fail(e)
+			continue
+		}
+		for i, b := range data {
+			if l := owner[s.addr+i]; l != 0 {
+				a.fail(errorf(1, "0x%03X overlaps line %d", s.addr+i, l))
+				break
+			}
+			owner[s.addr+i] = s.line
+			image[s.addr+i] = b
+		}
+		if len(data) > 0 {
+			table.AddLine(uint16(s.addr), file, s.line)
+		}
+		if s.addr+s.size > end {
+			end = s.addr + s.size
+		}
+	}
+	for name := range a.constants {
+		// unused constants are checked too.
+		if _, e := a.value(name, 1); e != nil {
+			a.fail(e)
+		}
+	}
+	if len(a.errs) > 0 {
+		for _, e := range a.errs {
+			e.File = file
+			if e.Line-1 < len(lines) {
+				e.Text = strings.TrimRight(lines[e.Line-1], "\r")
+			}
+		}
+		sort.SliceStable(a.errs, func(i, j int) bool { return a.errs[i].Line < a.errs[j].Line })
+		return nil, a.errs
+	}
+	for name, addr := range a.labels {
+		table.Labels[name] = uint16(addr)
+	}
+	return &Program{
+		Base:    core.StartOfProgram,
+		ROM:     image[core.StartOfProgram:end],
+		Symbols: table,
+	}, nil
+}
+
+type assembler struct {
+	line   int
+	labels map[string]int
+	// labelLines are the lines defining the labels.
+	labelLines map[string]int
+	constants  map[string]*constant
+	statements []*statement
+	errs       ErrorList
+	// placing is true while the labels are placed in the first pass.
+	placing bool
+}
+
+// constant is defined by EQU and evaluated on its first use.
+type constant struct {
+	line       int
+	here       int
+	x          expr
+	evaluating bool
+	done       bool
+	v          int
+	err        *Error
+}
+
+// statement emits size bytes at addr.
+type statement struct {
+	line int
+	addr int
+	size int
+	// org is true for ORG which moves the address to addr.
+	org  bool
+	emit func(a *assembler, here int) ([]byte, *Error)
+}
+
+// fail records e on the current line unless e has its line.
+// Errors of constants are recorded once even if they are used many times.
+func (a *assembler) fail(e *Error) {
+	if e.Line == 0 {
+		e.Line = a.line
+	}
+	for _, f := range a.errs {
+		if f == e {
+			return
+		}
+	}
+	a.errs = append(a.errs, e)
+}
+
+// value returns the value of the label or the constant.
+func (a *assembler) value(name string, col int) (int, *Error) {
+	if v, ok := a.labels[name]; ok {
+		return v, nil
+	}
+	c, ok := a.constants[name]
+	if !ok {
+		if a.placing {
+			return 0, errorf(col, "`%s` must be defined before use here", name)
+		}
+		return 0, errorf(col, "undefined `%s`", name)
+	}
+	if c.done {
+		return c.v, c.err
+	}
+	if c.evaluating {
+		return 0, errorf(col, "`%s` refers to itself", name)
+	}
+	c.evaluating = true
+	v, e := c.x(a, c.here)
+	c.evaluating = false
+	if e != nil && e.Line == 0 {
+		e.Line = c.line
+	}
+	// values in the first pass may change when the labels are placed.
+	if !a.placing {
+		c.done, c.v, c.err = true, v, e
+	}
+	return v, e
+}
+
+// define defines a label or a constant.
+func (a *assembler) define(t token) *Error {
+	if isReserved(t.text) {
+		return errorf(t.col, "`%s` is reserved", t.text)
+	}
+	if l, ok := a.labelLines[t.text]; ok {
+		return errorf(t.col, "`%s` is already defined on line %d", t.text, l)
+	}
+	if c, ok := a.constants[t.text]; ok {
+		return errorf(t.col, "`%s` is already defined on line %d", t.text, c.line)
+	}
+	return nil
+}
+
+// parseLine parses a line at here. The statement is nil for lines without code or data.
+func (a *assembler) parseLine(line string, here int) (*statement, *Error) {
+	toks, e := tokenize(line)
+	if e != nil {
+		return nil, e
+	}
+	if len(toks) >= 2 && toks[0].kind == tokIdent && toks[1].is(":") {
+		if e := a.define(toks[0]); e != nil {
+			return nil, e
+		}
+		a.labels[toks[0].text] = here
+		a.labelLines[toks[0].text] = a.line
+		toks = toks[2:]
+	}
+	if len(toks) == 0 {
+		return nil, nil
+	}
+	head := toks[0]
+	if head.kind != tokIdent {
+		return nil, errorf(head.col, "expected an instruction but got `%s`", head.text)
+	}
+	p := &parser{toks: toks, pos: 1, end: len(strings.TrimRight(line, " \t\r")) + 1}
+	if len(toks) >= 2 && (toks[1].is("=") || toks[1].kind == tokIdent && strings.EqualFold(toks[1].text, "EQU")) {
+		if e := a.define(head); e != nil {
+			return nil, e
+		}
+		p.pos = 2
+		x, e := a.operand(p)
+		if e != nil {
+			return nil, e
+		}
+		a.constants[head.text] = &constant{line: a.line, here: here, x: x}
+		return nil, nil
+	}
+	s := &statement{line: a.line, addr: here}
+	switch strings.ToUpper(head.text) {
+	case "ORG":
+		x, e := a.operand(p)
+		if e != nil {
+			return nil, e
+		}
+		v, e := x(a, here)
+		if e != nil {
+			return nil, e
+		}
+		if v < core.StartOfProgram || v >= memorySize {
+			return nil, errorf(toks[1].col, "ORG 0x%X is out of 0x%03X-0x%X", v, core.StartOfProgram, memorySize-1)
+		}
+		s.org, s.addr = true, v
+		return s, nil
+	case "DB":
+		return a.data(s, p, 1)
+	case "DW":
+		return a.data(s, p, 2)
+	}
+	return a.instruction(s, head, p)
+}
+
+// operand parses an expression which ends the line.
+func (a *assembler) operand(p *parser) (expr, *Error) {
+	x, e := p.expr(0)
+	if e != nil {
+		return nil, e
+	}
+	if t, ok := p.peek(); ok {
+		return nil, errorf(t.col, "unexpected `%s`", t.text)
+	}
+	return x, nil
+}
+
+// data parses the values of DB or DW. Strings are their bytes in both.
+func (a *assembler) data(s *statement, p *parser, width int) (*statement, *Error) {
+	var items []func(a *assembler, here int) ([]byte, *Error)
+	for {
+		t, e := p.next()
+		if e != nil {
+			return nil, e
+		}
+		if t.kind == tokString {
+			b := []byte(t.text)
+			items = append(items, func(*assembler, int) ([]byte, *Error) { return b, nil })
+			s.size += len(b)
+		} else {
+			p.pos--
+			x, e := p.expr(0)
+			if e != nil {
+				return nil, e
+			}
+			col := t.col
+			items = append(items, func(a *assembler, here int) ([]byte, *Error) {
+				v, e := x(a, here)
+				if e != nil {
+					return nil, e
+				}
+				if width == 1 {
+					if e := inRange(v, col, -0x80, 0xff, "a byte"); e != nil {
+						return nil, e
+					}
+					return []byte{byte(v)}, nil
+				}
+				if e := inRange(v, col, -0x8000, 0xffff, "a word"); e != nil {
+					return nil, e
+				}
+				return []byte{byte(v >> 8), byte(v)}, nil
+			})
+			s.size += width
+		}
+		t, ok := p.peek()
+		if !ok {
+			break
+		}
+		if !t.is(",") {
+			return nil, errorf(t.col, "expected `,` but got `%s`", t.text)
+		}
+		p.pos++
+	}
+	s.emit = func(a *assembler, here int) ([]byte, *Error) {
+		var out []byte
+		for _, item := range items {
+			b, e := item(a, here)
+			if e != nil {
+				return nil, e
+			}
+			out = append(out, b...)
+		}
+		return out, nil
+	}
+	return s, nil
+}
+
+func inRange(v, col, min, max int, what string) *Error {
+	if v < min || v > max {
+		return errorf(col, "%d (0x%X) doesn't fit in %s", v, v, what)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package asm
+
+import (
+	"strconv"
+)
+
+// expr is an expression evaluated after the labels are placed. here is the address of the statement for `$`.
+type expr func(a *assembler, here int) (int, *Error)
+
+// binaries are the binary operators by precedence, from the loosest.
+var binaries = []map[string]func(a, b int) int{
+	{"|": func(a, b int) int { return a | b }},
+	{"^": func(a, b int) int { return a ^ b }},
+	{"&": func(a, b int) int { return a & b }},
+	{
+		"<<": func(a, b int) int { return a << (uint(b) & 63) },
+		">>": func(a, b int) int { return a >> (uint(b) & 63) },
+	},
+	{
+		"+": func(a, b int) int { return a + b },
+		"-": func(a, b int) int { return a - b },
+	},
+	{
+		"*": func(a, b int) int { return a * b },
+		"/": func(a, b int) int { return a / b },
+		"%": func(a, b int) int { return a % b },
+	},
+}
+
+// parser reads expressions from the tokens of a line.
+type parser struct {
+	toks []token
+	pos  int
+	// end is the column after the line for errors at its end.
+	end int
+}
+
+func (p *parser) peek() (token, bool) {
+	if p.pos < len(p.toks) {
+		return p.toks[p.pos], true
+	}
+	return token{}, false
+}
+
+func (p *parser) next() (token, *Error) {
+	t, ok := p.peek()
+	if !ok {
+		return t, errorf(p.end, "unexpected end of line")
+	}
+	p.pos++
+	return t, nil
+}
+
+// expr parses binary operators of the level and the tighter ones.
+func (p *parser) expr(level int) (expr, *Error) {
+	if level == len(binaries) {
+		return p.unary()
+	}
+	lhs, e := p.expr(level + 1)
+	if e != nil {
+		return nil, e
+	}
+	for {
+		t, ok := p.peek()
+		if !ok || t.kind != tokPunct {
+			return lhs, nil
+		}
+		op, ok := binaries[level][t.text]
+		if !ok {
+			return lhs, nil
+		}
+		p.pos++
+		rhs, e := p.expr(level + 1)
+		if e != nil {
+			return nil, e
+		}
+		l, col := lhs, t.col
+		divides := t.text == "/" || t.text == "%"
+		lhs = func(a *assembler, here int) (int, *Error) {
+			x, e := l(a, here)
+			if e != nil {
+				return 0, e
+			}
+			y, e := rhs(a, here)
+			if e != nil {
+				return 0, e
+			}
+			if divides && y == 0 {
+				return 0, errorf(col, "division by zero")
+			}
+			return op(x, y), nil
+		}
+	}
+}
+
+func (p *parser) unary() (expr, *Error) {
+	if t, ok := p.peek(); ok && (t.is("-") || t.is("~") || t.is("+")) {
+		p.pos++
+		x, e := p.unary()
+		if e != nil {
+			return nil, e
+		}
+		switch t.text {
+		case "-":
+			return func(a *assembler, here int) (int, *Error) {
+				v, e := x(a, here)
+				return -v, e
+			}, nil
+		case "~":
+			return func(a *assembler, here int) (int, *Error) {
+				v, e := x(a, here)
+				return ^v, e
+			}, nil
+		}
+		return x, nil
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (expr, *Error) {
+	t, e := p.next()
+	if e != nil {
+		return nil, e
+	}
+	switch {
+	case t.is("("):
+		x, e := p.expr(0)
+		if e != nil {
+			return nil, e
+		}
+		c, e := p.next()
+		if e != nil || !c.is(")") {
+			return nil, errorf(t.col, "missing `)`")
+		}
+		return x, nil
+	case t.is("$"):
+		return func(_ *assembler, here int) (int, *Error) { return here, nil }, nil
+	case t.kind == tokNumber:
+		v, e := strconv.ParseInt(t.text, 0, 64)
+		if e != nil {
+			return nil, errorf(t.col, "invalid number `%s`", t.text)
+		}
+		return func(*assembler, int) (int, *Error) { return int(v), nil }, nil
+	case t.kind == tokIdent:
+		if isReserved(t.text) {
+			return nil, errorf(t.col, "register `%s` can't be used in expressions", t.text)
+		}
+		return func(a *assembler, _ int) (int, *Error) { return a.value(t.text, t.col) }, nil
+	case t.kind == tokString:
+		return nil, errorf(t.col, "strings are allowed only in DB, not in expressions")
+	}
+	return nil, errorf(t.col, "unexpected `%s`", t.text)
+}
@@ -0,0 +1,328 @@
+package asm
+
+import (
+	"strings"
+)
+
+// form is a form of an instruction.
+//
+// shape is the kinds of the operands separated by `,`. `V` is Vx and then Vy, `V0` is V0, `VV` is Vx written also
+// to Vy, `V-V` is a range of Vx to Vy and the names of the other registers stand for themselves.
+// Expressions are `a` for nnn, `b` for kk, `n` for the nibble at n, `x` for the nibble at x and `L` for LONG nnnn.
+type form struct {
+	shape string
+	op    uint16
+}
+
+// forms are the forms of each mnemonic.
+// > ref. http://devernay.free.fr/hacks/chip8/C8TECH10.HTM#3.1
+var forms = map[string][]form{
+	"CLS":  {{"", 0x00e0}},
+	"RET":  {{"", 0x00ee}},
+	"SYS":  {{"a", 0x0000}},
+	"JP":   {{"a", 0x1000}, {"V0,a", 0xb000}},
+	"CALL": {{"a", 0x2000}},
+	"SE":   {{"V,b", 0x3000}, {"V,V", 0x5000}},
+	"SNE":  {{"V,b", 0x4000}, {"V,V", 0x9000}},
+	"LD": {
+		{"V,b", 0x6000}, {"V,V", 0x8000}, {"I,a", 0xa000}, {"I,L", 0xf000},
+		{"V,DT", 0xf007}, {"V,K", 0xf00a}, {"DT,V", 0xf015}, {"ST,V", 0xf018},
+		{"F,V", 0xf029}, {"HF,V", 0xf030}, {"B,V", 0xf033},
+		{"[I],V", 0xf055}, {"V,[I]", 0xf065}, {"R,V", 0xf075}, {"V,R", 0xf085},
+	},
+	"ADD":  {{"V,b", 0x7000}, {"V,V", 0x8004}, {"I,V", 0xf01e}},
+	"OR":   {{"V,V", 0x8001}},
+	"AND":  {{"V,V", 0x8002}},
+	"XOR":  {{"V,V", 0x8003}},
+	"SUB":  {{"V,V", 0x8005}},
+	"SHR":  {{"VV", 0x8006}, {"V,V", 0x8006}},
+	"SUBN": {{"V,V", 0x8007}},
+	"SHL":  {{"VV", 0x800e}, {"V,V", 0x800e}},
+	"RND":  {{"V,b", 0xc000}},
+	"DRW":  {{"V,V,n", 0xd000}},
+	"SKP":  {{"V", 0xe09e}},
+	"SKNP": {{"V", 0xe0a1}},
+
+	// SUPER-CHIP
+	"SCD":  {{"n", 0x00c0}},
+	"SCR":  {{"", 0x00fb}},
+	"SCL":  {{"", 0x00fc}},
+	"EXIT": {{"", 0x00fd}},
+	"LOW":  {{"", 0x00fe}},
+	"HIGH": {{"", 0x00ff}},
+
+	// XO-CHIP
+	"SCU":   {{"n", 0x00d0}},
+	"SAVE":  {{"V-V", 0x5002}},
+	"LOAD":  {{"V-V", 0x5003}},
+	"PLANE": {{"x", 0xf001}},
+	"AUDIO": {{"", 0xf002}},
+	"PITCH": {{"V", 0xf03a}},
+}
+
+// registers are the names of the registers other than Vx in the operands.
+var registers = []string{"I", "DT", "ST", "K", "F", "HF", "B", "R", "LONG"}
+
+// isReserved tells whether name is a register, which can't be a label.
+func isReserved(name string) bool {
+	if _, ok := register(name); ok {
+		return true
+	}
+	for _, r := range registers {
+		if strings.EqualFold(r, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// register returns the number of Vx.
+func register(name string) (uint8, bool) {
+	if len(name) != 2 || name[0] != 'V' && name[0] != 'v' {
+		return 0, false
+	}
+	i := strings.IndexByte("0123456789ABCDEF", strings.ToUpper(name)[1])
+	if i < 0 {
+		return 0, false
+	}
+	return uint8(i), true
+}
+
+// operand is a parsed operand. kind is `V`, `V-V`, `L`, `e` for expressions or the name of a register.
+type operand struct {
+	kind string
+	x, y uint8
+	expr expr
+	col  int
+}
+
+// operands parses the operands separated by commas.
+func (a *assembler) operands(p *parser) ([]operand, *Error) {
+	var ops []operand
+	if _, ok := p.peek(); !ok {
+		return nil, nil
+	}
+	for {
+		// the group of tokens up to the next comma.
+		start, depth := p.pos, 0
+		for ; p.pos < len(p.toks); p.pos++ {
+			t := p.toks[p.pos]
+			if t.is("(") {
+				depth++
+			} else if t.is(")") {
+				depth--
+			} else if t.is(",") && depth == 0 {
+				break
+			}
+		}
+		group := p.toks[start:p.pos]
+		end := p.end
+		if p.pos < len(p.toks) {
+			end = p.toks[p.pos].col
+		}
+		op, e := parseOperand(group, end)
+		if e != nil {
+			return nil, e
+		}
+		ops = append(ops, op)
+		if p.pos == len(p.toks) {
+			return ops, nil
+		}
+		p.pos++
+	}
+}
+
+func parseOperand(toks []token, end int) (operand, *Error) {
+	if len(toks) == 0 {
+		return operand{}, errorf(end, "missing operand")
+	}
+	head := toks[0]
+	op := operand{col: head.col}
+	if head.kind == tokIdent {
+		if x, ok := register(head.text); ok {
+			switch {
+			case len(toks) == 1:
+				op.kind, op.x = "V", x
+				return op, nil
+			case len(toks) == 3 && toks[1].is("-"):
+				if y, ok := register(toks[2].text); ok && toks[2].kind == tokIdent {
+					op.kind, op.x, op.y = "V-V", x, y
+					return op, nil
+				}
+			}
+		}
+		name := strings.ToUpper(head.text)
+		if name == "LONG" {
+			p := &parser{toks: toks, pos: 1, end: end}
+			x, e := p.expr(0)
+			if e != nil {
+				return op, e
+			}
+			if t, ok := p.peek(); ok {
+				return op, errorf(t.col, "unexpected `%s`", t.text)
+			}
+			op.kind, op.expr = "L", x
+			return op, nil
+		}
+		if len(toks) == 1 && isReserved(name) {
+			op.kind = name
+			return op, nil
+		}
+	}
+	if len(toks) == 3 && head.is("[") && toks[2].is("]") && strings.EqualFold(toks[1].text, "I") {
+		op.kind = "[I]"
+		return op, nil
+	}
+	p := &parser{toks: toks, end: end}
+	x, e := p.expr(0)
+	if e != nil {
+		return op, e
+	}
+	if t, ok := p.peek(); ok {
+		return op, errorf(t.col, "unexpected `%s`", t.text)
+	}
+	op.kind, op.expr = "e", x
+	return op, nil
+}
+
+// matches tells whether the operands have the shape.
+func (f form) matches(ops []operand) bool {
+	parts := f.parts()
+	if len(parts) != len(ops) {
+		return false
+	}
+	for i, part := range parts {
+		kind := ops[i].kind
+		switch part {
+		case "a", "b", "n", "x":
+			if kind != "e" {
+				return false
+			}
+		case "V0", "VV":
+			if kind != "V" {
+				return false
+			}
+		default:
+			if kind != part {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func (f form) parts() []string {
+	if f.shape == "" {
+		return nil
+	}
+	return strings.Split(f.shape, ",")
+}
+
+// usage is the form as written in the source.
+func (f form) usage(name string) string {
+	words := map[string]string{"a": "addr", "b": "byte", "n": "n", "x": "n", "L": "LONG addr", "VV": "Vx", "V-V": "Vx - Vy"}
+	parts := f.parts()
+	vs := []string{"Vx", "Vy"}
+	for i, p := range parts {
+		if w, ok := words[p]; ok {
+			parts[i] = w
+		} else if p == "V" {
+			parts[i], vs = vs[0], vs[1:]
+		}
+	}
+	if len(parts) == 0 {
+		return name
+	}
+	return name + " " + strings.Join(parts, ", ")
+}
+
+// instruction parses an instruction and finds its form. The operands are evaluated in the second pass.
+func (a *assembler) instruction(s *statement, head token, p *parser) (*statement, *Error) {
+	name := strings.ToUpper(head.text)
+	candidates, ok := forms[name]
+	if !ok {
+		return nil, errorf(head.col, "unknown instruction `%s`", head.text)
+	}
+	ops, e := a.operands(p)
+	if e != nil {
+		return nil, e
+	}
+	var f *form
+	for i := range candidates {
+		if candidates[i].matches(ops) {
+			f = &candidates[i]
+			break
+		}
+	}
+	if f == nil {
+		usages := make([]string, len(candidates))
+		for i, c := range candidates {
+			usages[i] = c.usage(name)
+		}
+		col := head.col
+		if len(ops) > 0 {
+			col = ops[0].col
+		}
+		return nil, errorf(col, "invalid operands of %s, expected %s", name, strings.Join(usages, " or "))
+	}
+	s.size = 2
+	if strings.Contains(f.shape, "L") {
+		s.size = 4
+	}
+	parts := f.parts()
+	s.emit = func(a *assembler, here int) ([]byte, *Error) {
+		op := f.op
+		var long uint16
+		vs := 0
+		for i, part := range parts {
+			o := ops[i]
+			switch part {
+			case "V":
+				if vs == 0 {
+					op |= uint16(o.x) << 8
+				} else {
+					op |= uint16(o.x) << 4
+				}
+				vs++
+			case "V0":
+				if o.x != 0 {
+					return nil, errorf(o.col, "%s takes V0", name)
+				}
+			case "VV":
+				op |= uint16(o.x)<<8 | uint16(o.x)<<4
+			case "V-V":
+				op |= uint16(o.x)<<8 | uint16(o.y)<<4
+			case "a", "b", "n", "x", "L":
+				v, e := o.expr(a, here)
+				if e != nil {
+					return nil, e
+				}
+				switch part {
+				case "a":
+					e = inRange(v, o.col, 0, 0xfff, "an address (nnn)")
+					op |= uint16(v) & 0xfff
+				case "b":
+					e = inRange(v, o.col, -0x80, 0xff, "a byte")
+					op |= uint16(v) & 0xff
+				case "n":
+					e = inRange(v, o.col, 0, 0xf, "a nibble")
+					op |= uint16(v) & 0xf
+				case "x":
+					e = inRange(v, o.col, 0, 0xf, "a nibble")
+					op |= (uint16(v) & 0xf) << 8
+				case "L":
+					e = inRange(v, o.col, 0, 0xffff, "an address")
+					long = uint16(v)
+				}
+				if e != nil {
+					return nil, e
+				}
+			}
+		}
+		if s.size == 4 {
+			return []byte{byte(op >> 8), byte(op), byte(long >> 8), byte(long)}, nil
+		}
+		return []byte{byte(op >> 8), byte(op)}, nil
+	}
+	return s, nil
+}
@@ -0,0 +1,111 @@
+package asm
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+// formCases are a line of each form of forms by the mnemonic and the shape.
+var formCases = map[string]struct {
+	src  string
+	want []byte
+}{
+	"CLS ":      {"CLS", []byte{0x00, 0xe0}},
+	"RET ":      {"RET", []byte{0x00, 0xee}},
+	"SYS a":     {"SYS 0x123", []byte{0x01, 0x23}},
+	"JP a":      {"JP 0x345", []byte{0x13, 0x45}},
+	"JP V0,a":   {"JP V0, 0x345", []byte{0xb3, 0x45}},
+	"CALL a":    {"CALL 0x345", []byte{0x23, 0x45}},
+	"SE V,b":    {"SE V1, 0x22", []byte{0x31, 0x22}},
+	"SE V,V":    {"SE V1, V2", []byte{0x51, 0x20}},
+	"SNE V,b":   {"SNE V1, 0x22", []byte{0x41, 0x22}},
+	"SNE V,V":   {"SNE V1, V2", []byte{0x91, 0x20}},
+	"LD V,b":    {"LD V1, 0x22", []byte{0x61, 0x22}},
+	"LD V,V":    {"LD V1, V2", []byte{0x81, 0x20}},
+	"LD I,a":    {"LD I, 0x345", []byte{0xa3, 0x45}},
+	"LD I,L":    {"LD I, LONG 0x1234", []byte{0xf0, 0x00, 0x12, 0x34}},
+	"LD V,DT":   {"LD V1, DT", []byte{0xf1, 0x07}},
+	"LD V,K":    {"LD V1, K", []byte{0xf1, 0x0a}},
+	"LD DT,V":   {"LD DT, V1", []byte{0xf1, 0x15}},
+	"LD ST,V":   {"LD ST, V1", []byte{0xf1, 0x18}},
+	"LD F,V":    {"LD F, V1", []byte{0xf1, 0x29}},
+	"LD HF,V":   {"LD HF, V1", []byte{0xf1, 0x30}},
+	"LD B,V":    {"LD B, V1", []byte{0xf1, 0x33}},
+	"LD [I],V":  {"LD [I], V1", []byte{0xf1, 0x55}},
+	"LD V,[I]":  {"LD V1, [I]", []byte{0xf1, 0x65}},
+	"LD R,V":    {"LD R, V1", []byte{0xf1, 0x75}},
+	"LD V,R":    {"LD V1, R", []byte{0xf1, 0x85}},
+	"ADD V,b":   {"ADD V1, 0x22", []byte{0x71, 0x22}},
+	"ADD V,V":   {"ADD V1, V2", []byte{0x81, 0x24}},
+	"ADD I,V":   {"ADD I, V1", []byte{0xf1, 0x1e}},
+	"OR V,V":    {"OR V1, V2", []byte{0x81, 0x21}},
+	"AND V,V":   {"AND V1, V2", []byte{0x81, 0x22}},
+	"XOR V,V":   {"XOR V1, V2", []byte{0x81, 0x23}},
+	"SUB V,V":   {"SUB V1, V2", []byte{0x81, 0x25}},
+	"SHR VV":    {"SHR V1", []byte{0x81, 0x16}},
+	"SHR V,V":   {"SHR V1, V2", []byte{0x81, 0x26}},
+	"SUBN V,V":  {"SUBN V1, V2", []byte{0x81, 0x27}},
+	"SHL VV":    {"SHL V1", []byte{0x81, 0x1e}},
+	"SHL V,V":   {"SHL V1, V2", []byte{0x81, 0x2e}},
+	"RND V,b":   {"RND V1, 0x22", []byte{0xc1, 0x22}},
+	"DRW V,V,n": {"DRW V1, V2, 5", []byte{0xd1, 0x25}},
+	"SKP V":     {"SKP V1", []byte{0xe1, 0x9e}},
+	"SKNP V":    {"SKNP V1", []byte{0xe1, 0xa1}},
+	"SCD n":     {"SCD 4", []byte{0x00, 0xc4}},
+	"SCR ":      {"SCR", []byte{0x00, 0xfb}},
+	"SCL ":      {"SCL", []byte{0x00, 0xfc}},
+	"EXIT ":     {"EXIT", []byte{0x00, 0xfd}},
+	"LOW ":      {"LOW", []byte{0x00, 0xfe}},
+	"HIGH ":     {"HIGH", []byte{0x00, 0xff}},
+	"SCU n":     {"SCU 4", []byte{0x00, 0xd4}},
+	"SAVE V-V":  {"SAVE V1 - V3", []byte{0x51, 0x32}},
+	"LOAD V-V":  {"LOAD V1 - V3", []byte{0x51, 0x33}},
+	"PLANE x":   {"PLANE 3", []byte{0xf3, 0x01}},
+	"AUDIO ":    {"AUDIO", []byte{0xf0, 0x02}},
+	"PITCH V":   {"PITCH V1", []byte{0xf1, 0x3a}},
+}
+
+func TestForms(t *testing.T) {
+	n := 0
+	for name, fs := range forms {
+		for _, f := range fs {
+			n++
+			key := fmt.Sprintf("%s %s", name, f.shape)
+			tc, ok := formCases[key]
+			if !ok {
+				t.Errorf("no case of `%s`", key)
+				continue
+			}
+			p, e := Assemble("test.asm", []byte("\t"+tc.src+"\n"))
+			if e != nil {
+				t.Errorf("%s: %v", tc.src, e)
+				continue
+			}
+			if !bytes.Equal(p.ROM, tc.want) {
+				t.Errorf("%s = % x; want % x", tc.src, p.ROM, tc.want)
+			}
+		}
+	}
+	if n != len(formCases) {
+		t.Errorf("%d cases for %d forms", len(formCases), n)
+	}
+}
+
+func TestFormErrors(t *testing.T) {
+	for _, src := range []string{
+		"JP V1, 0x345",
+		"LD V1, 0x100",
+		"DRW V1, V2, 16",
+		"SE V1",
+		"LD I, V1, V2",
+		"PLANE 16",
+		"FOO V1",
+		"SAVE V1 - VG",
+		`LD V1, "a"`,
+	} {
+		if _, e := Assemble("test.asm", []byte("\t"+src+"\n")); e == nil {
+			t.Errorf("%s assembled; want an error", src)
+		}
+	}
+}
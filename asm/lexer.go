@@ -0,0 +1,112 @@
+package asm
+
+import (
+	"strconv"
+	"strings"
+)
+
+type tokenKind int
+
+const (
+	tokIdent tokenKind = iota
+	tokNumber
+	tokString
+	tokPunct
+)
+
+// token is a token of a line. Col is the 1-based column of its first byte.
+type token struct {
+	kind tokenKind
+	text string
+	col  int
+}
+
+func (t token) is(text string) bool {
+	return t.kind == tokPunct && t.text == text
+}
+
+// puncts are listed longest first to be tokenized greedily.
+var puncts = []string{
+	"<<", ">>",
+	",", ":", "=", "(", ")", "[", "]", "|", "^", "&", "+", "-", "*", "/", "%", "~", "$",
+}
+
+// tokenize splits a line into tokens. Comments start with `;`.
+// Strings and characters are unquoted into their text.
+func tokenize(line string) ([]token, *Error) {
+	var toks []token
+	for i := 0; i < len(line); {
+		c := line[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == ';':
+			return toks, nil
+		case isIdentStart(c):
+			j := i
+			for j < len(line) && isIdent(line[j]) {
+				j++
+			}
+			toks = append(toks, token{kind: tokIdent, text: line[i:j], col: i + 1})
+			i = j
+		case '0' <= c && c <= '9':
+			j := i
+			for j < len(line) && isIdent(line[j]) {
+				j++
+			}
+			toks = append(toks, token{kind: tokNumber, text: line[i:j], col: i + 1})
+			i = j
+		case c == '"' || c == '\'':
+			j := i + 1
+			for j < len(line) && line[j] != c {
+				if line[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(line) {
+				return nil, errorf(i+1, "unterminated %s", map[byte]string{'"': "string", '\'': "character"}[c])
+			}
+			body := line[i+1 : j]
+			if c == '\'' {
+				body = strings.NewReplacer(`\'`, `'`, `\"`, `\"`, `"`, `\"`).Replace(body)
+			}
+			s, e := strconv.Unquote(`"` + body + `"`)
+			if e != nil {
+				return nil, errorf(i+1, "invalid escape in %s", line[i:j+1])
+			}
+			kind := tokString
+			if c == '\'' {
+				if len(s) != 1 {
+					return nil, errorf(i+1, "character %s must be a byte", line[i:j+1])
+				}
+				kind = tokNumber
+				s = strconv.Itoa(int(s[0]))
+			}
+			toks = append(toks, token{kind: kind, text: s, col: i + 1})
+			i = j + 1
+		default:
+			p := ""
+			for _, o := range puncts {
+				if strings.HasPrefix(line[i:], o) {
+					p = o
+					break
+				}
+			}
+			if p == "" {
+				return nil, errorf(i+1, "unexpected `%c`", c)
+			}
+			toks = append(toks, token{kind: tokPunct, text: p, col: i + 1})
+			i += len(p)
+		}
+	}
+	return toks, nil
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || c == '.' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+func isIdent(c byte) bool {
+	return isIdentStart(c) || '0' <= c && c <= '9'
+}
@@ -0,0 +1,211 @@
+// Package cfg builds the control-flow graphs of the subroutines of a ROM analyzed by the disasm package.
+package cfg
+
+import (
+	"sort"
+
+	"github.com/masu-mi/gochip-8/disasm"
+)
+
+// EdgeKind tells how control passes along an edge.
+type EdgeKind int
+
+const (
+	// EdgeFall falls through to the following instruction.
+	EdgeFall EdgeKind = iota
+	// EdgeJump jumps by JP or JP V0.
+	EdgeJump
+	// EdgeSkip skips the following instruction.
+	EdgeSkip
+)
+
+func (k EdgeKind) String() string {
+	switch k {
+	case EdgeFall:
+		return "fall"
+	case EdgeJump:
+		return "jump"
+	case EdgeSkip:
+		return "skip"
+	}
+	return "unknown"
+}
+
+// Edge is an edge to the block at To.
+type Edge struct {
+	To   uint16
+	Kind EdgeKind
+}
+
+// Block is a basic block. Only its last instruction may pass control elsewhere than the following one.
+type Block struct {
+	Start uint16
+	// Insts are the addresses of the instructions.
+	Insts []uint16
+	Succs []Edge
+}
+
+// Last returns the address of the last instruction.
+func (b *Block) Last() uint16 {
+	return b.Insts[len(b.Insts)-1]
+}
+
+// Func is a subroutine, or main, with the blocks reached from Entry without following calls.
+type Func struct {
+	Entry uint16
+	Name  string
+	// Insts are the addresses of the instructions in order.
+	Insts []uint16
+	// Blocks are the blocks in the order of their addresses.
+	Blocks []*Block
+	// Callees are the entries of the subroutines called by the function in order.
+	Callees []uint16
+
+	owns map[uint16]bool
+}
+
+// Owns tells whether the instruction at addr belongs to f.
+func (f *Func) Owns(addr uint16) bool {
+	return f.owns[addr]
+}
+
+// Block returns the block starting at addr.
+func (f *Func) Block(addr uint16) (*Block, bool) {
+	i := sort.Search(len(f.Blocks), func(i int) bool { return f.Blocks[i].Start >= addr })
+	if i < len(f.Blocks) && f.Blocks[i].Start == addr {
+		return f.Blocks[i], true
+	}
+	return nil, false
+}
+
+// Graph is the functions of a program.
+type Graph struct {
+	Program *disasm.Program
+	// Funcs are in the order of their entries.
+	Funcs []*Func
+}
+
+// Func returns the function of entry.
+func (g *Graph) Func(entry uint16) (*Func, bool) {
+	for _, f := range g.Funcs {
+		if f.Entry == entry {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+// Succs returns the intra-procedural successors of the instruction at addr.
+// Calls continue to the following instruction and the targets of JP V0 are the JP table found by the disasm package.
+func Succs(p *disasm.Program, addr uint16) []Edge {
+	inst, ok := p.Code[addr]
+	if !ok {
+		return nil
+	}
+	next := addr + uint16(inst.Size)
+	var edges []Edge
+	add := func(to uint16, kind EdgeKind) {
+		if _, ok := p.Code[to]; ok {
+			edges = append(edges, Edge{To: to, Kind: kind})
+		}
+	}
+	switch inst.Flow {
+	case disasm.FlowNext, disasm.FlowCall:
+		add(next, EdgeFall)
+	case disasm.FlowSkip:
+		add(next, EdgeFall)
+		if n, ok := p.Code[next]; ok {
+			add(next+uint16(n.Size), EdgeSkip)
+		}
+	case disasm.FlowJump:
+		add(inst.Target, EdgeJump)
+	case disasm.FlowJumpIndirect:
+		for t := inst.Target; ; t += 2 {
+			n, ok := p.Code[t]
+			if !ok || n.Op>>12 != 0x1 {
+				break
+			}
+			add(t, EdgeJump)
+		}
+	}
+	return edges
+}
+
+// Build builds the functions from the entries of p. An instruction reached from many entries
+// belongs to the first of them, so jumps into another function are edges out of the function.
+func Build(p *disasm.Program) *Graph {
+	g := &Graph{Program: p}
+	// entries belong to their functions even if other functions reach them.
+	owner := map[uint16]*Func{}
+	for _, entry := range p.Entries {
+		f := &Func{Entry: entry, Name: p.Labels[entry], owns: map[uint16]bool{}}
+		g.Funcs = append(g.Funcs, f)
+		owner[entry] = f
+	}
+	for _, f := range g.Funcs {
+		work := []uint16{f.Entry}
+		callees := map[uint16]bool{}
+		for len(work) > 0 {
+			a := work[len(work)-1]
+			work = work[:len(work)-1]
+			if o, ok := owner[a]; ok && (o != f || f.owns[a]) {
+				continue
+			}
+			if _, ok := p.Code[a]; !ok {
+				continue
+			}
+			owner[a], f.owns[a] = f, true
+			f.Insts = append(f.Insts, a)
+			if inst := p.Code[a]; inst.Flow == disasm.FlowCall {
+				if _, ok := p.Code[inst.Target]; ok {
+					callees[inst.Target] = true
+				}
+			}
+			for _, e := range Succs(p, a) {
+				work = append(work, e.To)
+			}
+		}
+		sort.Slice(f.Insts, func(i, j int) bool { return f.Insts[i] < f.Insts[j] })
+		for c := range callees {
+			f.Callees = append(f.Callees, c)
+		}
+		sort.Slice(f.Callees, func(i, j int) bool { return f.Callees[i] < f.Callees[j] })
+	}
+	for _, f := range g.Funcs {
+		f.Blocks = blocks(p, f)
+	}
+	return g
+}
+
+// blocks splits the instructions of f at the leaders: the entry, the targets of jumps and skips and
+// the instructions after the ends of blocks.
+func blocks(p *disasm.Program, f *Func) []*Block {
+	leaders := map[uint16]bool{f.Entry: true}
+	for _, a := range f.Insts {
+		inst := p.Code[a]
+		succs := Succs(p, a)
+		ends := inst.Flow != disasm.FlowNext && inst.Flow != disasm.FlowCall
+		for _, e := range succs {
+			if ends || e.Kind != EdgeFall {
+				leaders[e.To] = true
+			}
+		}
+		if ends {
+			leaders[a+uint16(inst.Size)] = true
+		}
+	}
+	var bs []*Block
+	var cur *Block
+	for i, a := range f.Insts {
+		// a run is broken by the leaders and by the gaps of the instructions of other functions or data.
+		if cur == nil || leaders[a] || a != f.Insts[i-1]+uint16(p.Code[f.Insts[i-1]].Size) {
+			cur = &Block{Start: a}
+			bs = append(bs, cur)
+		}
+		cur.Insts = append(cur.Insts, a)
+	}
+	for _, b := range bs {
+		b.Succs = Succs(p, b.Last())
+	}
+	return bs
+}
@@ -0,0 +1,47 @@
+package cfg
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/masu-mi/gochip-8/disasm"
+)
+
+func TestBuild(t *testing.T) {
+	rom := []byte{
+		0x22, 0x0a, // CALL sub
+		0x30, 0x01, // loop: SE V0, 1
+		0x60, 0x05, // LD V0, 5
+		0x70, 0x01, // ADD V0, 1
+		0x12, 0x02, // JP loop
+		0x00, 0xee, // sub: RET
+	}
+	g := Build(disasm.Analyze(rom, 0x200))
+	if len(g.Funcs) != 2 {
+		t.Fatalf("%d functions; want main and sub", len(g.Funcs))
+	}
+	main, sub := g.Funcs[0], g.Funcs[1]
+	if !reflect.DeepEqual(main.Callees, []uint16{0x20a}) {
+		t.Errorf("Callees of main = %x; want [20a]", main.Callees)
+	}
+	want := []Block{
+		{Start: 0x200, Insts: []uint16{0x200}, Succs: []Edge{{0x202, EdgeFall}}},
+		{Start: 0x202, Insts: []uint16{0x202}, Succs: []Edge{{0x204, EdgeFall}, {0x206, EdgeSkip}}},
+		{Start: 0x204, Insts: []uint16{0x204}, Succs: []Edge{{0x206, EdgeFall}}},
+		{Start: 0x206, Insts: []uint16{0x206, 0x208}, Succs: []Edge{{0x202, EdgeJump}}},
+	}
+	if len(main.Blocks) != len(want) {
+		t.Fatalf("%d blocks of main; want %d", len(main.Blocks), len(want))
+	}
+	for i, b := range main.Blocks {
+		if !reflect.DeepEqual(*b, want[i]) {
+			t.Errorf("block %d = %+v; want %+v", i, *b, want[i])
+		}
+	}
+	if len(sub.Blocks) != 1 || sub.Blocks[0].Start != 0x20a || len(sub.Blocks[0].Succs) != 0 {
+		t.Errorf("blocks of sub = %+v; want a block of RET", sub.Blocks)
+	}
+	if sub.Owns(0x200) || !main.Owns(0x208) {
+		t.Error("instructions belong to the wrong functions")
+	}
+}
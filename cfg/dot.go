@@ -0,0 +1,89 @@
+package cfg
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/masu-mi/gochip-8/disasm"
+)
+
+// > ref. https://graphviz.org/doc/info/lang.html
+
+// edgeStyles are the attributes of the kinds of edges.
+var edgeStyles = map[EdgeKind]string{
+	EdgeFall: "",
+	EdgeJump: ` [style=bold]`,
+	EdgeSkip: ` [style=dashed, label="skip"]`,
+}
+
+// WriteCallGraph writes the call graph of g in DOT.
+func (g *Graph) WriteCallGraph(w io.Writer) error {
+	var b strings.Builder
+	b.WriteString("digraph calls {\n")
+	b.WriteString("\tnode [shape=box, fontname=\"monospace\"];\n")
+	for _, f := range g.Funcs {
+		fmt.Fprintf(&b, "\t%s [label=\"%s\\n0x%03X\"];\n", quote(f.Name), escape(f.Name), f.Entry)
+	}
+	for _, f := range g.Funcs {
+		for _, c := range f.Callees {
+			fmt.Fprintf(&b, "\t%s -> %s;\n", quote(f.Name), quote(g.Program.Labels[c]))
+		}
+	}
+	b.WriteString("}\n")
+	_, e := io.WriteString(w, b.String())
+	return e
+}
+
+// WriteCFG writes the control-flow graphs of fs in DOT. Each function is a cluster and
+// edges out of the function go to the ellipses of their targets.
+func (g *Graph) WriteCFG(w io.Writer, fs ...*Func) error {
+	var b strings.Builder
+	name := "cfg"
+	if len(fs) == 1 {
+		name = fs[0].Name
+	}
+	fmt.Fprintf(&b, "digraph %s {\n", quote(name))
+	b.WriteString("\tnode [shape=box, fontname=\"monospace\"];\n")
+	for _, f := range fs {
+		fmt.Fprintf(&b, "\tsubgraph %s {\n", quote("cluster_"+f.Name))
+		fmt.Fprintf(&b, "\t\tlabel=%s;\n", quote(f.Name))
+		for _, blk := range f.Blocks {
+			var text strings.Builder
+			if name, ok := g.Program.Labels[blk.Start]; ok {
+				text.WriteString(escape(name) + ":\\l")
+			}
+			for _, a := range blk.Insts {
+				fmt.Fprintf(&text, "0x%03X: %s\\l", a, escape(g.Program.Format(g.Program.Code[a], disasm.SyntaxCowgod)))
+			}
+			fmt.Fprintf(&b, "\t\tb_%03x [label=\"%s\"];\n", blk.Start, text.String())
+		}
+		for _, blk := range f.Blocks {
+			for _, e := range blk.Succs {
+				to := fmt.Sprintf("b_%03x", e.To)
+				if _, ok := f.Block(e.To); !ok {
+					// the target is in another function or in the middle of a block of it.
+					to = fmt.Sprintf("x_%03x_%03x", blk.Start, e.To)
+					target, ok := g.Program.Labels[e.To]
+					if !ok {
+						target = fmt.Sprintf("0x%03X", e.To)
+					}
+					fmt.Fprintf(&b, "\t\t%s [shape=ellipse, label=%s];\n", to, quote(target))
+				}
+				fmt.Fprintf(&b, "\t\tb_%03x -> %s%s;\n", blk.Start, to, edgeStyles[e.Kind])
+			}
+		}
+		b.WriteString("\t}\n")
+	}
+	b.WriteString("}\n")
+	_, e := io.WriteString(w, b.String())
+	return e
+}
+
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s)
+}
+
+func quote(s string) string {
+	return `"` + escape(s) + `"`
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/masu-mi/gochip-8/asm"
+	"github.com/masu-mi/gochip-8/disasm"
+	"github.com/masu-mi/gochip-8/octo"
+	"github.com/masu-mi/gochip-8/symbols"
+	"github.com/spf13/cobra"
+)
+
+var (
+	asmSource  string
+	asmOut     string
+	asmSymbols bool
+	asmSyntax  string
+)
+
+func NewAsmCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "asm",
+		Short: "assemble Cowgod's mnemonics or compile Octo into a ROM",
+		RunE:  assemble,
+	}
+	cmd.PersistentFlags().StringVar(&asmSource, "src", "", "source file path")
+	cmd.PersistentFlags().StringVarP(&asmOut, "out", "o", "", "rom image file path (default: the source with .ch8)")
+	cmd.PersistentFlags().StringVar(&asmSyntax, "syntax", "", "syntax of the source (octo, cowgod; default: octo for .8o and cowgod for the others)")
+	cmd.PersistentFlags().BoolVar(&asmSymbols, "symbols", true, "write the symbol table next to the rom")
+	return cmd
+}
+
+func assemble(cmd *cobra.Command, args []string) error {
+	src, e := os.ReadFile(asmSource)
+	if e != nil {
+		return fmt.Errorf("can't open `%s`: %w", asmSource, e)
+	}
+	name := asmSyntax
+	if name == "" {
+		name = "cowgod"
+		if filepath.Ext(asmSource) == ".8o" {
+			name = "octo"
+		}
+	}
+	syntax, e := disasm.ParseSyntax(name)
+	if e != nil {
+		return e
+	}
+	var p *asm.Program
+	if syntax == disasm.SyntaxOcto {
+		p, e = octo.Compile(asmSource, src)
+	} else {
+		p, e = asm.Assemble(asmSource, src)
+	}
+	var errs asm.ErrorList
+	if errors.As(e, &errs) {
+		for _, e := range errs {
+			fmt.Fprintf(cmd.ErrOrStderr(), "%s\n%s\n", e, e.Excerpt())
+		}
+		return fmt.Errorf("%d errors in `%s`", len(errs), asmSource)
+	}
+	if e != nil {
+		return e
+	}
+	return writeROM(cmd, romPathFor(asmSource, asmOut), p.ROM, p.Symbols, asmSymbols)
+}
+
+// romPathFor returns out or the path of src with the extension of ROMs.
+func romPathFor(src, out string) string {
+	if out != "" {
+		return out
+	}
+	return strings.TrimSuffix(src, filepath.Ext(src)) + ".ch8"
+}
+
+// writeROM writes rom to path and the table next to it.
+func writeROM(cmd *cobra.Command, path string, rom []byte, table *symbols.Table, withSymbols bool) error {
+	if e := os.WriteFile(path, rom, 0644); e != nil {
+		return e
+	}
+	fmt.Fprintf(cmd.ErrOrStderr(), "%s: %d bytes\n", path, len(rom))
+	if !withSymbols {
+		return nil
+	}
+	f, e := os.Create(symbols.PathFor(path))
+	if e != nil {
+		return e
+	}
+	defer f.Close()
+	return table.Write(f)
+}
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/masu-mi/gochip-8/core"
+	"github.com/spf13/cobra"
+)
+
+var (
+	conformanceDir     string
+	conformanceGolden  string
+	conformanceDiffDir string
+	conformanceFrames  int
+	conformanceUpdate  bool
+)
+
+// manifestName is the name of the manifest configuring the ROMs of a conformance directory.
+const manifestName = "conformance.json"
+
+// romExts are the extensions of the ROMs found in a conformance directory.
+var romExts = map[string]bool{".ch8": true, ".sc8": true, ".xo8": true}
+
+func NewConformanceCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "conformance",
+		Short: "run a directory of test ROMs headless and compare their screens with golden images",
+		RunE:  conformance,
+	}
+	cmd.PersistentFlags().StringVar(&conformanceDir, "dir", "", "directory of the test ROMs and "+manifestName)
+	cmd.PersistentFlags().StringVar(&conformanceGolden, "golden", "", "directory of the golden images <rom>.png (default: --dir)")
+	cmd.PersistentFlags().StringVar(&conformanceDiffDir, "diff-dir", "", "directory of <rom>.actual.png and <rom>.diff.png of failures (default: --golden)")
+	cmd.PersistentFlags().IntVar(&conformanceFrames, "frames", 300, "frames of the ROMs not configured in "+manifestName)
+	cmd.PersistentFlags().BoolVar(&conformanceUpdate, "update", false, "write the screens as the golden images instead of comparing")
+	return cmd
+}
+
+// conformanceCase configures the machine running a ROM. Zero values are the defaults.
+type conformanceCase struct {
+	Frames int    `json:"frames"`
+	Quirks string `json:"quirks"`
+	XOChip bool   `json:"xo_chip"`
+	IPF    int    `json:"ipf"`
+	// Memory are the bytes written by address after loading the ROM, e.g. {"0x1ff": 1} selecting
+	// the platform of Timendus' quirks test without a key.
+	Memory map[string]uint8 `json:"memory"`
+}
+
+// conformanceManifest is the defaults of the ROMs and the cases of the ROMs by file name.
+//
+//	{"frames": 300, "roms": {"5-quirks.ch8": {"quirks": "cosmac-vip", "memory": {"0x1ff": 1}}}}
+type conformanceManifest struct {
+	conformanceCase
+	ROMs map[string]conformanceCase `json:"roms"`
+}
+
+// readManifest reads the manifest of dir. A directory without it runs every ROM with the defaults.
+func readManifest(dir string) (*conformanceManifest, error) {
+	m := &conformanceManifest{}
+	b, e := os.ReadFile(filepath.Join(dir, manifestName))
+	if errors.Is(e, fs.ErrNotExist) {
+		return m, nil
+	}
+	if e != nil {
+		return nil, e
+	}
+	if e := json.Unmarshal(b, m); e != nil {
+		return nil, fmt.Errorf("%s: %w", manifestName, e)
+	}
+	return m, nil
+}
+
+// caseOf returns the case of rom filled with the defaults of m.
+func (m *conformanceManifest) caseOf(rom string) conformanceCase {
+	c := m.ROMs[rom]
+	if c.Frames == 0 {
+		c.Frames = m.Frames
+	}
+	if c.Frames == 0 {
+		c.Frames = conformanceFrames
+	}
+	c.XOChip = c.XOChip || m.XOChip
+	if c.Quirks == "" {
+		c.Quirks = m.Quirks
+	}
+	if c.Quirks == "" {
+		c.Quirks = core.DefaultQuirks
+		if c.XOChip {
+			c.Quirks = "xo-chip"
+		}
+	}
+	if c.IPF == 0 {
+		c.IPF = m.IPF
+	}
+	if c.IPF == 0 {
+		c.IPF = core.DefaultIPF
+	}
+	memory := map[string]uint8{}
+	for a, v := range m.Memory {
+		memory[a] = v
+	}
+	for a, v := range c.Memory {
+		memory[a] = v
+	}
+	c.Memory = memory
+	return c
+}
+
+// roms returns the names of the ROMs in dir and in m in order.
+func (m *conformanceManifest) roms(dir string) ([]string, error) {
+	entries, e := os.ReadDir(dir)
+	if e != nil {
+		return nil, e
+	}
+	found := map[string]bool{}
+	for _, ent := range entries {
+		if !ent.IsDir() && romExts[filepath.Ext(ent.Name())] {
+			found[ent.Name()] = true
+		}
+	}
+	for name := range m.ROMs {
+		found[name] = true
+	}
+	names := make([]string, 0, len(found))
+	for name := range found {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// runCase runs the ROM of path as c configures and returns the final screen.
+func runCase(path string, c conformanceCase) (*core.Frame, core.StopReason, error) {
+	chip, _, _, e := newDebugMachine(path, c.Quirks, c.XOChip, c.IPF)
+	if e != nil {
+		return nil, 0, e
+	}
+	defer chip.Close()
+	for a, v := range c.Memory {
+		addr, e := strconv.ParseUint(a, 0, 16)
+		if e != nil || int(addr) >= len(chip.Memory.Buf) {
+			return nil, 0, fmt.Errorf("invalid address `%s`", a)
+		}
+		chip.Memory.Buf[addr] = v
+	}
+	reason, e := chip.RunUntil(core.Until{Frames: uint64(c.Frames)})
+	return chip.Display.(core.FrameHolder).Frame(), reason, e
+}
+
+func conformance(cmd *cobra.Command, args []string) error {
+	if conformanceDir == "" {
+		return fmt.Errorf("no directory of test ROMs: give --dir")
+	}
+	golden := conformanceGolden
+	if golden == "" {
+		golden = conformanceDir
+	}
+	diffDir := conformanceDiffDir
+	if diffDir == "" {
+		diffDir = golden
+	}
+	m, e := readManifest(conformanceDir)
+	if e != nil {
+		return e
+	}
+	names, e := m.roms(conformanceDir)
+	if e != nil {
+		return e
+	}
+	cmd.SilenceUsage, cmd.SilenceErrors = true, true
+
+	tw := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "ROM\tFRAMES\tSTOP\tRESULT")
+	failed := 0
+	for _, name := range names {
+		c := m.caseOf(name)
+		stop, result, pass := checkROM(name, c, golden, diffDir)
+		if !pass {
+			failed++
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%s\n", name, c.Frames, stop, result)
+	}
+	if e := tw.Flush(); e != nil {
+		return e
+	}
+	if failed > 0 {
+		return &exitError{Code: 1, Err: fmt.Errorf("%d of %d ROMs failed", failed, len(names))}
+	}
+	return nil
+}
+
+// checkROM runs the ROM and compares its screen with the golden image, or updates the image with --update.
+// It returns the columns STOP and RESULT of the table.
+func checkROM(name string, c conformanceCase, golden, diffDir string) (stop, result string, pass bool) {
+	f, reason, e := runCase(filepath.Join(conformanceDir, name), c)
+	if f == nil {
+		return "-", "FAIL: " + e.Error(), false
+	}
+	stop = reason.String()
+	if e != nil {
+		return stop, "FAIL: " + e.Error(), false
+	}
+	path := filepath.Join(golden, name+".png")
+	if conformanceUpdate {
+		if e := writePNG(path, frameImage(f)); e != nil {
+			return stop, "FAIL: " + e.Error(), false
+		}
+		return stop, "updated", true
+	}
+	expected, e := readPNG(path)
+	if e != nil {
+		return stop, "FAIL: no golden image: " + e.Error(), false
+	}
+	n, diff := diffImage(expected, f)
+	if n == 0 {
+		return stop, "pass", true
+	}
+	base := filepath.Join(diffDir, name)
+	if e := writePNG(base+".actual.png", frameImage(f)); e != nil {
+		return stop, "FAIL: " + e.Error(), false
+	}
+	if e := writePNG(base+".diff.png", diff); e != nil {
+		return stop, "FAIL: " + e.Error(), false
+	}
+	return stop, fmt.Sprintf("FAIL: %d pixels differ (%s)", n, filepath.Base(base)+".diff.png"), false
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/masu-mi/gochip-8/core"
+	"github.com/masu-mi/gochip-8/dap"
+	"github.com/spf13/cobra"
+)
+
+var dapListen string
+
+func NewDAPCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "dap",
+		Short: "serve the debug adapter protocol on stdio or --listen",
+		RunE:  serveDAP,
+	}
+	cmd.PersistentFlags().StringVar(&dapListen, "listen", "", "address to accept a client on instead of stdio")
+	return cmd
+}
+
+func serveDAP(cmd *cobra.Command, args []string) error {
+	s := dap.New(launchDAP)
+	if dapListen == "" {
+		return s.Serve(os.Stdin, os.Stdout)
+	}
+	l, e := net.Listen("tcp", dapListen)
+	if e != nil {
+		return e
+	}
+	defer l.Close()
+	fmt.Fprintf(cmd.ErrOrStderr(), "listening on %s\n", l.Addr())
+	c, e := l.Accept()
+	if e != nil {
+		return e
+	}
+	defer c.Close()
+	return s.Serve(c, c)
+}
+
+func launchDAP(a *dap.LaunchArguments) (*core.Chip8, *core.Keypad, error) {
+	quirks := a.Quirks
+	if quirks == "" {
+		quirks = core.DefaultQuirks
+		if a.XOChip {
+			quirks = "xo-chip"
+		}
+	}
+	ipf := a.IPF
+	if ipf == 0 {
+		ipf = core.DefaultIPF
+	}
+	chip, keypad, _, e := newDebugMachine(a.Program, quirks, a.XOChip, ipf)
+	return chip, keypad, e
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/masu-mi/gochip-8/core"
+	"github.com/masu-mi/gochip-8/debugger"
+	"github.com/spf13/cobra"
+)
+
+var (
+	debugPath   string
+	debugQuirks string
+	debugXOChip bool
+	debugIPF    int
+)
+
+func NewDebugCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "debug",
+		Short: "debug a ROM with an interactive command line (type `help`)",
+		RunE:  debug,
+	}
+	addDebugFlags(cmd)
+	return cmd
+}
+
+// addDebugFlags adds the flags of the machine shared by the debuggers.
+func addDebugFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVar(&debugPath, "rom", "", "rom image file path")
+	cmd.PersistentFlags().StringVar(&debugQuirks, "quirks", core.DefaultQuirks, fmt.Sprintf("quirk profile (%s)", strings.Join(core.QuirkPresetNames(), ", ")))
+	cmd.PersistentFlags().BoolVar(&debugXOChip, "xo-chip", false, "enable XO-CHIP's 64KB memory (quirks default to xo-chip)")
+	cmd.PersistentFlags().IntVar(&debugIPF, "ipf", core.DefaultIPF, "instructions per 60Hz frame")
+}
+
+func debug(cmd *cobra.Command, args []string) error {
+	chip, keypad, e := newDebugChip(cmd)
+	if e != nil {
+		return e
+	}
+	defer chip.Close()
+
+	repl := debugger.NewREPL(debugger.New(chip), cmd.OutOrStdout())
+	repl.Keypad = keypad
+	repl.Interrupt = func(ctx context.Context) (context.Context, context.CancelFunc) {
+		return signal.NotifyContext(ctx, os.Interrupt)
+	}
+	return repl.Run(context.Background(), cmd.InOrStdin())
+}
+
+// newDebugChip loads the ROM of the flags into a machine pressed through the returned keypad.
+func newDebugChip(cmd *cobra.Command) (*core.Chip8, *core.Keypad, error) {
+	quirks := debugQuirks
+	if debugXOChip && !cmd.Flags().Changed("quirks") {
+		quirks = "xo-chip"
+	}
+	chip, keypad, n, e := newDebugMachine(debugPath, quirks, debugXOChip, debugIPF)
+	if e != nil {
+		return nil, nil, e
+	}
+	fmt.Fprintf(cmd.ErrOrStderr(), "load: %d[byte]\n", n)
+	return chip, keypad, nil
+}
+
+// newDebugMachine loads the ROM of path and returns the size of the ROM too.
+func newDebugMachine(path, quirks string, xoChip bool, ipf int) (*core.Chip8, *core.Keypad, int, error) {
+	memSize := core.MemorySize
+	if xoChip {
+		memSize = core.XOMemorySize
+	}
+	q, e := core.LookupQuirks(quirks)
+	if e != nil {
+		return nil, nil, 0, e
+	}
+	if ipf < 1 {
+		return nil, nil, 0, fmt.Errorf("instructions per frame must be positive: %d", ipf)
+	}
+	f, e := os.Open(path)
+	if e != nil {
+		return nil, nil, 0, fmt.Errorf("can't open `%s`: %w", path, e)
+	}
+	defer f.Close()
+
+	keypad := core.NewKeypad()
+	chip := &core.Chip8{
+		// timers follow executed instructions so that they stop with the program.
+		Cpu:      core.NewCpu(core.NewCycleClock(ipf), nil),
+		Memory:   core.NewMemory(memSize),
+		Display:  core.NewFrameBuffer(),
+		Keyboard: keypad,
+		IPF:      ipf,
+	}
+	chip.Cpu.Quirks = q
+	n, e := chip.Init(f)
+	if e != nil {
+		chip.Close()
+		return nil, nil, 0, e
+	}
+	return chip, keypad, n, nil
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"github.com/masu-mi/gochip-8/cfg"
+	"github.com/masu-mi/gochip-8/core"
+	"github.com/masu-mi/gochip-8/decompile"
+	"github.com/masu-mi/gochip-8/disasm"
+	"github.com/spf13/cobra"
+)
+
+var decompilePath string
+
+func NewDecompileCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "decompile",
+		Short: "decompile a ROM into structured Octo-like code",
+		RunE:  decompileROM,
+	}
+	cmd.PersistentFlags().StringVar(&decompilePath, "rom", "", "rom image file path")
+	return cmd
+}
+
+func decompileROM(cmd *cobra.Command, args []string) error {
+	rom, e := readROM(decompilePath)
+	if e != nil {
+		return e
+	}
+	g := cfg.Build(disasm.Analyze(rom, core.StartOfProgram))
+	return decompile.Write(cmd.OutOrStdout(), g)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/masu-mi/gochip-8/core"
+	"github.com/masu-mi/gochip-8/disasm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	disasmPath   string
+	disasmSyntax string
+)
+
+func NewDisasmCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "disasm",
+		Short: "disassemble a ROM separating code from data",
+		RunE:  disassemble,
+	}
+	cmd.PersistentFlags().StringVar(&disasmPath, "rom", "", "rom image file path")
+	cmd.PersistentFlags().StringVar(&disasmSyntax, "syntax", "octo", "syntax of the output (octo, cowgod)")
+	return cmd
+}
+
+func disassemble(cmd *cobra.Command, args []string) error {
+	syntax, e := disasm.ParseSyntax(disasmSyntax)
+	if e != nil {
+		return e
+	}
+	rom, e := readROM(disasmPath)
+	if e != nil {
+		return e
+	}
+	return disasm.Analyze(rom, core.StartOfProgram).Write(cmd.OutOrStdout(), syntax)
+}
+
+// readROM reads the whole ROM of path.
+func readROM(path string) ([]byte, error) {
+	f, e := os.Open(path)
+	if e != nil {
+		return nil, fmt.Errorf("can't open `%s`: %w", path, e)
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+
+	"github.com/masu-mi/gochip-8/debugger"
+	"github.com/masu-mi/gochip-8/gdbstub"
+	"github.com/spf13/cobra"
+)
+
+var gdbListen string
+
+func NewGDBServerCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "gdbserver",
+		Short: "serve a ROM to gdb over the remote serial protocol",
+		RunE:  gdbServer,
+	}
+	addDebugFlags(cmd)
+	cmd.PersistentFlags().StringVar(&gdbListen, "listen", "localhost:1234", "address to accept gdb on")
+	return cmd
+}
+
+func gdbServer(cmd *cobra.Command, args []string) error {
+	chip, keypad, e := newDebugChip(cmd)
+	if e != nil {
+		return e
+	}
+	defer chip.Close()
+	l, e := net.Listen("tcp", gdbListen)
+	if e != nil {
+		return e
+	}
+	fmt.Fprintf(cmd.ErrOrStderr(), "listening on %s (target remote %s)\n", l.Addr(), l.Addr())
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	s := gdbstub.New(debugger.New(chip))
+	s.Keypad = keypad
+	return s.Serve(ctx, l)
+}
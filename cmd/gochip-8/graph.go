@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/masu-mi/gochip-8/cfg"
+	"github.com/masu-mi/gochip-8/core"
+	"github.com/masu-mi/gochip-8/disasm"
+	"github.com/spf13/cobra"
+)
+
+var (
+	graphPath string
+	graphKind string
+	graphFunc string
+	graphDir  string
+)
+
+func NewGraphCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "graph",
+		Short: "write the call graph or the control-flow graphs of a ROM in Graphviz DOT",
+		RunE:  writeGraph,
+	}
+	cmd.PersistentFlags().StringVar(&graphPath, "rom", "", "rom image file path")
+	cmd.PersistentFlags().StringVar(&graphKind, "graph", "calls", "graph to write (calls, cfg)")
+	cmd.PersistentFlags().StringVar(&graphFunc, "func", "", "label of the subroutine of the cfg (default: all)")
+	cmd.PersistentFlags().StringVar(&graphDir, "dir", "", "write calls.dot and <subroutine>.dot of each subroutine into the directory")
+	return cmd
+}
+
+func writeGraph(cmd *cobra.Command, args []string) error {
+	rom, e := readROM(graphPath)
+	if e != nil {
+		return e
+	}
+	g := cfg.Build(disasm.Analyze(rom, core.StartOfProgram))
+	if graphDir != "" {
+		return writeGraphFiles(g, graphDir)
+	}
+	out := cmd.OutOrStdout()
+	switch graphKind {
+	case "calls":
+		return g.WriteCallGraph(out)
+	case "cfg":
+		if graphFunc == "" {
+			return g.WriteCFG(out, g.Funcs...)
+		}
+		for _, f := range g.Funcs {
+			if f.Name == graphFunc {
+				return g.WriteCFG(out, f)
+			}
+		}
+		return fmt.Errorf("no subroutine `%s`", graphFunc)
+	}
+	return fmt.Errorf("unknown graph `%s` (calls, cfg)", graphKind)
+}
+
+// writeGraphFiles writes the call graph and the control-flow graph of each function into dir.
+func writeGraphFiles(g *cfg.Graph, dir string) error {
+	if e := os.MkdirAll(dir, 0755); e != nil {
+		return e
+	}
+	write := func(name string, f func(*os.File) error) error {
+		out, e := os.Create(filepath.Join(dir, name))
+		if e != nil {
+			return e
+		}
+		if e := f(out); e != nil {
+			out.Close()
+			return e
+		}
+		return out.Close()
+	}
+	if e := write("calls.dot", func(out *os.File) error { return g.WriteCallGraph(out) }); e != nil {
+		return e
+	}
+	for _, f := range g.Funcs {
+		f := f
+		if e := write(f.Name+".dot", func(out *os.File) error { return g.WriteCFG(out, f) }); e != nil {
+			return e
+		}
+	}
+	return nil
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
@@ -10,10 +11,28 @@ import (
 func main() {
 	if err := run(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
+		var ee *exitError
+		if errors.As(err, &ee) {
+			os.Exit(ee.Code)
+		}
 		os.Exit(1)
 	}
 }
 
+// exitError makes the process exit with Code.
+type exitError struct {
+	Code int
+	Err  error
+}
+
+func (e *exitError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.Err
+}
+
 func run() error {
 	cmd := newRootCommand()
 	return cmd.Execute()
@@ -24,6 +43,6 @@ func newRootCommand() *cobra.Command {
 		Use:  "chip-8-term",
 		Args: cobra.ExactArgs(0),
 	}
-	cmd.AddCommand(NewColorCmd(), NewStartCommand())
+	cmd.AddCommand(NewColorCmd(), NewStartCommand(), NewDebugCommand(), NewGDBServerCommand(), NewDAPCommand(), NewDisasmCommand(), NewAsmCommand(), NewDecompileCommand(), NewGraphCommand(), NewRunCommand(), NewConformanceCommand())
 	return cmd
 }
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/masu-mi/gochip-8/core"
+	"github.com/masu-mi/gochip-8/disasm"
+	"github.com/nsf/termbox-go"
+)
+
+const (
+	panesHz = 30
+	// panesWidth is the width of the registers column and the disassembly and memory column.
+	panesWidth    = 24 + 2 + 32
+	disasmLines   = 15
+	memoryLines   = 8
+	memoryPerLine = 8
+)
+
+// keypadLayout is the COSMAC VIP's hex keypad.
+var keypadLayout = [4][4]uint8{
+	{0x1, 0x2, 0x3, 0xc},
+	{0x4, 0x5, 0x6, 0xd},
+	{0x7, 0x8, 0x9, 0xe},
+	{0xa, 0x0, 0xb, 0xf},
+}
+
+// Panes shows the state of chip on the right of the screen.
+type Panes struct {
+	chip *core.Chip8
+	// highlight is the attribute of PC and pressed keys.
+	highlight termbox.Attribute
+}
+
+func NewPanes(chip *core.Chip8, highlight termbox.Attribute) *Panes {
+	return &Panes{chip: chip, highlight: highlight}
+}
+
+// Run redraws the panes at panesHz until ctx is done.
+func (p *Panes) Run(ctx context.Context) {
+	t := time.NewTicker(time.Second / panesHz)
+	defer t.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			p.draw()
+		}
+	}
+}
+
+// paneState is a copy of the state of chip taken between frames.
+type paneState struct {
+	v      [16]uint8
+	i      uint16
+	pc, sp uint16
+	stack  [16]uint16
+	dt, st uint8
+	hires  bool
+	keys   [16]bool
+	code   []disasm.Inst
+	addrs  []uint16
+	mem    []uint8
+	memAt  uint16
+}
+
+func (p *Panes) snapshot() *paneState {
+	chip := p.chip
+	chip.Lock()
+	defer chip.Unlock()
+	cpu := chip.Cpu
+	s := &paneState{
+		v:     cpu.V,
+		i:     cpu.I,
+		pc:    cpu.Pc,
+		sp:    uint16(cpu.Sp),
+		stack: cpu.Stack,
+		dt:    cpu.Dt.GetV(),
+		st:    cpu.St.GetV(),
+		hires: cpu.Hires,
+	}
+	if chip.Keyboard != nil {
+		for k := range s.keys {
+			s.keys[k] = chip.Keyboard.IsPressed(uint8(k))
+		}
+	}
+	// instructions are assumed to be 2 bytes long before PC.
+	addr := s.pc
+	if back := uint16(disasmLines / 2 * 2); addr >= back {
+		addr -= back
+	}
+	mem := chip.Memory.Buf
+	for n := 0; n < disasmLines && int(addr) < len(mem); n++ {
+		inst := disasm.At(mem, addr)
+		s.addrs = append(s.addrs, addr)
+		s.code = append(s.code, inst)
+		addr += uint16(inst.Size)
+	}
+	s.memAt = s.i &^ (memoryPerLine - 1)
+	if int(s.memAt) < len(mem) {
+		end := int(s.memAt) + memoryLines*memoryPerLine
+		if end > len(mem) {
+			end = len(mem)
+		}
+		s.mem = append([]uint8(nil), mem[s.memAt:end]...)
+	}
+	return s
+}
+
+func (p *Panes) draw() {
+	// the snapshot is taken before term is locked as Chip8.Run renders the screen with the chip locked.
+	s := p.snapshot()
+	term.Lock()
+	defer term.Unlock()
+	if term.closed {
+		return
+	}
+	x0 := core.WIDTH + 1
+	if s.hires {
+		x0 = core.HIRES_WIDTH + 1
+	}
+	for y := 0; y < core.HEIGHT; y++ {
+		for x := x0; x < x0+panesWidth; x++ {
+			termbox.SetCell(x, y, ' ', termbox.ColorDefault, termbox.ColorDefault)
+		}
+	}
+	def := termbox.ColorDefault
+
+	y := 0
+	for r := 0; r < 4; r++ {
+		line := ""
+		for c := 0; c < 4; c++ {
+			n := r*4 + c
+			line += fmt.Sprintf("V%X %02x ", n, s.v[n])
+		}
+		printAt(x0, y, line, def)
+		y++
+	}
+	y++
+	printAt(x0, y, fmt.Sprintf("I  %04x  PC %04x", s.i, s.pc), def)
+	y++
+	printAt(x0, y, fmt.Sprintf("DT %02x    ST %02x", s.dt, s.st), def)
+	y++
+	printAt(x0, y, fmt.Sprintf("SP %d", s.sp), def)
+	y++
+	for r := 0; r < 4; r++ {
+		for c := 0; c < 4; c++ {
+			if n := uint16(r*4 + c); n < s.sp {
+				printAt(x0+c*6, y, fmt.Sprintf("%04x", s.stack[n]), def)
+			}
+		}
+		y++
+	}
+	y++
+	for _, row := range keypadLayout {
+		for c, k := range row {
+			fg := def
+			if s.keys[k] {
+				fg = p.highlight | termbox.AttrReverse
+			}
+			printAt(x0+c*2, y, fmt.Sprintf("%X", k), fg)
+		}
+		y++
+	}
+
+	x1 := x0 + 26
+	for n, inst := range s.code {
+		mark, fg := "  ", def
+		if s.addrs[n] == s.pc {
+			mark, fg = "=>", p.highlight
+		}
+		printAt(x1, n, fmt.Sprintf("%s %03x %v", mark, s.addrs[n], inst), fg)
+	}
+	y = disasmLines + 1
+	for n := 0; n < len(s.mem); n += memoryPerLine {
+		end := n + memoryPerLine
+		if end > len(s.mem) {
+			end = len(s.mem)
+		}
+		printAt(x1, y, fmt.Sprintf("%04x % x", int(s.memAt)+n, s.mem[n:end]), def)
+		y++
+	}
+	termbox.Flush()
+}
+
+func printAt(x, y int, s string, fg termbox.Attribute) {
+	for i, r := range []rune(s) {
+		termbox.SetCell(x+i, y, r, fg, termbox.ColorDefault)
+	}
+}
@@ -2,7 +2,7 @@ package main
 
 import (
 	"context"
-	"errors"
+	"fmt"
 	"sync"
 	"time"
 
@@ -10,23 +10,47 @@ import (
 	"github.com/nsf/termbox-go"
 )
 
-type Display struct {
-	color termbox.Attribute
+// Screen paints frames of core.FrameBuffer on termbox.
+// Each pixel is a cell's background in low resolution.
+// In SUPER-CHIP's high resolution two vertical pixels share a cell as half blocks.
+// The colour of a pixel is picked from palette by the pixel's bits of XO-CHIP's planes.
+type Screen struct {
+	palette [4]termbox.Attribute
+	// mux guards hires and err, which are read by the goroutine of the events.
+	mux   sync.Mutex
+	hires bool
+	err   error
+	// stop closes termbox and cancels the emulation once, with the error which stopped it.
+	stop func(e error)
 }
 
-func StarTermbox(ctx context.Context, color termbox.Attribute) (context.Context, *Display, *Keyboard, error) {
+// StarTermbox starts termbox. hotkeys are called in new goroutines on their keys.
+func StarTermbox(ctx context.Context, palette [4]termbox.Attribute, hotkeys map[termbox.Key]func()) (context.Context, *Screen, *Keyboard, error) {
 	c, cancel := context.WithCancel(ctx)
+	term.Lock()
+	term.closed = false
+	term.Unlock()
+	dsp := &Screen{palette: palette}
+	var once sync.Once
+	dsp.stop = func(e error) {
+		once.Do(func() {
+			dsp.mux.Lock()
+			dsp.err = e
+			dsp.mux.Unlock()
+			closeTermbox()
+			cancel()
+		})
+	}
 	e := termbox.Init()
 	if e != nil {
-		termbox.Close()
+		closeTermbox()
 		cancel()
 		return c, nil, nil, e
 	}
-	w, h := termbox.Size()
-	if !IsDisplaySizeSufficient(w, h) {
-		termbox.Close()
+	if e := dsp.checkSize(false); e != nil {
+		closeTermbox()
 		cancel()
-		return c, nil, nil, errors.New("terminal is too small")
+		return c, nil, nil, e
 	}
 	ch := make(chan rune)
 	kb := NewKeyboard(ch, DefaultConvert)
@@ -37,45 +61,145 @@ func StarTermbox(ctx context.Context, color termbox.Attribute) (context.Context,
 			case termbox.EventKey:
 				switch ev.Key {
 				case termbox.KeyEsc:
-					termbox.Close()
-					cancel()
+					dsp.stop(nil)
 					break MAINLOOP
 				default:
+					if f, ok := hotkeys[ev.Key]; ok {
+						go f()
+						continue
+					}
 					ch <- ev.Ch
 				}
+			case termbox.EventResize:
+				dsp.mux.Lock()
+				hires := dsp.hires
+				dsp.mux.Unlock()
+				if e := dsp.checkSize(hires); e != nil {
+					dsp.stop(e)
+					break MAINLOOP
+				}
 			}
 		}
 	}()
-	return c, &Display{color: color}, kb, nil
+	return c, dsp, kb, nil
+}
+
+// screenSize returns the cells showing the screen of the resolution and the status line below it.
+// Two vertical pixels share a cell in high resolution.
+func screenSize(hires bool) (w, h int) {
+	if hires {
+		return core.HIRES_WIDTH, core.HIRES_HEIGHT/2 + 1
+	}
+	return core.WIDTH, core.HEIGHT + 1
+}
+
+// IsDisplaySizeSufficient tells whether a terminal of w x h cells shows the screen of the resolution.
+func IsDisplaySizeSufficient(w, h int, hires bool) bool {
+	sw, sh := screenSize(hires)
+	return w >= sw && h >= sh
+}
+
+// checkSize returns an error unless the terminal shows the screen of the resolution.
+func (t *Screen) checkSize(hires bool) error {
+	if w, h := termbox.Size(); IsDisplaySizeSufficient(w, h, hires) {
+		return nil
+	}
+	w, h := screenSize(hires)
+	return fmt.Errorf("terminal is too small: %dx%d is needed", w, h)
 }
 
-func IsDisplaySizeSufficient(w, h int) bool {
-	return w >= 64 && h >= 32
+// Err returns the error which stopped the emulation, e.g. a terminal too small for high resolution.
+func (t *Screen) Err() error {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+	return t.err
 }
 
-func (t *Display) Clear() {
-	termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
+// term serializes the calls of termbox, which isn't safe for concurrent use:
+// Chip8.Run draws the screen while the panes and the status line are drawn by other goroutines.
+var term struct {
+	sync.Mutex
+	// closed is true after closeTermbox, and nothing is drawn then.
+	closed bool
 }
-func (t *Display) Draw(x, y uint8, sprite []byte) (collision bool) {
-	for dh, b := range sprite {
-		for rdw := 0; rdw < 8; rdw++ {
-			input := (b >> rdw) & 1
-			cx, cy := (int(x)+7-rdw)%core.WIDTH, (int(y)+dh)%core.HEIGHT
-			cell := termbox.GetCell(cx, cy)
-			col := cell.Bg == t.color && input == 1
-			if (cell.Bg == t.color || input == 1) && !col {
-				termbox.SetBg(cx, cy, t.color)
-			} else {
-				termbox.SetBg(cx, cy, termbox.ColorDefault)
+
+// closeTermbox closes termbox once.
+func closeTermbox() {
+	term.Lock()
+	defer term.Unlock()
+	if !term.closed {
+		term.closed = true
+		termbox.Close()
+	}
+}
+
+// ShowStatus writes msg on the line below the screen.
+func ShowStatus(msg string) {
+	term.Lock()
+	defer term.Unlock()
+	if term.closed {
+		return
+	}
+	w, _ := termbox.Size()
+	for x := 0; x < w; x++ {
+		termbox.SetCell(x, core.HEIGHT, ' ', termbox.ColorDefault, termbox.ColorDefault)
+	}
+	for i, r := range []rune(msg) {
+		termbox.SetCell(i, core.HEIGHT, r, termbox.ColorDefault, termbox.ColorDefault)
+	}
+	termbox.Flush()
+}
+
+func (t *Screen) Render(f *core.Frame) {
+	t.mux.Lock()
+	switched := t.hires != f.Hires
+	t.hires = f.Hires
+	t.mux.Unlock()
+	if switched {
+		if e := t.checkSize(f.Hires); e != nil {
+			t.stop(e)
+			return
+		}
+	}
+	term.Lock()
+	defer term.Unlock()
+	if term.closed {
+		return
+	}
+	if switched {
+		termbox.Clear(termbox.ColorDefault, termbox.ColorDefault)
+	}
+	if !f.Hires {
+		for y := 0; y < f.Height; y++ {
+			for x := 0; x < f.Width; x++ {
+				termbox.SetCell(x, y, ' ', termbox.ColorDefault, t.palette[f.At(x, y)])
 			}
-			collision = collision || col
+		}
+		termbox.Flush()
+		return
+	}
+	for y := 0; y < f.Height; y += 2 {
+		for x := 0; x < f.Width; x++ {
+			t.setHalfBlocks(x, y/2, f.At(x, y), f.At(x, y+1))
 		}
 	}
 	termbox.Flush()
-	return collision
 }
 
-var _ core.Display = &Display{}
+func (t *Screen) setHalfBlocks(x, y int, top, bottom uint8) {
+	switch {
+	case top == 0 && bottom == 0:
+		termbox.SetCell(x, y, ' ', termbox.ColorDefault, termbox.ColorDefault)
+	case top == bottom:
+		termbox.SetCell(x, y, '█', t.palette[top], termbox.ColorDefault)
+	case top == 0:
+		termbox.SetCell(x, y, '▄', t.palette[bottom], termbox.ColorDefault)
+	default:
+		termbox.SetCell(x, y, '▀', t.palette[top], t.palette[bottom])
+	}
+}
+
+var _ core.Renderer = &Screen{}
 
 type Keyboard struct {
 	sync.RWMutex
@@ -83,9 +207,14 @@ type Keyboard struct {
 	time.Duration
 	convert map[rune]uint8
 
-	events  chan uint8
-	pressed map[uint8]bool
-	timers  map[uint8]*time.Timer
+	events   chan uint8
+	releases chan uint8
+	pressed  map[uint8]bool
+	timers   map[uint8]*time.Timer
+
+	// waiting is true while a wait of WaitKey continues, and waitFor is its pressed key or -1.
+	waiting bool
+	waitFor int
 }
 
 var _ core.Keyboard = &Keyboard{}
@@ -103,9 +232,10 @@ func NewKeyboard(tty <-chan rune, convert map[rune]uint8) *Keyboard {
 		Duration: time.Second / time.Duration(fps),
 		convert:  convert,
 
-		events:  make(chan uint8),
-		pressed: map[uint8]bool{},
-		timers:  map[uint8]*time.Timer{},
+		events:   make(chan uint8, 16),
+		releases: make(chan uint8, 16),
+		pressed:  map[uint8]bool{},
+		timers:   map[uint8]*time.Timer{},
 	}
 
 	go func() {
@@ -126,6 +256,10 @@ func (k *Keyboard) up(key uint8) {
 	defer k.Unlock()
 	k.pressed[key] = false
 	delete(k.timers, key)
+	select {
+	case k.releases <- key:
+	default:
+	}
 }
 
 func (k *Keyboard) press(key uint8) {
@@ -151,17 +285,60 @@ func (k *Keyboard) IsPressed(key uint8) bool {
 	defer k.RUnlock()
 	return k.pressed[key]
 }
-func (k *Keyboard) Wait(ctx context.Context, key uint8) {
-LOOP:
-	for {
-		select {
-		case <-ctx.Done():
-			break LOOP
-		case pressed := <-k.events:
-			if pressed == key {
-				break LOOP
-			}
+
+// WaitKey is polled by every frame with a done ctx while Fx0A waits, so a wait continues over the calls until it returns a key:
+// the presses and releases before it began are dropped only by the first call.
+func (k *Keyboard) WaitKey(ctx context.Context, release bool) (uint8, bool) {
+	k.Lock()
+	if !k.waiting {
+		k.waiting, k.waitFor = true, -1
+		// drop presses and releases of keys before waiting
+		for len(k.events) > 0 {
+			<-k.events
 		}
+		for len(k.releases) > 0 {
+			<-k.releases
+		}
+	}
+	waitFor := k.waitFor
+	k.Unlock()
+	if waitFor < 0 {
+		key, ok := receive(ctx, k.events)
+		if !ok {
+			return 0, false
+		}
+		waitFor = int(key)
+		k.Lock()
+		k.waitFor = waitFor
+		k.Unlock()
+	}
+	key := uint8(waitFor)
+	for release {
+		released, ok := receive(ctx, k.releases)
+		if !ok {
+			return 0, false
+		}
+		if released == key {
+			break
+		}
+	}
+	k.Lock()
+	k.waiting = false
+	k.Unlock()
+	return key, true
+}
+
+// receive receives from ch, preferring a pending value to ctx being done.
+func receive(ctx context.Context, ch <-chan uint8) (uint8, bool) {
+	select {
+	case v := <-ch:
+		return v, true
+	default:
+	}
+	select {
+	case <-ctx.Done():
+		return 0, false
+	case v := <-ch:
+		return v, true
 	}
-	return
 }
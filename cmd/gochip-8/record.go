@@ -0,0 +1,174 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/gif"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/masu-mi/gochip-8/apng"
+	"github.com/masu-mi/gochip-8/core"
+	"github.com/nsf/termbox-go"
+)
+
+// Recorder is a Renderer recording the frames presented while it's started, and writes them as an animated
+// GIF or APNG in the colours and the scale of Screenshots.
+type Recorder struct {
+	mux   sync.Mutex
+	chip  *core.Chip8
+	shots *Screenshots
+
+	path   string
+	frames []*core.Frame
+	// at are the numbers of the frames of Chip8 when the frames are presented.
+	at []uint64
+}
+
+var _ core.Renderer = &Recorder{}
+
+func NewRecorder(chip *core.Chip8, shots *Screenshots) *Recorder {
+	return &Recorder{chip: chip, shots: shots}
+}
+
+// Render records f. It's called by Chip8 with the lock held.
+func (r *Recorder) Render(f *core.Frame) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.path == "" {
+		return
+	}
+	// Chip8 counts the frame after presenting it.
+	n := r.chip.Stats.Frames + 1
+	if last := len(r.at) - 1; r.at[last] >= n {
+		// rewinding doesn't count frames.
+		r.frames[last] = f
+		return
+	}
+	r.frames, r.at = append(r.frames, f), append(r.at, n)
+}
+
+// Recording tells whether r is started.
+func (r *Recorder) Recording() bool {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	return r.path != ""
+}
+
+// Start starts recording into path from the current frame.
+func (r *Recorder) Start(path string) {
+	r.chip.Lock()
+	defer r.chip.Unlock()
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.path = path
+	r.frames = []*core.Frame{r.chip.Display.(core.FrameHolder).Frame()}
+	r.at = []uint64{r.chip.Stats.Frames}
+}
+
+// Stop stops recording and writes the recorded frames. The format is APNG for .png and .apng and GIF otherwise.
+func (r *Recorder) Stop() (string, error) {
+	r.chip.Lock()
+	end := r.chip.Stats.Frames
+	r.chip.Unlock()
+	r.mux.Lock()
+	path, frames, at := r.path, r.frames, r.at
+	r.path, r.frames, r.at = "", nil, nil
+	r.mux.Unlock()
+	if path == "" {
+		return "", fmt.Errorf("not recording")
+	}
+	// the last frame lasts until the end.
+	at = append(at, end+1)
+
+	// frames of both resolutions fill the size of the largest one.
+	width := 0
+	for _, f := range frames {
+		if f.Width > width {
+			width = f.Width
+		}
+	}
+	images := make([]*image.Paletted, len(frames))
+	for i, f := range frames {
+		images[i] = scaledImage(f, r.shots.palette, r.shots.scale*width/f.Width)
+	}
+	out, e := os.Create(path)
+	if e != nil {
+		return path, e
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png", ".apng":
+		a := &apng.APNG{DelayDen: core.FrameRate}
+		for i, img := range images {
+			a.Image = append(a.Image, img)
+			a.Delay = append(a.Delay, int(at[i+1]-at[i]))
+		}
+		e = apng.EncodeAll(out, a)
+	default:
+		e = gif.EncodeAll(out, gifOf(images, at))
+	}
+	if e != nil {
+		out.Close()
+		return path, e
+	}
+	return path, out.Close()
+}
+
+// gifOf returns the animation of images presented at the frames at.
+// GIF's delays are in 1/100 second and players slow down delays below 2, so a frame shown shorter than that
+// is replaced by the next one.
+func gifOf(images []*image.Paletted, at []uint64) *gif.GIF {
+	centis := func(frame uint64) int {
+		return int((frame-at[0])*100+core.FrameRate/2) / core.FrameRate
+	}
+	g := &gif.GIF{}
+	var starts []int
+	for i, img := range images {
+		start := centis(at[i])
+		if last := len(g.Image) - 1; last >= 0 && start-starts[last] < 2 {
+			g.Image[last] = img
+			continue
+		}
+		g.Image, starts = append(g.Image, img), append(starts, start)
+	}
+	end := centis(at[len(at)-1])
+	for i := range starts {
+		next := end
+		if i+1 < len(starts) {
+			next = starts[i+1]
+		}
+		delay := next - starts[i]
+		if delay < 2 {
+			delay = 2
+		}
+		g.Delay = append(g.Delay, delay)
+	}
+	return g
+}
+
+// RecordHotkeys are F11 to start and stop recording into a new file named after the ROM and the time.
+func RecordHotkeys(r *Recorder) map[termbox.Key]func() {
+	return map[termbox.Key]func(){
+		termbox.KeyF11: func() {
+			if !r.Recording() {
+				s := r.shots
+				if e := os.MkdirAll(s.dir, 0o755); e != nil {
+					ShowStatus(fmt.Sprintf("record: %v", e))
+					return
+				}
+				r.Start(filepath.Join(s.dir, fmt.Sprintf("%s.%s.gif", s.rom, time.Now().Format("20060102-150405.000"))))
+				ShowStatus("recording")
+				return
+			}
+			path, e := r.Stop()
+			if e != nil {
+				ShowStatus(fmt.Sprintf("record: %v", e))
+				return
+			}
+			ShowStatus("recorded: " + path)
+		},
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/masu-mi/gochip-8/core"
+)
+
+// CrashReport writes registers, the call stack and memory around Pc of a stopped chip.
+func CrashReport(w io.Writer, chip *core.Chip8) {
+	cpu := chip.Cpu
+	fmt.Fprintln(w, "=== crash report ===")
+	for i, v := range cpu.V {
+		fmt.Fprintf(w, "V%X: 0x%02x", i, v)
+		if i%4 == 3 {
+			fmt.Fprintln(w)
+		} else {
+			fmt.Fprint(w, "  ")
+		}
+	}
+	fmt.Fprintf(w, "I: 0x%04x  Pc: 0x%04x  Sp: %d  DT: %d  ST: %d\n", cpu.I, cpu.Pc, cpu.Sp, cpu.Dt.GetV(), cpu.St.GetV())
+	fmt.Fprintln(w, "stack:")
+	for i := int(cpu.Sp) - 1; i >= 0; i-- {
+		fmt.Fprintf(w, "  #%d 0x%04x\n", i, cpu.Stack[i])
+	}
+	fmt.Fprintln(w, "memory:")
+	from := int(cpu.Pc) &^ 0xf
+	if from >= 0x10 {
+		from -= 0x10
+	}
+	to := from + 0x30
+	if to > len(chip.Memory.Buf) {
+		to = len(chip.Memory.Buf)
+	}
+	for p := from; p < to; p += 0x10 {
+		fmt.Fprintf(w, "  %04x:", p)
+		for i := p; i < p+0x10 && i < to; i++ {
+			mark := " "
+			if i == int(cpu.Pc) {
+				mark = ">"
+			}
+			fmt.Fprintf(w, "%s%02x", mark, chip.Memory.Buf[i])
+		}
+		fmt.Fprintln(w)
+	}
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"time"
+
+	"github.com/masu-mi/gochip-8/core"
+	"github.com/nsf/termbox-go"
+)
+
+// rewindHold is how long a press of the rewind key lasts.
+// It bridges the delay before the terminal repeats a held key.
+const rewindHold = 500 * time.Millisecond
+
+// RewindHotkeys rewind play while Backspace is held down.
+func RewindHotkeys(r *core.Rewinder) map[termbox.Key]func() {
+	hold := func() {
+		r.Hold(rewindHold)
+	}
+	return map[termbox.Key]func(){
+		termbox.KeyBackspace:  hold,
+		termbox.KeyBackspace2: hold,
+	}
+}
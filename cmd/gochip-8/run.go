@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/masu-mi/gochip-8/core"
+	"github.com/spf13/cobra"
+)
+
+var (
+	runCycles    uint64
+	runFrames    uint64
+	runUntilPC   string
+	runUntilIdle bool
+	runOutput    string
+	runOut       string
+	runOnFault   string
+)
+
+// exitTimeout is the exit status when --cycles or --frames runs out before --until-pc or --until-idle.
+const exitTimeout = 2
+
+func NewRunCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "run",
+		Short: "run a ROM without a terminal and print the final screen",
+		RunE:  runHeadless,
+	}
+	addDebugFlags(cmd)
+	cmd.PersistentFlags().Uint64Var(&runCycles, "cycles", 0, "instructions to execute (0: unlimited)")
+	cmd.PersistentFlags().Uint64Var(&runFrames, "frames", 0, "60Hz frames to execute (0: unlimited)")
+	cmd.PersistentFlags().StringVar(&runUntilPC, "until-pc", "", "stop before the instruction at the address, e.g. 0x3dc")
+	cmd.PersistentFlags().BoolVar(&runUntilIdle, "until-idle", false, "stop when the program jumps to itself or waits for a key")
+	cmd.PersistentFlags().StringVar(&runOutput, "output", "text", "format of the final screen (text, png)")
+	cmd.PersistentFlags().StringVarP(&runOut, "out", "o", "", "file of the final screen (default: stdout for text)")
+	cmd.PersistentFlags().StringVar(&runOnFault, "on-fault", core.FaultHalt.String(), "what to do on a faulting instruction (halt, skip)")
+	return cmd
+}
+
+// runHeadless exits with 0 when the program exits or a condition is met, 1 on a fault and exitTimeout
+// when the limits run out before the conditions of --until-pc and --until-idle.
+func runHeadless(cmd *cobra.Command, args []string) error {
+	until := core.Until{Cycles: runCycles, Frames: runFrames, Idle: runUntilIdle}
+	if runUntilPC != "" {
+		pc, e := strconv.ParseUint(runUntilPC, 0, 16)
+		if e != nil || pc == 0 {
+			return fmt.Errorf("invalid address `%s`", runUntilPC)
+		}
+		until.PC = uint16(pc)
+	}
+	if until == (core.Until{}) {
+		return fmt.Errorf("no condition to stop: give --cycles, --frames, --until-pc or --until-idle")
+	}
+	if runOutput != "text" && runOutput != "png" {
+		return fmt.Errorf("unknown output `%s` (available: text, png)", runOutput)
+	}
+	if runOutput == "png" && runOut == "" {
+		return fmt.Errorf("png needs --out")
+	}
+	policy, e := core.ParseFaultPolicy(runOnFault)
+	if e != nil {
+		return e
+	}
+	if policy == core.FaultTrap {
+		return fmt.Errorf("fault policy `%s` needs a debugger", policy)
+	}
+	chip, _, e := newDebugChip(cmd)
+	if e != nil {
+		return e
+	}
+	defer chip.Close()
+	chip.Cpu.FaultPolicy = policy
+	cmd.SilenceUsage, cmd.SilenceErrors = true, true
+
+	reason, fault := chip.RunUntil(until)
+	fmt.Fprintf(cmd.ErrOrStderr(), "stop: %s at 0x%03x after %d instructions, %d frames\n",
+		reason, chip.Cpu.Pc, chip.Stats.Instructions, chip.Stats.Frames)
+	if e := writeScreen(cmd.OutOrStdout(), chip.Display.(core.FrameHolder).Frame()); e != nil {
+		return e
+	}
+	if fault != nil {
+		CrashReport(cmd.ErrOrStderr(), chip)
+		return &exitError{Code: 1, Err: fault}
+	}
+	if (until.PC != 0 || until.Idle) && (reason == core.StopCycles || reason == core.StopFrames) {
+		return &exitError{Code: exitTimeout, Err: fmt.Errorf("timeout: %s ran out", reason)}
+	}
+	return nil
+}
+
+// writeScreen writes f in the format of --output to --out or stdout.
+func writeScreen(stdout io.Writer, f *core.Frame) error {
+	if runOut == "" {
+		_, e := io.WriteString(stdout, f.String())
+		return e
+	}
+	if runOutput == "png" {
+		return writePNG(runOut, frameImage(f))
+	}
+	return os.WriteFile(runOut, []byte(f.String()), 0644)
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"image"
+	imagecolor "image/color"
+	"image/png"
+	"io"
+	"os"
+
+	"github.com/masu-mi/gochip-8/core"
+)
+
+// framePalette are the colours of pixels indexed by the bits of the planes, as the characters of Frame.String.
+var framePalette = imagecolor.Palette{
+	imagecolor.Gray{Y: 0x00},
+	imagecolor.Gray{Y: 0xff},
+	imagecolor.Gray{Y: 0xaa},
+	imagecolor.Gray{Y: 0x55},
+}
+
+// frameImage returns f as an image of a pixel per pixel in framePalette.
+func frameImage(f *core.Frame) image.Image {
+	return scaledImage(f, framePalette, 1)
+}
+
+// scaledImage returns f as an image of scale x scale pixels per pixel coloured by palette
+// indexed by the bits of the planes.
+func scaledImage(f *core.Frame, palette imagecolor.Palette, scale int) *image.Paletted {
+	img := image.NewPaletted(image.Rect(0, 0, f.Width*scale, f.Height*scale), palette)
+	for y := 0; y < f.Height*scale; y++ {
+		for x := 0; x < f.Width*scale; x++ {
+			img.SetColorIndex(x, y, f.At(x/scale, y/scale)&3)
+		}
+	}
+	return img
+}
+
+// writePNG writes img to path.
+func writePNG(path string, img image.Image) error {
+	f, e := os.Create(path)
+	if e != nil {
+		return e
+	}
+	if e := png.Encode(f, img); e != nil {
+		f.Close()
+		return e
+	}
+	return f.Close()
+}
+
+// createOutput creates the file of path, or returns stdout for "-".
+func createOutput(path string) (io.WriteCloser, error) {
+	if path == "-" {
+		return nopCloser{os.Stdout}, nil
+	}
+	return os.Create(path)
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+// readPNG reads the image of path.
+func readPNG(path string) (image.Image, error) {
+	f, e := os.Open(path)
+	if e != nil {
+		return nil, e
+	}
+	defer f.Close()
+	return png.Decode(f)
+}
+
+// diffPalette colours the pixels of diffImage.
+var diffPalette = imagecolor.Palette{
+	imagecolor.Gray{Y: 0x00},                   // off in both
+	imagecolor.Gray{Y: 0x60},                   // the same colour in both
+	imagecolor.RGBA{R: 0xff, A: 0xff},          // only in the expected image
+	imagecolor.RGBA{G: 0xff, A: 0xff},          // only in the actual frame
+	imagecolor.RGBA{R: 0xff, G: 0xff, A: 0xff}, // other colours in both
+}
+
+// diffImage compares f with the expected image in framePalette and returns the number of differing pixels
+// and the image of the differences. Pixels out of either of them are off.
+func diffImage(expected image.Image, f *core.Frame) (int, image.Image) {
+	b := expected.Bounds()
+	w, h := f.Width, f.Height
+	if b.Dx() > w {
+		w = b.Dx()
+	}
+	if b.Dy() > h {
+		h = b.Dy()
+	}
+	img := image.NewPaletted(image.Rect(0, 0, w, h), diffPalette)
+	n := 0
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var want, got uint8
+			if x < b.Dx() && y < b.Dy() {
+				want = uint8(framePalette.Index(expected.At(b.Min.X+x, b.Min.Y+y)))
+			}
+			if x < f.Width && y < f.Height {
+				got = f.At(x, y) & 3
+			}
+			var c uint8
+			switch {
+			case want == got && want == 0:
+				c = 0
+			case want == got:
+				c = 1
+			case got == 0:
+				c = 2
+			case want == 0:
+				c = 3
+			default:
+				c = 4
+			}
+			if want != got {
+				n++
+			}
+			img.SetColorIndex(x, y, c)
+		}
+	}
+	return n, img
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	imagecolor "image/color"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/masu-mi/gochip-8/core"
+	"github.com/nsf/termbox-go"
+)
+
+// Screenshots write frames of a ROM to PNG files.
+type Screenshots struct {
+	dir     string
+	rom     string
+	scale   int
+	palette imagecolor.Palette
+}
+
+// NewScreenshots returns Screenshots of scale x scale pixels per pixel in fg on bg.
+// The second plane and both planes of XO-CHIP are in the grays of framePalette.
+func NewScreenshots(dir, romPath string, scale int, fg, bg string) (*Screenshots, error) {
+	if scale < 1 {
+		return nil, fmt.Errorf("screenshot scale must be positive: %d", scale)
+	}
+	f, e := parseColor(fg)
+	if e != nil {
+		return nil, e
+	}
+	b, e := parseColor(bg)
+	if e != nil {
+		return nil, e
+	}
+	return &Screenshots{
+		dir:     dir,
+		rom:     filepath.Base(romPath),
+		scale:   scale,
+		palette: imagecolor.Palette{b, f, framePalette[2], framePalette[3]},
+	}, nil
+}
+
+// parseColor parses a colour written as #rrggbb.
+func parseColor(s string) (imagecolor.Color, error) {
+	hex := strings.TrimPrefix(s, "#")
+	v, e := strconv.ParseUint(hex, 16, 32)
+	if e != nil || len(hex) != 6 {
+		return nil, fmt.Errorf("invalid colour `%s` (expected #rrggbb)", s)
+	}
+	return imagecolor.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 0xff}, nil
+}
+
+// Write writes f to path.
+func (s *Screenshots) Write(path string, f *core.Frame) error {
+	return writePNG(path, scaledImage(f, s.palette, s.scale))
+}
+
+// Take writes the current frame of chip to a new file named after the ROM and the time in dir.
+func (s *Screenshots) Take(chip *core.Chip8) (string, error) {
+	chip.Lock()
+	f := chip.Display.(core.FrameHolder).Frame()
+	chip.Unlock()
+	if e := os.MkdirAll(s.dir, 0o755); e != nil {
+		return "", e
+	}
+	path := filepath.Join(s.dir, fmt.Sprintf("%s.%s.png", s.rom, time.Now().Format("20060102-150405.000")))
+	return path, s.Write(path, f)
+}
+
+// ScreenshotHotkeys are F12 to take a screenshot.
+func ScreenshotHotkeys(chip *core.Chip8, s *Screenshots) map[termbox.Key]func() {
+	return map[termbox.Key]func(){
+		termbox.KeyF12: func() {
+			path, e := s.Take(chip)
+			if e != nil {
+				ShowStatus(fmt.Sprintf("screenshot: %v", e))
+				return
+			}
+			ShowStatus("screenshot: " + path)
+		},
+	}
+}
@@ -3,9 +3,10 @@ package main
 import (
 	"context"
 	"fmt"
+	"io"
 	"log"
 	"os"
-	"time"
+	"strings"
 
 	"github.com/masu-mi/gochip-8/core"
 	"github.com/mattn/go-tty"
@@ -15,9 +16,29 @@ import (
 
 var (
 	cpuHz      int
+	ipf        int
 	fps        uint8
 	path       string
 	blockColor int64
+	quirks     string
+
+	xoChip       bool
+	plane2Color  int64
+	overlapColor int64
+	onFault      string
+	stateDir     string
+	rewindSecs   int
+	panes        bool
+	traceFile    string
+
+	screenshotDir    string
+	screenshotOnExit string
+	screenshotScale  int
+	screenshotFg     string
+	screenshotBg     string
+	recordPath       string
+	y4mPath          string
+	wavPath          string
 )
 
 func NewStartCommand() *cobra.Command {
@@ -26,14 +47,56 @@ func NewStartCommand() *cobra.Command {
 		Short: "start CHIP-8 emulator",
 		RunE:  start,
 	}
-	cmd.PersistentFlags().IntVar(&cpuHz, "cpu-hz", 1000000, "reciprocal of duration of key pressed (default: 1MHz)")
+	cmd.PersistentFlags().IntVar(&cpuHz, "cpu-hz", core.DefaultIPF*core.FrameRate, "instructions per second, rounded to instructions per frame")
+	cmd.PersistentFlags().IntVar(&ipf, "ipf", core.DefaultIPF, "instructions per 60Hz frame (overrides --cpu-hz)")
 	cmd.PersistentFlags().Uint8Var(&fps, "keyboard-hz", 10, "reciprocal of duration of key pressed (default: 10Hz)")
 	cmd.PersistentFlags().StringVar(&path, "rom", "", "rom image file path")
 	cmd.PersistentFlags().Int64Var(&blockColor, "color", 16, "display active cell's color(defalt: 16)")
+	cmd.PersistentFlags().StringVar(&quirks, "quirks", core.DefaultQuirks, fmt.Sprintf("quirk profile (%s)", strings.Join(core.QuirkPresetNames(), ", ")))
+	cmd.PersistentFlags().BoolVar(&xoChip, "xo-chip", false, "enable XO-CHIP's 64KB memory (quirks default to xo-chip)")
+	cmd.PersistentFlags().Int64Var(&plane2Color, "plane2-color", 2, "XO-CHIP's second plane's color")
+	cmd.PersistentFlags().Int64Var(&overlapColor, "overlap-color", 4, "XO-CHIP's color of cells active in both planes")
+	cmd.PersistentFlags().StringVar(&stateDir, "state-dir", "", "directory of save state slots (default: gochip-8/states in the user cache directory)")
+	cmd.PersistentFlags().IntVar(&rewindSecs, "rewind-seconds", 10, "seconds of play kept to rewind with [Backspace] (0 disables rewinding)")
+	cmd.PersistentFlags().BoolVar(&panes, "panes", false, "show registers, disassembly, memory and keys next to the screen")
+	cmd.PersistentFlags().StringVar(&traceFile, "trace-file", "", "file receiving the instruction trace of builds with the debug tag (default: discarded)")
+	cmd.PersistentFlags().StringVar(&onFault, "on-fault", core.FaultHalt.String(), "what to do on a faulting instruction (halt, skip)")
+	cmd.PersistentFlags().StringVar(&screenshotDir, "screenshot-dir", ".", "directory of the screenshots taken with [F12]")
+	cmd.PersistentFlags().StringVar(&screenshotOnExit, "screenshot-on-exit", "", "write the last frame to the PNG file on exit")
+	cmd.PersistentFlags().IntVar(&screenshotScale, "screenshot-scale", 8, "pixels of screenshots per pixel")
+	cmd.PersistentFlags().StringVar(&screenshotFg, "screenshot-fg", "#ffffff", "foreground colour of screenshots")
+	cmd.PersistentFlags().StringVar(&screenshotBg, "screenshot-bg", "#000000", "background colour of screenshots")
+	cmd.PersistentFlags().StringVar(&recordPath, "record", "", "record the play into the animated GIF (or APNG for .png and .apng) until exit")
+	cmd.PersistentFlags().StringVar(&y4mPath, "y4m", "", "write every frame into the YUV4MPEG2 file (- for stdout) in the screenshot's colours and scale")
+	cmd.PersistentFlags().StringVar(&wavPath, "wav", "", "write the buzzer's tone of every frame into the WAV file")
 	return cmd
 }
 
-func start(_ *cobra.Command, args []string) error {
+func start(cmd *cobra.Command, args []string) error {
+	memSize := core.MemorySize
+	if xoChip {
+		memSize = core.XOMemorySize
+		if !cmd.Flags().Changed("quirks") {
+			quirks = "xo-chip"
+		}
+	}
+	q, e := core.LookupQuirks(quirks)
+	if e != nil {
+		return e
+	}
+	if !cmd.Flags().Changed("ipf") && cmd.Flags().Changed("cpu-hz") {
+		ipf = cpuHz / core.FrameRate
+	}
+	if ipf < 1 {
+		return fmt.Errorf("instructions per frame must be positive: %d", ipf)
+	}
+	policy, e := core.ParseFaultPolicy(onFault)
+	if e != nil {
+		return e
+	}
+	if policy == core.FaultTrap {
+		return fmt.Errorf("fault policy `%s` needs a debugger", policy)
+	}
 	f, e := os.Open(path)
 	if e != nil {
 		log.Fatalf("can't open `%s`\n", path)
@@ -41,12 +104,7 @@ func start(_ *cobra.Command, args []string) error {
 	defer f.Close()
 
 	tty, _ := tty.Open()
-	defer func() {
-		if v := recover(); v != nil {
-			fmt.Printf("panic: %v\n", v)
-		}
-		tty.Close()
-	}()
+	defer tty.Close()
 	forKeys := make(chan rune)
 	forRepl := make(chan rune)
 	go func() {
@@ -61,21 +119,122 @@ func start(_ *cobra.Command, args []string) error {
 			forRepl <- r
 		}
 	}()
-	ctx, dsp, kb, e := StarTermbox(context.Background(), termbox.Attribute(blockColor))
+	fb := core.NewFrameBuffer()
+	chip := &core.Chip8{
+		Cpu:     core.NewCpu(nil, nil),
+		Memory:  core.NewMemory(memSize),
+		Display: fb,
+		IPF:     ipf,
+	}
+	defer chip.Close()
+	chip.Cpu.Quirks = q
+	chip.Cpu.FaultPolicy = policy
+	_, e = chip.Init(f)
+	if e != nil {
+		log.Fatalln(e)
+	}
+	slots, e := NewStateSlots(stateDir, path)
+	if e != nil {
+		return e
+	}
+
+	palette := [4]termbox.Attribute{
+		termbox.ColorDefault,
+		termbox.Attribute(blockColor),
+		termbox.Attribute(plane2Color),
+		termbox.Attribute(overlapColor),
+	}
+	shots, e := NewScreenshots(screenshotDir, path, screenshotScale, screenshotFg, screenshotBg)
+	if e != nil {
+		return e
+	}
+	hotkeys := StateHotkeys(chip, slots)
+	for k, f := range ScreenshotHotkeys(chip, shots) {
+		hotkeys[k] = f
+	}
+	recorder := NewRecorder(chip, shots)
+	for k, f := range RecordHotkeys(recorder) {
+		hotkeys[k] = f
+	}
+	if rewindSecs > 0 {
+		chip.Rewinder = core.NewRewinder(rewindSecs * core.FrameRate)
+		for k, f := range RewindHotkeys(chip.Rewinder) {
+			hotkeys[k] = f
+		}
+	}
+	// the trace would corrupt the screen of termbox.
+	core.TraceOutput = io.Discard
+	if traceFile != "" {
+		t, e := os.Create(traceFile)
+		if e != nil {
+			return e
+		}
+		defer t.Close()
+		core.TraceOutput = t
+	}
+	// the exports are opened before termbox, which has to be closed on their errors otherwise.
+	if y4mPath != "" {
+		out, e := createOutput(y4mPath)
+		if e != nil {
+			return e
+		}
+		defer out.Close()
+		video := NewY4MRenderer(out, shots)
+		defer closeExport("y4m", video)
+		fb.AddFrameRenderer(video)
+	}
+	if wavPath != "" {
+		out, e := createOutput(wavPath)
+		if e != nil {
+			return e
+		}
+		defer out.Close()
+		audio := NewWAVBuzzer(out)
+		defer closeExport("wav", audio)
+		chip.Lock()
+		chip.SetBuzzer(audio)
+		chip.Unlock()
+		fb.AddFrameRenderer(audio)
+	}
+	ctx, screen, kb, e := StarTermbox(context.Background(), palette, hotkeys)
 	if e != nil {
 		fmt.Println(e)
 		os.Exit(1)
 	}
-	chip := &core.Chip8{
-		Cpu:      core.NewCpu(time.NewTicker(time.Second/time.Duration(cpuHz)), nil),
-		Memory:   &core.Memory{},
-		Display:  dsp,
-		Keyboard: kb,
+	chip.Lock()
+	chip.Keyboard = kb
+	chip.Unlock()
+	fb.AddRenderer(screen)
+	fb.AddRenderer(recorder)
+	if recordPath != "" {
+		recorder.Start(recordPath)
 	}
-	_, e = chip.Init(f)
+	if panes {
+		go NewPanes(chip, termbox.Attribute(blockColor)).Run(ctx)
+	}
+	e = chip.Run(ctx)
+	closeTermbox()
 	if e != nil {
-		log.Fatalln(e)
+		CrashReport(os.Stderr, chip)
+		return e
+	}
+	if screenshotOnExit != "" {
+		if e := shots.Write(screenshotOnExit, fb.Frame()); e != nil {
+			fmt.Fprintf(os.Stderr, "screenshot: %v\n", e)
+		}
+	}
+	if recorder.Recording() {
+		if path, e := recorder.Stop(); e != nil {
+			fmt.Fprintf(os.Stderr, "record: %s: %v\n", path, e)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "%d instructions in %d frames (%.0f instructions/s)\n", chip.Stats.Instructions, chip.Stats.Frames, chip.Stats.IPS())
+	return screen.Err()
+}
+
+// closeExport closes the stream of an export and reports its error.
+func closeExport(name string, c io.Closer) {
+	if e := c.Close(); e != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, e)
 	}
-	chip.Run(ctx)
-	return nil
 }
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/masu-mi/gochip-8/core"
+	"github.com/nsf/termbox-go"
+)
+
+// StateSlots saves and loads states of a ROM into numbered files in dir.
+type StateSlots struct {
+	sync.Mutex
+	dir  string
+	rom  string
+	slot int
+}
+
+const numOfSlots = 10
+
+func NewStateSlots(dir, romPath string) (*StateSlots, error) {
+	if dir == "" {
+		cache, e := os.UserCacheDir()
+		if e != nil {
+			return nil, e
+		}
+		dir = filepath.Join(cache, "gochip-8", "states")
+	}
+	return &StateSlots{dir: dir, rom: filepath.Base(romPath)}, nil
+}
+
+func (s *StateSlots) path(slot int) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%s.%d.state", s.rom, slot))
+}
+
+// Select moves the current slot by d and returns it.
+func (s *StateSlots) Select(d int) int {
+	s.Lock()
+	defer s.Unlock()
+	s.slot = (s.slot + d + numOfSlots) % numOfSlots
+	return s.slot
+}
+
+// Save writes the state of chip into the current slot. chip must not be running a frame.
+func (s *StateSlots) Save(chip *core.Chip8) (int, error) {
+	s.Lock()
+	defer s.Unlock()
+	if e := os.MkdirAll(s.dir, 0o755); e != nil {
+		return s.slot, e
+	}
+	f, e := os.Create(s.path(s.slot))
+	if e != nil {
+		return s.slot, e
+	}
+	if e := chip.SaveState(f); e != nil {
+		f.Close()
+		return s.slot, e
+	}
+	return s.slot, f.Close()
+}
+
+// Load restores chip from the current slot. chip must not be running a frame.
+func (s *StateSlots) Load(chip *core.Chip8) (int, error) {
+	s.Lock()
+	defer s.Unlock()
+	f, e := os.Open(s.path(s.slot))
+	if e != nil {
+		return s.slot, e
+	}
+	defer f.Close()
+	return s.slot, chip.LoadState(f)
+}
+
+// StateHotkeys are F5 to save, F9 to load, and F6/F7 to select the previous/next slot.
+func StateHotkeys(chip *core.Chip8, slots *StateSlots) map[termbox.Key]func() {
+	return map[termbox.Key]func(){
+		termbox.KeyF5: func() {
+			chip.Lock()
+			n, e := slots.Save(chip)
+			chip.Unlock()
+			showResult("saved", n, e)
+		},
+		termbox.KeyF9: func() {
+			chip.Lock()
+			n, e := slots.Load(chip)
+			chip.Unlock()
+			showResult("loaded", n, e)
+		},
+		termbox.KeyF6: func() {
+			ShowStatus(fmt.Sprintf("slot %d", slots.Select(-1)))
+		},
+		termbox.KeyF7: func() {
+			ShowStatus(fmt.Sprintf("slot %d", slots.Select(1)))
+		},
+	}
+}
+
+func showResult(done string, slot int, e error) {
+	if e != nil {
+		ShowStatus(fmt.Sprintf("slot %d: %v", slot, e))
+		return
+	}
+	ShowStatus(fmt.Sprintf("slot %d: %s", slot, done))
+}
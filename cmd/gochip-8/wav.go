@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"math"
+	"sync"
+
+	"github.com/masu-mi/gochip-8/core"
+)
+
+const (
+	sampleRate      = 44100
+	samplesPerFrame = sampleRate / core.FrameRate
+	// toneHz is the tone of the buzzer while no XO-CHIP's pattern is loaded.
+	toneHz    = 440
+	amplitude = 8000
+)
+
+// WAVBuzzer is a Buzzer writing its tone into a 16-bit mono PCM WAV stream. It's a renderer of every frame
+// to write the samples of a frame at a time, so that the stream runs in step with the video of the same frames.
+// A frame sounds when the buzzer is on at any time in it.
+//
+// > ref. http://soundfile.sapp.org/doc/WaveFormat/
+type WAVBuzzer struct {
+	mux sync.Mutex
+	out io.Writer
+	w   *bufio.Writer
+
+	on      bool
+	beeped  bool
+	pattern [16]uint8
+	loaded  bool
+	pitch   uint8
+	// phase is the position in the tone or the pattern, in cycles or in bits.
+	phase float64
+
+	samples uint32
+	started bool
+	err     error
+}
+
+var (
+	_ core.AudioBuzzer = &WAVBuzzer{}
+	_ core.Renderer    = &WAVBuzzer{}
+)
+
+func NewWAVBuzzer(w io.Writer) *WAVBuzzer {
+	return &WAVBuzzer{out: w, w: bufio.NewWriter(w), pitch: 64}
+}
+
+func (b *WAVBuzzer) Start() {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.on, b.beeped = true, true
+}
+
+func (b *WAVBuzzer) Stop() {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.on = false
+}
+
+func (b *WAVBuzzer) SetPattern(pattern [16]uint8) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.pattern, b.loaded = pattern, true
+}
+
+func (b *WAVBuzzer) SetPitch(pitch uint8) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	b.pitch = pitch
+}
+
+// header writes the header of data of size bytes.
+func (b *WAVBuzzer) header(w io.Writer, size uint32) error {
+	h := make([]byte, 44)
+	copy(h[0:], "RIFF")
+	binary.LittleEndian.PutUint32(h[4:], 36+size)
+	copy(h[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(h[16:], 16)
+	binary.LittleEndian.PutUint16(h[20:], 1) // PCM
+	binary.LittleEndian.PutUint16(h[22:], 1) // mono
+	binary.LittleEndian.PutUint32(h[24:], sampleRate)
+	binary.LittleEndian.PutUint32(h[28:], sampleRate*2)
+	binary.LittleEndian.PutUint16(h[32:], 2)
+	binary.LittleEndian.PutUint16(h[34:], 16)
+	copy(h[36:], "data")
+	binary.LittleEndian.PutUint32(h[40:], size)
+	_, e := w.Write(h)
+	return e
+}
+
+// Render writes the samples of a frame. The first error is kept for Close.
+func (b *WAVBuzzer) Render(*core.Frame) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	if b.err != nil {
+		return
+	}
+	if !b.started {
+		b.started = true
+		// the sizes are written by Close when the stream can seek, and are the largest otherwise.
+		if b.err = b.header(b.w, math.MaxUint32-36); b.err != nil {
+			return
+		}
+	}
+	sounding := b.on || b.beeped
+	b.beeped = false
+	// XO-CHIP plays the 128 bits of the pattern at 4000*2^((pitch-64)/48) bits per second.
+	step := float64(toneHz) / sampleRate
+	if b.loaded {
+		step = 4000 * math.Pow(2, (float64(b.pitch)-64)/48) / sampleRate
+	}
+	buf := make([]byte, 2*samplesPerFrame)
+	for i := 0; i < samplesPerFrame; i++ {
+		var v int16
+		if sounding {
+			var high bool
+			if b.loaded {
+				bit := int(b.phase) % 128
+				high = b.pattern[bit/8]&(0x80>>(bit%8)) != 0
+			} else {
+				high = b.phase-math.Floor(b.phase) < 0.5
+			}
+			v = -amplitude
+			if high {
+				v = amplitude
+			}
+			b.phase += step
+		}
+		binary.LittleEndian.PutUint16(buf[2*i:], uint16(v))
+	}
+	if b.loaded {
+		b.phase = math.Mod(b.phase, 128)
+	} else {
+		b.phase -= math.Floor(b.phase)
+	}
+	_, b.err = b.w.Write(buf)
+	b.samples += samplesPerFrame
+}
+
+// Close flushes the stream and writes the sizes into the header when the stream can seek.
+// It returns the first error of writing the stream.
+func (b *WAVBuzzer) Close() error {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+	if b.err != nil {
+		return b.err
+	}
+	if !b.started {
+		return b.header(b.out, 0)
+	}
+	if e := b.w.Flush(); e != nil {
+		return e
+	}
+	s, ok := b.out.(io.WriteSeeker)
+	if !ok {
+		return nil
+	}
+	if _, e := s.Seek(0, io.SeekStart); e != nil {
+		// pipes can't seek.
+		return nil
+	}
+	if e := b.header(s, 2*b.samples); e != nil {
+		return e
+	}
+	_, e := s.Seek(0, io.SeekEnd)
+	return e
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/masu-mi/gochip-8/core"
+)
+
+// Y4MRenderer writes every frame into a YUV4MPEG2 stream of 60 frames per second in the colours and the scale of Screenshots.
+// The video is as large as the high resolution, where a pixel of the low resolution is twice as large.
+//
+// > ref. https://wiki.multimedia.cx/index.php/YUV4MPEG2
+type Y4MRenderer struct {
+	mux    sync.Mutex
+	w      *bufio.Writer
+	width  int
+	height int
+	// colours are Y, Cb and Cr of the palette.
+	colours [4][3]uint8
+	planes  []byte
+	started bool
+	err     error
+}
+
+var _ core.Renderer = &Y4MRenderer{}
+
+func NewY4MRenderer(w io.Writer, shots *Screenshots) *Y4MRenderer {
+	r := &Y4MRenderer{
+		w:      bufio.NewWriter(w),
+		width:  core.HIRES_WIDTH * shots.scale,
+		height: core.HIRES_HEIGHT * shots.scale,
+	}
+	for i, c := range shots.palette {
+		r.colours[i] = ycbcr(c.RGBA())
+	}
+	r.planes = make([]byte, 3*r.width*r.height)
+	return r
+}
+
+// ycbcr converts a colour into the limited range of BT.601, which players assume for Y4M.
+func ycbcr(r, g, b, _ uint32) [3]uint8 {
+	fr, fg, fb := float64(r>>8), float64(g>>8), float64(b>>8)
+	return [3]uint8{
+		uint8(16 + 0.257*fr + 0.504*fg + 0.098*fb + 0.5),
+		uint8(128 - 0.148*fr - 0.291*fg + 0.439*fb + 0.5),
+		uint8(128 + 0.439*fr - 0.368*fg - 0.071*fb + 0.5),
+	}
+}
+
+// Render writes f as a frame. The first error is kept for Close.
+func (r *Y4MRenderer) Render(f *core.Frame) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.err != nil {
+		return
+	}
+	if !r.started {
+		r.started = true
+		_, r.err = fmt.Fprintf(r.w, "YUV4MPEG2 W%d H%d F%d:1 Ip A1:1 C444 XCOLORRANGE=LIMITED\n", r.width, r.height, core.FrameRate)
+	}
+	n := r.width * r.height
+	for y := 0; y < r.height; y++ {
+		for x := 0; x < r.width; x++ {
+			c := r.colours[f.At(x*f.Width/r.width, y*f.Height/r.height)&3]
+			i := y*r.width + x
+			r.planes[i], r.planes[n+i], r.planes[2*n+i] = c[0], c[1], c[2]
+		}
+	}
+	if _, e := io.WriteString(r.w, "FRAME\n"); e != nil {
+		r.err = e
+		return
+	}
+	_, r.err = r.w.Write(r.planes)
+}
+
+// Close flushes the stream and returns the first error of writing it.
+func (r *Y4MRenderer) Close() error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.err != nil {
+		return r.err
+	}
+	return r.w.Flush()
+}
@@ -0,0 +1,170 @@
+package core
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock drives DelayedTimer. Subscribers are called on every tick of the clock.
+//
+// Cpu reports every instruction through Cycle and Chip8 reports the end of every frame through Frame,
+// so a clock can tick in step with the emulated machine instead of the wall clock.
+type Clock interface {
+	Subscribe(f func()) (unsubscribe func())
+	Cycle()
+	Frame()
+}
+
+// subscribers is the registry of callbacks shared by the clocks.
+type subscribers struct {
+	mux  sync.Mutex
+	next int
+	fs   map[int]func()
+}
+
+func (s *subscribers) Subscribe(f func()) func() {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	if s.fs == nil {
+		s.fs = map[int]func(){}
+	}
+	id := s.next
+	s.next++
+	s.fs[id] = f
+	return func() {
+		s.mux.Lock()
+		defer s.mux.Unlock()
+		delete(s.fs, id)
+	}
+}
+
+func (s *subscribers) tick() {
+	s.mux.Lock()
+	fs := make([]func(), 0, len(s.fs))
+	for _, f := range s.fs {
+		fs = append(fs, f)
+	}
+	s.mux.Unlock()
+	for _, f := range fs {
+		f()
+	}
+}
+
+// FrameClock ticks at the end of every frame run by Chip8, i.e. at FrameRate while Run runs.
+// Timers stop while no frame runs, e.g. while a debugger pauses the machine.
+type FrameClock struct {
+	subscribers
+}
+
+func NewFrameClock() *FrameClock {
+	return &FrameClock{}
+}
+
+func (c *FrameClock) Cycle() {}
+func (c *FrameClock) Frame() {
+	c.tick()
+}
+
+// ManualClock ticks only when Tick is called. Tests tick it by hand.
+type ManualClock struct {
+	subscribers
+}
+
+func NewManualClock() *ManualClock {
+	return &ManualClock{}
+}
+
+func (c *ManualClock) Tick() {
+	c.tick()
+}
+func (c *ManualClock) Cycle() {}
+func (c *ManualClock) Frame() {}
+
+// CycleClock ticks every PerTick instructions executed by Cpu.
+// Timers stop while the CPU is stopped, e.g. in a debugger.
+type CycleClock struct {
+	subscribers
+	PerTick int
+
+	cycles int
+}
+
+func NewCycleClock(perTick int) *CycleClock {
+	return &CycleClock{PerTick: perTick}
+}
+
+func (c *CycleClock) Cycle() {
+	c.cycles++
+	if c.cycles >= c.PerTick {
+		c.cycles = 0
+		c.tick()
+	}
+}
+func (c *CycleClock) Frame() {}
+
+// RealClock ticks at hz on the wall clock until Stop is called.
+// The owner pauses it while the machine is paused.
+type RealClock struct {
+	subscribers
+	ticker *time.Ticker
+	done   chan struct{}
+	once   sync.Once
+
+	pmux   sync.Mutex
+	paused bool
+}
+
+func NewRealClock(hz uint) *RealClock {
+	c := &RealClock{
+		ticker: time.NewTicker(time.Second / time.Duration(hz)),
+		done:   make(chan struct{}),
+	}
+	go func() {
+		for {
+			select {
+			case <-c.ticker.C:
+				if !c.Paused() {
+					c.tick()
+				}
+			case <-c.done:
+				return
+			}
+		}
+	}()
+	return c
+}
+
+func (c *RealClock) Cycle() {}
+func (c *RealClock) Frame() {}
+
+// Pause stops ticking until Resume is called.
+func (c *RealClock) Pause() {
+	c.pmux.Lock()
+	defer c.pmux.Unlock()
+	c.paused = true
+}
+func (c *RealClock) Resume() {
+	c.pmux.Lock()
+	defer c.pmux.Unlock()
+	c.paused = false
+}
+func (c *RealClock) Paused() bool {
+	c.pmux.Lock()
+	defer c.pmux.Unlock()
+	return c.paused
+}
+
+// Stop releases the goroutine of the clock. It may be called more than once.
+func (c *RealClock) Stop() {
+	c.once.Do(func() {
+		c.ticker.Stop()
+		close(c.done)
+	})
+}
+
+var (
+	_ Clock = &FrameClock{}
+	_ Clock = &ManualClock{}
+	_ Clock = &CycleClock{}
+	_ Clock = &RealClock{}
+)
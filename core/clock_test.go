@@ -0,0 +1,104 @@
+package core
+
+import (
+	"testing"
+	"time"
+)
+
+type countingBuzzer struct {
+	starts, stops int
+}
+
+func (b *countingBuzzer) Start() { b.starts++ }
+func (b *countingBuzzer) Stop()  { b.stops++ }
+
+func TestManualClockTimers(t *testing.T) {
+	clock := NewManualClock()
+	buz := &countingBuzzer{}
+	cpu := NewCpu(clock, buz)
+	defer cpu.Close()
+	cpu.Dt.SetV(3)
+	cpu.St.SetV(2)
+	if buz.starts != 1 {
+		t.Fatalf("buzzer started %d times; want 1", buz.starts)
+	}
+
+	// the timers stand still while nothing ticks the clock, e.g. while the machine is paused.
+	for i := 0; i < 10; i++ {
+		cpu.Clock.Cycle()
+	}
+	if dt, st := cpu.Dt.GetV(), cpu.St.GetV(); dt != 3 || st != 2 {
+		t.Fatalf("DT, ST = %d, %d while paused; want 3, 2", dt, st)
+	}
+
+	for i, want := range []struct{ dt, st uint8 }{{2, 1}, {1, 0}, {0, 0}, {0, 0}} {
+		clock.Tick()
+		if dt, st := cpu.Dt.GetV(), cpu.St.GetV(); dt != want.dt || st != want.st {
+			t.Errorf("tick %d: DT, ST = %d, %d; want %d, %d", i+1, dt, st, want.dt, want.st)
+		}
+	}
+	if buz.stops != 1 {
+		t.Errorf("buzzer stopped %d times; want 1", buz.stops)
+	}
+}
+
+func TestCycleClockTicksPerInstructions(t *testing.T) {
+	clock := NewCycleClock(4)
+	cpu := NewCpu(clock, nil)
+	defer cpu.Close()
+	cpu.Dt.SetV(10)
+	for i := 0; i < 9; i++ {
+		clock.Cycle()
+	}
+	clock.Frame()
+	if dt := cpu.Dt.GetV(); dt != 8 {
+		t.Errorf("DT = %d after 9 cycles of 4 per tick; want 8", dt)
+	}
+}
+
+func TestClosedTimerStops(t *testing.T) {
+	clock := NewManualClock()
+	cpu := NewCpu(clock, nil)
+	cpu.Dt.SetV(5)
+	cpu.Close()
+	clock.Tick()
+	if dt := cpu.Dt.GetV(); dt != 5 {
+		t.Errorf("DT = %d after Close; want 5", dt)
+	}
+}
+
+func TestFrameClockStandsStillWhilePaused(t *testing.T) {
+	chip := newTestChip(t, 0x12, 0x00) // JP 0x200
+	chip.Dt.SetV(10)
+	if e := chip.Frame(); e != nil {
+		t.Fatal(e)
+	}
+	if dt := chip.Dt.GetV(); dt != 9 {
+		t.Fatalf("DT = %d after a frame; want 9", dt)
+	}
+	// a debugger pausing the machine runs no frames.
+	time.Sleep(50 * time.Millisecond)
+	if dt := chip.Dt.GetV(); dt != 9 {
+		t.Errorf("DT = %d while paused; want 9", dt)
+	}
+}
+
+func TestRealClockPause(t *testing.T) {
+	clock := NewRealClock(1000)
+	defer clock.Stop()
+	cpu := NewCpu(clock, nil)
+	defer cpu.Close()
+	clock.Pause()
+	cpu.Dt.SetV(100)
+	time.Sleep(20 * time.Millisecond)
+	if dt := cpu.Dt.GetV(); dt != 100 {
+		t.Fatalf("DT = %d while paused; want 100", dt)
+	}
+	clock.Resume()
+	time.Sleep(20 * time.Millisecond)
+	if dt := cpu.Dt.GetV(); dt == 100 {
+		t.Error("DT didn't count down after Resume")
+	}
+	clock.Stop()
+	clock.Stop()
+}
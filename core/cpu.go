@@ -2,10 +2,13 @@ package core
 
 import (
 	"context"
+	"crypto/sha256"
 	"fmt"
 	"io"
+	"math"
 	"math/rand"
-	"time"
+	"os"
+	"sync"
 
 	"github.com/masu-mi/gochip-8/env"
 )
@@ -13,11 +16,25 @@ import (
 // CHIP-8 emulator
 // https://en.wikipedia.org/wiki/CHIP-8
 type Chip8 struct {
+	// Run holds the lock while a frame is executed.
+	// Lock it to inspect or change the machine between frames.
+	sync.Mutex
+
 	*Cpu
 	*Memory
 	Display
 	Keyboard
 	Buzzer
+
+	// IPF is the number of instructions per frame. DefaultIPF is used when it's 0.
+	IPF   int
+	Stats Stats
+	// Rewinder records frames of Run to step them backwards. It's disabled when nil.
+	Rewinder *Rewinder
+	// Break is called before every instruction of Frame, usually by a debugger.
+	Break func() bool
+	// ROMHash is SHA-256 of the program loaded by Init.
+	ROMHash [sha256.Size]byte
 }
 
 const StartOfProgram = 0x200
@@ -26,19 +43,33 @@ func (chip *Chip8) Init(rom io.Reader) (int, error) {
 	for i, f := range Font {
 		copy(chip.Memory.Buf[fontAddr(uint8(i)):fontAddr(uint8(i))+5], f[0:])
 	}
-	return chip.Memory.Load(StartOfProgram, rom)
+	for i, f := range BigFont {
+		copy(chip.Memory.Buf[bigFontAddr(uint8(i)):bigFontAddr(uint8(i))+10], f[0:])
+	}
+	n, e := chip.Memory.Load(StartOfProgram, rom)
+	chip.ROMHash = sha256.Sum256(chip.Memory.Buf[StartOfProgram : StartOfProgram+n])
+	return n, e
 }
 
-func (chip *Chip8) Run(ctx context.Context) {
-	chip.Cpu.Run(ctx, chip.Memory, chip.Display, chip.Keyboard, chip.Buzzer)
+// Close releases the resources held by the machine.
+func (chip *Chip8) Close() {
+	chip.Cpu.Close()
 }
-func (chip *Chip8) Cycle() {
-	chip.Cpu.Cycle(context.Background(), chip.Memory, chip.Display, chip.Keyboard, chip.Buzzer)
+
+// SetBuzzer replaces Buzzer, which the sound timer starts and stops.
+func (chip *Chip8) SetBuzzer(buz Buzzer) {
+	chip.Buzzer = buz
+	chip.Cpu.St.SetHandler(buz)
+}
+
+func (chip *Chip8) Cycle() error {
+	return chip.Cpu.Cycle(context.Background(), chip.Memory, chip.Display, chip.Keyboard, chip.Buzzer)
 }
 
 type Cpu struct {
 	*rand.Rand
-	Ticker *time.Ticker
+	Clock  Clock
+	Quirks Quirks
 
 	V [16]uint8
 	I uint16
@@ -49,89 +80,195 @@ type Cpu struct {
 	Pc    uint16
 	Sp    uint8
 	Stack [16]uint16
+
+	// RPL are SUPER-CHIP's user flags saved by Fx75 and restored by Fx85.
+	RPL [16]uint8
+	// Hires is true while SUPER-CHIP's 128x64 mode is enabled.
+	Hires bool
+	// Halted is set by 00FD (EXIT).
+	Halted bool
+
+	// Planes selects XO-CHIP's bit planes affected by drawing, clearing and scrolling.
+	Planes uint8
+	// Pattern is XO-CHIP's 1-bit audio pattern buffer loaded by F002.
+	Pattern [16]uint8
+	// Pitch sets the playback rate of Pattern (Fx3A).
+	Pitch uint8
+
+	// FaultPolicy decides how Chip8.Run reacts to a faulting instruction.
+	FaultPolicy FaultPolicy
+	// Trap is called by Chip8.Run on a fault under FaultTrap.
+	// Run stops with the returned error. On nil, Run resumes only if Trap
+	// moved Pc off the faulting instruction; otherwise the CPU halts as
+	// under FaultHalt, so the same fault never repeats forever.
+	Trap func(f *Fault) error
+
+	waitVBlank bool
+	// waitKey is set when Fx0A returns without a key.
+	waitKey bool
 }
 
-func NewCpu(tick *time.Ticker, buz Buzzer) *Cpu {
+// NewCpu returns Cpu whose timers count down on clock.
+// nil clock is a FrameClock, ticked by Chip8's frames.
+func NewCpu(clock Clock, buz Buzzer) *Cpu {
+	if clock == nil {
+		clock = NewFrameClock()
+	}
 	c := &Cpu{
+		Clock:  clock,
 		Pc:     StartOfProgram,
-		Dt:     NewDelayedTimer(60, nil),
-		St:     NewDelayedTimer(60, buz),
-		Ticker: tick,
+		Planes: 1,
+		Pitch:  64,
+		Dt:     NewDelayedTimer(clock, nil),
+		St:     NewDelayedTimer(clock, buz),
 	}
 	return c
 }
 
-func (cpu *Cpu) Run(ctx context.Context, ram *Memory, disp Display, keys Keyboard, buz Buzzer) {
-LOOP:
-	for {
-		if cpu.Pc >= uint16(len(ram.Buf)) {
-			break
-		}
-		cpu.Cycle(ctx, ram, disp, keys, buz)
-		select {
-		case <-cpu.Ticker.C:
-		case <-ctx.Done():
-			break LOOP
+// Close detaches the timers from Clock.
+func (cpu *Cpu) Close() {
+	cpu.Dt.Close()
+	cpu.St.Close()
+}
+
+// handleFault applies FaultPolicy to e returned by Cycle.
+func (cpu *Cpu) handleFault(e error) error {
+	f, ok := e.(*Fault)
+	if !ok {
+		return e
+	}
+	switch cpu.FaultPolicy {
+	case FaultSkip:
+		trace("skip: %v", f)
+		cpu.Pc = f.Pc + 2
+		return nil
+	case FaultTrap:
+		if cpu.Trap != nil {
+			if e := cpu.Trap(f); e != nil || cpu.Halted || cpu.Pc != f.Pc {
+				return e
+			}
 		}
 	}
+	cpu.Halted = true
+	return f
 }
 
-// Cycle
-func (cpu *Cpu) Cycle(ctx context.Context, ram *Memory, disp Display, keys Keyboard, buz Buzzer) {
+// Cycle executes the instruction at Pc.
+// A faulting instruction returns *Fault and leaves Pc pointing to it.
+func (cpu *Cpu) Cycle(ctx context.Context, ram *Memory, disp Display, keys Keyboard, buz Buzzer) error {
 	defer cpu.dump()
-	op := ram.Buf[cpu.Pc : cpu.Pc+2]
+	defer cpu.Clock.Cycle()
+	pc := cpu.Pc
+	if !inRange(ram, pc, 2) {
+		return &Fault{Err: ErrMemoryBounds, Pc: pc}
+	}
+	ram.access(AccessFetch, pc, 2)
+	op := ram.Buf[pc : pc+2]
 	inst := NewInstruction(op)
+	fault := func(err error) error {
+		return &Fault{Err: err, Pc: pc, Opcode: inst.opcode()}
+	}
 	switch inst.o1 {
 	case 0x0:
 		switch {
 		case inst == instruction{0, 0, 0xe, 0}:
 			trace("00E0 - CLS")
-			disp.Clear()
+			disp.Clear(cpu.Planes)
 		case inst == instruction{0, 0, 0xe, 0xe}:
 			trace("00EE - RET")
+			if cpu.Sp == 0 {
+				return fault(ErrStackUnderflow)
+			}
 			cpu.Pc = cpu.Stack[cpu.Sp-1]
 			cpu.Sp--
+		case inst.o2 == 0 && inst.o3 == 0xc:
+			trace("00Cn - SCD %d", inst.o4)
+			disp.Scroll(cpu.Planes, 0, int(inst.o4))
+		case inst.o2 == 0 && inst.o3 == 0xd:
+			trace("00Dn - SCU %d", inst.o4)
+			disp.Scroll(cpu.Planes, 0, -int(inst.o4))
+		case inst == instruction{0, 0, 0xf, 0xb}:
+			trace("00FB - SCR")
+			disp.Scroll(cpu.Planes, 4, 0)
+		case inst == instruction{0, 0, 0xf, 0xc}:
+			trace("00FC - SCL")
+			disp.Scroll(cpu.Planes, -4, 0)
+		case inst == instruction{0, 0, 0xf, 0xd}:
+			trace("00FD - EXIT")
+			cpu.Halted = true
+			return nil
+		case inst == instruction{0, 0, 0xf, 0xe}:
+			trace("00FE - LOW")
+			cpu.Hires = false
+			disp.SetHires(false)
+		case inst == instruction{0, 0, 0xf, 0xf}:
+			trace("00FF - HIGH")
+			cpu.Hires = true
+			disp.SetHires(true)
 		case inst.o1 == 0:
 			next := addr(inst.o2, inst.o3, inst.o4)
 			trace("0nnn - SYS 0x%X", next)
 			cpu.Pc = next
-			return
+			return nil
 		}
 	case 0x1:
 		next := addr(inst.o2, inst.o3, inst.o4)
 		trace("1nnn - JP 0x%x", next)
 		cpu.Pc = next
-		return
+		return nil
 	case 0x2:
 		next := addr(inst.o2, inst.o3, inst.o4)
 		trace("2nnn - CALL addr 0x%x", next)
+		if int(cpu.Sp) >= len(cpu.Stack) {
+			return fault(ErrStackOverflow)
+		}
 		cpu.Sp++
 		cpu.Stack[cpu.Sp-1] = cpu.Pc
 		cpu.Pc = next
-		return
+		return nil
 	case 0x3:
 		kk := bite(inst.o3, inst.o4)
 		cv := cpu.V[inst.o2]
 		trace("3xkk - SE V%d(0x%x), 0x%x", inst.o2, cv, kk)
 		if cv == kk {
-			cpu.Pc += 2
+			cpu.skip(ram)
 		}
 	case 0x4:
 		kk := bite(inst.o3, inst.o4)
 		cv := cpu.V[inst.o2]
 		trace("4xkk - SNE V%d(0x%x), 0x%x", inst.o2, cv, kk)
 		if cv != kk {
-			cpu.Pc += 2
+			cpu.skip(ram)
 		}
 	case 0x5:
-		if inst.o4 != 0x0 {
-			panic(fmt.Sprintf("N/A: `%v`", inst))
-		}
-		cx := cpu.V[inst.o2]
-		cy := cpu.V[inst.o3]
-		trace("5xy0 - SE V%d(0x%x), V%d(0x%x)", inst.o2, cx, inst.o3, cy)
-		if cx == cy {
-			cpu.Pc += 2
+		switch inst.o4 {
+		case 0x0:
+			cx := cpu.V[inst.o2]
+			cy := cpu.V[inst.o3]
+			trace("5xy0 - SE V%d(0x%x), V%d(0x%x)", inst.o2, cx, inst.o3, cy)
+			if cx == cy {
+				cpu.skip(ram)
+			}
+		case 0x2:
+			trace("5xy2 - SAVE V%d - V%d", inst.o2, inst.o3)
+			if !inRange(ram, cpu.I, len(registerRange(inst.o2, inst.o3))) {
+				return fault(ErrMemoryBounds)
+			}
+			ram.access(AccessWrite, cpu.I, len(registerRange(inst.o2, inst.o3)))
+			for i, r := range registerRange(inst.o2, inst.o3) {
+				ram.Buf[cpu.I+uint16(i)] = cpu.V[r]
+			}
+		case 0x3:
+			trace("5xy3 - LOAD V%d - V%d", inst.o2, inst.o3)
+			if !inRange(ram, cpu.I, len(registerRange(inst.o2, inst.o3))) {
+				return fault(ErrMemoryBounds)
+			}
+			ram.access(AccessRead, cpu.I, len(registerRange(inst.o2, inst.o3)))
+			for i, r := range registerRange(inst.o2, inst.o3) {
+				cpu.V[r] = ram.Buf[cpu.I+uint16(i)]
+			}
+		default:
+			return fault(ErrInvalidOpcode)
 		}
 	case 0x6:
 		v := bite(inst.o3, inst.o4)
@@ -149,60 +286,56 @@ func (cpu *Cpu) Cycle(ctx context.Context, ram *Memory, disp Display, keys Keybo
 		case 0x1:
 			trace("8xy1 - OR V%d, V%d", inst.o2, inst.o3)
 			cpu.V[inst.o2] |= cpu.V[inst.o3]
+			cpu.resetVF()
 		case 0x2:
 			trace("8xy2 - AND V%d, V%d", inst.o2, inst.o3)
 			cpu.V[inst.o2] &= cpu.V[inst.o3]
+			cpu.resetVF()
 		case 0x3:
 			trace("8xy3 - XOR V%d, V%d", inst.o2, inst.o3)
 			and := cpu.V[inst.o2] & cpu.V[inst.o3]
 			cpu.V[inst.o2] = (cpu.V[inst.o2] | cpu.V[inst.o3]) & ^and
+			cpu.resetVF()
 		case 0x4:
 			trace("8xy4 - ADD V%d, V%d", inst.o2, inst.o3)
 			add := uint16(cpu.V[inst.o2]) + uint16(cpu.V[inst.o3])
-			if add > 0xff {
-				add = add & 0xff
-				cpu.V[0xF] = 1
-			} else {
-				cpu.V[0xF] = 0
-			}
+			// VF is written after Vx to hold the flag even when x is F.
 			cpu.V[inst.o2] = uint8(add)
+			cpu.V[0xF] = uint8(add >> 8)
 		case 0x5:
 			trace("8xy5 - SUB V%d, V%d", inst.o2, inst.o3)
 			vx := cpu.V[inst.o2]
 			vy := cpu.V[inst.o3]
-			if vx > vy {
-				cpu.V[0xF] = 1
-			} else {
-				cpu.V[0xF] = 0
-			}
 			cpu.V[inst.o2] = vx - vy
+			// VF is NOT borrow.
+			cpu.V[0xF] = flag(vx >= vy)
 		case 0x6:
 			trace("8xy6 - SHR V%d {, V%d}", inst.o2, inst.o3)
-			cpu.V[0xF] = cpu.V[inst.o2] & 0x1
-			cpu.V[inst.o2] >>= 1
+			src := cpu.shiftSource(inst.o2, inst.o3)
+			cpu.V[inst.o2] = src >> 1
+			cpu.V[0xF] = src & 0x1
 		case 0x7:
 			trace("8xy7 - SUBN V%d, V%d", inst.o2, inst.o3)
 			vx := cpu.V[inst.o2]
 			vy := cpu.V[inst.o3]
-			if vx < vy {
-				cpu.V[0xF] = 1
-			} else {
-				cpu.V[0xF] = 0
-			}
 			cpu.V[inst.o2] = vy - vx
+			cpu.V[0xF] = flag(vy >= vx)
 		case 0xE:
 			trace("8xyE - SHL V%d {, V%d}", inst.o2, inst.o3)
-			cpu.V[0xF] = cpu.V[inst.o2] >> 7 & 0x1
-			cpu.V[inst.o2] <<= 1
+			src := cpu.shiftSource(inst.o2, inst.o3)
+			cpu.V[inst.o2] = src << 1
+			cpu.V[0xF] = src >> 7 & 0x1
+		default:
+			return fault(ErrInvalidOpcode)
 		}
 	case 0x9:
 		if inst.o4 != 0x0 {
-			panic(fmt.Sprintf("N/A: `%v`", inst))
+			return fault(ErrInvalidOpcode)
 		}
 		vx, vy := cpu.V[inst.o2], cpu.V[inst.o3]
 		trace("9xy0 - SNE V%d(0x%x), V%d(0x%x)", inst.o2, vx, inst.o3, vy)
 		if vx != vy {
-			cpu.Pc += 2
+			cpu.skip(ram)
 		}
 	case 0xA:
 		p := addr(inst.o2, inst.o3, inst.o4)
@@ -210,9 +343,14 @@ func (cpu *Cpu) Cycle(ctx context.Context, ram *Memory, disp Display, keys Keybo
 		cpu.I = p
 	case 0xB:
 		p := addr(inst.o2, inst.o3, inst.o4)
+		if cpu.Quirks.JumpVx {
+			trace("Bxnn - JP V%d, *(0x%x)", inst.o2, p)
+			cpu.Pc = p + uint16(cpu.V[inst.o2])
+			return nil
+		}
 		trace("Bnnn - JP V0, *(0x%x)", p)
 		cpu.Pc = p + uint16(cpu.V[0x0])
-		return
+		return nil
 	case 0xC:
 		v := bite(inst.o3, inst.o4)
 		trace("Cxkk - RND V%d, 0x%x", inst.o2, v)
@@ -223,39 +361,89 @@ func (cpu *Cpu) Cycle(ctx context.Context, ram *Memory, disp Display, keys Keybo
 		cpu.V[inst.o2] = r & v
 	case 0xD:
 		trace("Dxyn - DRW V%d, V%d, %d[byte]", inst.o2, inst.o3, inst.o4)
-		sprite := ram.Buf[cpu.I : cpu.I+uint16(inst.o4)]
-		if disp.Draw(cpu.V[inst.o2], cpu.V[inst.o3], sprite) {
+		// XO-CHIP draws the sprite of each selected plane in turn.
+		size := uint16(inst.o4)
+		if size == 0 {
+			size = 32
+		}
+		if !inRange(ram, cpu.I, int(size)*bits(cpu.Planes)) {
+			return fault(ErrMemoryBounds)
+		}
+		ram.access(AccessRead, cpu.I, int(size)*bits(cpu.Planes))
+		var collision bool
+		p := cpu.I
+		for plane := uint8(1); plane <= 2; plane <<= 1 {
+			if cpu.Planes&plane == 0 {
+				continue
+			}
+			if inst.o4 == 0 {
+				collision = cpu.drawWide(disp, plane, cpu.V[inst.o2], cpu.V[inst.o3], ram.Buf[p:p+size]) || collision
+			} else {
+				collision = cpu.draw(disp, plane, cpu.V[inst.o2], cpu.V[inst.o3], ram.Buf[p:p+size]) || collision
+			}
+			p += size
+		}
+		if collision {
 			cpu.V[0xF] = 1
 		} else {
 			cpu.V[0xF] = 0
 		}
+		cpu.waitVBlank = cpu.Quirks.DisplayWait
 	case 0xE:
 		if inst.o3 == 0x9 && inst.o4 == 0xE {
 			trace("Ex9E - SKP V%d", inst.o2)
 			target := cpu.V[inst.o2]
 			pressed := keys.IsPressed(target)
 			if pressed {
-				cpu.Pc += 2
+				cpu.skip(ram)
 			}
 		} else if inst.o3 == 0xA && inst.o4 == 0x1 {
 			trace("ExA1 - SKNP V%d", inst.o2)
 			target := cpu.V[inst.o2]
 			pressed := keys.IsPressed(target)
 			if !pressed {
-				cpu.Pc += 2
+				cpu.skip(ram)
 			}
 		} else {
-			panic(fmt.Sprintf("N/A: `%v`", inst))
+			return fault(ErrInvalidOpcode)
 		}
 	case 0xF:
 		switch {
+		case inst == instruction{0xf, 0, 0, 0}:
+			if !inRange(ram, cpu.Pc, 4) {
+				return fault(ErrMemoryBounds)
+			}
+			ram.access(AccessFetch, cpu.Pc+2, 2)
+			p := uint16(ram.Buf[cpu.Pc+2])<<8 | uint16(ram.Buf[cpu.Pc+3])
+			trace("F000 nnnn - LD I, *(0x%04x)", p)
+			cpu.I = p
+			cpu.Pc += 4
+			return nil
+		case inst.o3 == 0x0 && inst.o4 == 0x1:
+			trace("Fn01 - PLANE %d", inst.o2)
+			cpu.Planes = inst.o2 & 0x3
+		case inst == instruction{0xf, 0, 0, 0x2}:
+			trace("F002 - AUDIO")
+			if !inRange(ram, cpu.I, len(cpu.Pattern)) {
+				return fault(ErrMemoryBounds)
+			}
+			ram.access(AccessRead, cpu.I, len(cpu.Pattern))
+			copy(cpu.Pattern[:], ram.Buf[cpu.I:cpu.I+16])
+			if a, ok := buz.(AudioBuzzer); ok {
+				a.SetPattern(cpu.Pattern)
+			}
 		case inst.o3 == 0x0 && inst.o4 == 0x7:
 			trace("Fx07 - LD V%d, DT", inst.o2)
 			cpu.V[inst.o2] = cpu.Dt.GetV()
 		case inst.o3 == 0x0 && inst.o4 == 0xA:
 			trace("Fx0A - LD V%d, K", inst.o2)
-			target := cpu.V[inst.o2]
-			keys.Wait(ctx, target)
+			key, ok := keys.WaitKey(ctx, cpu.Quirks.WaitRelease)
+			if !ok {
+				// Pc stays here to wait again when resumed.
+				cpu.waitKey = true
+				return nil
+			}
+			cpu.V[inst.o2] = key
 		case inst.o3 == 0x1 && inst.o4 == 0x5:
 			trace("Fx15 - LD DT, V%d", inst.o2)
 			cpu.Dt.SetV(cpu.V[inst.o2])
@@ -268,28 +456,176 @@ func (cpu *Cpu) Cycle(ctx context.Context, ram *Memory, disp Display, keys Keybo
 		case inst.o3 == 0x2 && inst.o4 == 0x9:
 			trace("Fx29 - LD F, V%d", inst.o2)
 			cpu.I = fontAddr(cpu.V[inst.o2])
+		case inst.o3 == 0x3 && inst.o4 == 0xA:
+			trace("Fx3A - PITCH V%d", inst.o2)
+			cpu.Pitch = cpu.V[inst.o2]
+			if a, ok := buz.(AudioBuzzer); ok {
+				a.SetPitch(cpu.Pitch)
+			}
+		case inst.o3 == 0x3 && inst.o4 == 0x0:
+			trace("Fx30 - LD HF, V%d", inst.o2)
+			cpu.I = bigFontAddr(cpu.V[inst.o2])
 		case inst.o3 == 0x3 && inst.o4 == 0x3:
 			trace("Fx33 - LD B, V%d", inst.o2)
+			if !inRange(ram, cpu.I, 3) {
+				return fault(ErrMemoryBounds)
+			}
+			ram.access(AccessWrite, cpu.I, 3)
 			ram.Buf[cpu.I], ram.Buf[cpu.I+1], ram.Buf[cpu.I+2] = bcd(cpu.V[inst.o2])
 		case inst.o3 == 0x5 && inst.o4 == 0x5:
 			trace("Fx55 - LD [I], V%d", inst.o2)
+			if !inRange(ram, cpu.I, int(inst.o2)+1) {
+				return fault(ErrMemoryBounds)
+			}
+			ram.access(AccessWrite, cpu.I, int(inst.o2)+1)
 			copy(ram.Buf[cpu.I:(cpu.I+uint16(inst.o2)+1)], cpu.V[0:inst.o2+1])
+			if cpu.Quirks.LoadStoreIncI {
+				cpu.I += uint16(inst.o2) + 1
+			}
 		case inst.o3 == 0x6 && inst.o4 == 0x5:
 			trace("Fx65 - LD V%d, [I]", inst.o2)
+			if !inRange(ram, cpu.I, int(inst.o2)+1) {
+				return fault(ErrMemoryBounds)
+			}
+			ram.access(AccessRead, cpu.I, int(inst.o2)+1)
 			copy(cpu.V[0:inst.o2+1], ram.Buf[cpu.I:(cpu.I+uint16(inst.o2)+1)])
+			if cpu.Quirks.LoadStoreIncI {
+				cpu.I += uint16(inst.o2) + 1
+			}
+		case inst.o3 == 0x7 && inst.o4 == 0x5:
+			trace("Fx75 - LD R, V%d", inst.o2)
+			copy(cpu.RPL[0:inst.o2+1], cpu.V[0:inst.o2+1])
+		case inst.o3 == 0x8 && inst.o4 == 0x5:
+			trace("Fx85 - LD V%d, R", inst.o2)
+			copy(cpu.V[0:inst.o2+1], cpu.RPL[0:inst.o2+1])
+		default:
+			return fault(ErrInvalidOpcode)
 		}
 	}
 	// All instructions are 2 bytes long and are stored most-significant-byte first.
 	// In memory, the first byte of each instruction should be located at an even addresses.
 	// If a program includes sprite data, it should be padded so any instructions following it will be properly situated in RAM.
 	cpu.Pc += 2
+	return nil
+}
+
+// flag returns 1 for true as VF.
+func flag(b bool) uint8 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func (cpu *Cpu) resetVF() {
+	if cpu.Quirks.VFReset {
+		cpu.V[0xF] = 0
+	}
+}
+
+func (cpu *Cpu) shiftSource(x, y uint8) uint8 {
+	if cpu.Quirks.ShiftVy {
+		return cpu.V[y]
+	}
+	return cpu.V[x]
+}
+
+// screen returns the size of the current resolution.
+func (cpu *Cpu) screen() (w, h int) {
+	if cpu.Hires {
+		return HIRES_WIDTH, HIRES_HEIGHT
+	}
+	return WIDTH, HEIGHT
+}
+
+// draw draws 8 pixels wide sprite on plane. The origin is wrapped around the screen
+// and the rest of the sprite is either wrapped or clipped by Quirks.ClipSprites.
+func (cpu *Cpu) draw(disp Display, plane, x, y uint8, sprite []byte) bool {
+	w, h := cpu.screen()
+	x, y = uint8(int(x)%w), uint8(int(y)%h)
+	if cpu.Quirks.ClipSprites {
+		sprite = clip(w, h, x, y, sprite)
+	}
+	return disp.Draw(plane, x, y, sprite)
+}
+
+// drawWide draws SUPER-CHIP's 16x16 sprite as the left and the right 8x16 halves.
+func (cpu *Cpu) drawWide(disp Display, plane, x, y uint8, sprite []byte) bool {
+	w, _ := cpu.screen()
+	left, right := make([]byte, 16), make([]byte, 16)
+	for i := range left {
+		left[i], right[i] = sprite[2*i], sprite[2*i+1]
+	}
+	x = uint8(int(x) % w)
+	collision := cpu.draw(disp, plane, x, y, left)
+	if cpu.Quirks.ClipSprites && int(x)+8 >= w {
+		return collision
+	}
+	return cpu.draw(disp, plane, x+8, y, right) || collision
+}
+
+// skip skips the next instruction. XO-CHIP's F000 nnnn is 4 bytes long.
+func (cpu *Cpu) skip(ram *Memory) {
+	cpu.Pc += 2
+	if int(cpu.Pc)+1 < len(ram.Buf) && ram.Buf[cpu.Pc] == 0xf0 && ram.Buf[cpu.Pc+1] == 0x00 {
+		cpu.Pc += 2
+	}
+}
+
+func inRange(ram *Memory, p uint16, n int) bool {
+	return int(p)+n <= len(ram.Buf)
+}
+
+// bits counts the set bits of planes.
+func bits(planes uint8) int {
+	n := 0
+	for ; planes > 0; planes >>= 1 {
+		n += int(planes & 1)
+	}
+	return n
+}
+
+// registerRange lists registers from x to y, in reverse order when x > y.
+func registerRange(x, y uint8) []uint8 {
+	var r []uint8
+	if x <= y {
+		for i := int(x); i <= int(y); i++ {
+			r = append(r, uint8(i))
+		}
+		return r
+	}
+	for i := int(x); i >= int(y); i-- {
+		r = append(r, uint8(i))
+	}
+	return r
+}
+
+// PlaybackRate is the rate of Pattern's bits in Hz.
+func (cpu *Cpu) PlaybackRate() float64 {
+	return 4000 * math.Pow(2, (float64(cpu.Pitch)-64)/48)
+}
+
+// clip drops the rows and bits of sprite which would be drawn outside of the w x h screen.
+func clip(w, h int, x, y uint8, sprite []byte) []byte {
+	if rows := h - int(y); len(sprite) > rows {
+		sprite = sprite[:rows]
+	}
+	over := int(x) + 8 - w
+	if over <= 0 {
+		return sprite
+	}
+	clipped := make([]byte, len(sprite))
+	for i, b := range sprite {
+		clipped[i] = b & (0xff << over)
+	}
+	return clipped
 }
 
 func (c *Cpu) dump() {
 	if !env.DEBUG {
 		return
 	}
-	fmt.Printf("regs:: v[%v], I: 0x%04x, Dt:%v, St:%v, Pc:0x%03x, Sp: %02d, Stack: %v\n", c.V, c.I, c.Dt.GetV(), c.St.GetV(), c.Pc, c.Sp, c.Stack)
+	fmt.Fprintf(TraceOutput, "regs:: v[%v], I: 0x%04x, Dt:%v, St:%v, Pc:0x%03x, Sp: %02d, Stack: %v\n", c.V, c.I, c.Dt.GetV(), c.St.GetV(), c.Pc, c.Sp, c.Stack)
 }
 
 func bcd(v uint8) (h, t, o uint8) {
@@ -306,9 +642,13 @@ func bite(n1, n2 uint8) uint8 {
 	return n1<<4 + n2
 }
 
+// TraceOutput receives the trace of instructions in builds with the debug tag.
+// Point it away from the terminal while a full-screen UI is drawn.
+var TraceOutput io.Writer = os.Stdout
+
 func trace(msg string, d ...interface{}) {
 	if env.DEBUG {
-		fmt.Printf(fmt.Sprintf("%s\n", msg), d...)
+		fmt.Fprintf(TraceOutput, fmt.Sprintf("%s\n", msg), d...)
 	}
 }
 
@@ -316,6 +656,10 @@ type instruction struct {
 	o1, o2, o3, o4 uint8
 }
 
+func (inst instruction) opcode() uint16 {
+	return uint16(inst.o1)<<12 | uint16(inst.o2)<<8 | uint16(inst.o3)<<4 | uint16(inst.o4)
+}
+
 func NewInstruction(seg []byte) instruction {
 	return instruction{
 		seg[0] >> 4,
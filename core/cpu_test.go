@@ -0,0 +1,107 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+// testDisplay keeps the sprite drawn last.
+type testDisplay struct {
+	x, y   uint8
+	sprite []byte
+}
+
+func (d *testDisplay) Clear(planes uint8)              {}
+func (d *testDisplay) SetHires(hires bool)             {}
+func (d *testDisplay) Scroll(planes uint8, dx, dy int) {}
+func (d *testDisplay) Draw(plane, x, y uint8, sprite []byte) bool {
+	d.x, d.y, d.sprite = x, y, append([]byte(nil), sprite...)
+	return false
+}
+
+// testKeyboard answers Fx0A with key unless idle, and keeps whether it waited for the release.
+type testKeyboard struct {
+	key     uint8
+	idle    bool
+	release bool
+}
+
+func (k *testKeyboard) IsPressed(key uint8) bool { return false }
+func (k *testKeyboard) WaitKey(ctx context.Context, release bool) (uint8, bool) {
+	k.release = release
+	if k.idle {
+		return 0, false
+	}
+	return k.key, true
+}
+
+// newTestChip returns a machine running program from StartOfProgram.
+func newTestChip(t *testing.T, program ...byte) *Chip8 {
+	t.Helper()
+	chip := &Chip8{
+		Cpu:      NewCpu(nil, nil),
+		Memory:   NewMemory(MemorySize),
+		Display:  &testDisplay{},
+		Keyboard: &testKeyboard{},
+	}
+	t.Cleanup(chip.Close)
+	if _, e := chip.Init(bytes.NewReader(program)); e != nil {
+		t.Fatal(e)
+	}
+	return chip
+}
+
+func TestArithmeticFlags(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		op     []byte
+		x, y   uint8
+		vx, vf uint8
+	}{
+		{"ADD without carry", []byte{0x81, 0x24}, 0x01, 0x02, 0x03, 0},
+		{"ADD with carry", []byte{0x81, 0x24}, 0xff, 0x01, 0x00, 1},
+		{"SUB without borrow", []byte{0x81, 0x25}, 0x05, 0x03, 0x02, 1},
+		{"SUB of equals", []byte{0x81, 0x25}, 0x03, 0x03, 0x00, 1},
+		{"SUB with borrow", []byte{0x81, 0x25}, 0x03, 0x05, 0xfe, 0},
+		{"SUBN without borrow", []byte{0x81, 0x27}, 0x03, 0x05, 0x02, 1},
+		{"SUBN of equals", []byte{0x81, 0x27}, 0x03, 0x03, 0x00, 1},
+		{"SUBN with borrow", []byte{0x81, 0x27}, 0x05, 0x03, 0xfe, 0},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			chip := newTestChip(t, tc.op...)
+			chip.V[1], chip.V[2] = tc.x, tc.y
+			if e := chip.Cycle(); e != nil {
+				t.Fatal(e)
+			}
+			if chip.V[1] != tc.vx || chip.V[0xF] != tc.vf {
+				t.Errorf("V1, VF = 0x%02x, %d; want 0x%02x, %d", chip.V[1], chip.V[0xF], tc.vx, tc.vf)
+			}
+		})
+	}
+}
+
+// TestFlagOverVF tests that the flag is written after the result when x is F.
+func TestFlagOverVF(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		op   []byte
+		f, y uint8
+		vf   uint8
+	}{
+		{"ADD", []byte{0x8f, 0x24}, 0xff, 0x01, 1},
+		{"SUB", []byte{0x8f, 0x25}, 0x01, 0x02, 0},
+		{"SUBN", []byte{0x8f, 0x27}, 0x01, 0x02, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			chip := newTestChip(t, tc.op...)
+			chip.V[0xF], chip.V[2] = tc.f, tc.y
+			if e := chip.Cycle(); e != nil {
+				t.Fatal(e)
+			}
+			if chip.V[0xF] != tc.vf {
+				t.Errorf("VF = %d; want %d", chip.V[0xF], tc.vf)
+			}
+		})
+	}
+}
@@ -0,0 +1,65 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrInvalidOpcode  = errors.New("invalid opcode")
+	ErrStackOverflow  = errors.New("stack overflow")
+	ErrStackUnderflow = errors.New("stack underflow")
+	ErrMemoryBounds   = errors.New("memory access out of bounds")
+)
+
+// Fault is returned by Cpu.Cycle when an instruction can't be executed.
+// Err is one of ErrInvalidOpcode, ErrStackOverflow, ErrStackUnderflow and ErrMemoryBounds.
+type Fault struct {
+	Err    error
+	Pc     uint16
+	Opcode uint16
+}
+
+func (f *Fault) Error() string {
+	return fmt.Sprintf("%v: %04X at 0x%03x", f.Err, f.Opcode, f.Pc)
+}
+
+func (f *Fault) Unwrap() error {
+	return f.Err
+}
+
+// FaultPolicy decides how Chip8.Run reacts to a Fault.
+type FaultPolicy int
+
+const (
+	// FaultHalt stops the CPU and returns the Fault.
+	FaultHalt FaultPolicy = iota
+	// FaultSkip ignores the faulting instruction and continues.
+	FaultSkip
+	// FaultTrap hands the Fault to Cpu.Trap, usually a debugger.
+	// The CPU halts unless Trap moves Pc or returns an error.
+	FaultTrap
+)
+
+var faultPolicyNames = map[FaultPolicy]string{
+	FaultHalt: "halt",
+	FaultSkip: "skip",
+	FaultTrap: "trap",
+}
+
+func (p FaultPolicy) String() string {
+	if n, ok := faultPolicyNames[p]; ok {
+		return n
+	}
+	return fmt.Sprintf("FaultPolicy(%d)", int(p))
+}
+
+// ParseFaultPolicy returns the FaultPolicy named name.
+func ParseFaultPolicy(name string) (FaultPolicy, error) {
+	for p, n := range faultPolicyNames {
+		if n == name {
+			return p, nil
+		}
+	}
+	return FaultHalt, fmt.Errorf("unknown fault policy `%s` (available: halt, skip, trap)", name)
+}
@@ -0,0 +1,110 @@
+package core
+
+import (
+	"errors"
+	"testing"
+)
+
+var faultCases = []struct {
+	name    string
+	program []byte
+	setup   func(chip *Chip8)
+	pc      uint16
+	err     error
+}{
+	{"invalid opcode", []byte{0x51, 0x29}, nil, 0x200, ErrInvalidOpcode},
+	{"invalid opcode of Exkk", []byte{0xe1, 0x00}, nil, 0x200, ErrInvalidOpcode},
+	{"return from main", []byte{0x00, 0xee}, nil, 0x200, ErrStackUnderflow},
+	{"call over the stack", []byte{0x22, 0x00}, func(chip *Chip8) { chip.Sp = uint8(len(chip.Stack)) }, 0x200, ErrStackOverflow},
+	{"BCD over memory", []byte{0xf1, 0x33}, func(chip *Chip8) { chip.I = MemorySize - 2 }, 0x200, ErrMemoryBounds},
+	{"sprite over memory", []byte{0xd1, 0x25}, func(chip *Chip8) { chip.I = MemorySize - 4 }, 0x200, ErrMemoryBounds},
+	{"fetch over memory", []byte{0x12, 0x00}, func(chip *Chip8) { chip.Pc = MemorySize - 1 }, MemorySize - 1, ErrMemoryBounds},
+}
+
+func faultChip(t *testing.T, program []byte, setup func(chip *Chip8), policy FaultPolicy) *Chip8 {
+	t.Helper()
+	chip := newTestChip(t, program...)
+	chip.FaultPolicy = policy
+	if setup != nil {
+		setup(chip)
+	}
+	return chip
+}
+
+func TestFaultHalt(t *testing.T) {
+	for _, tc := range faultCases {
+		t.Run(tc.name, func(t *testing.T) {
+			chip := faultChip(t, tc.program, tc.setup, FaultHalt)
+			e := chip.handleFault(chip.Cycle())
+			var f *Fault
+			if !errors.As(e, &f) {
+				t.Fatalf("error = %v; want a Fault", e)
+			}
+			if !errors.Is(e, tc.err) || f.Pc != tc.pc {
+				t.Errorf("fault = %v at 0x%03x; want %v at 0x%03x", f.Err, f.Pc, tc.err, tc.pc)
+			}
+			if !chip.Halted || chip.Pc != tc.pc {
+				t.Errorf("Halted, Pc = %v, 0x%03x; want true, 0x%03x", chip.Halted, chip.Pc, tc.pc)
+			}
+		})
+	}
+}
+
+func TestFaultSkip(t *testing.T) {
+	for _, tc := range faultCases {
+		t.Run(tc.name, func(t *testing.T) {
+			chip := faultChip(t, tc.program, tc.setup, FaultSkip)
+			if e := chip.handleFault(chip.Cycle()); e != nil {
+				t.Fatalf("error = %v; want the fault skipped", e)
+			}
+			if chip.Halted || chip.Pc != tc.pc+2 {
+				t.Errorf("Halted, Pc = %v, 0x%03x; want false, 0x%03x", chip.Halted, chip.Pc, tc.pc+2)
+			}
+		})
+	}
+}
+
+func TestFaultTrap(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		skip   bool
+		halted bool
+		pc     uint16
+	}{
+		{"resume past fault", true, false, 0x202},
+		{"halt on untouched Pc", false, true, 0x200},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			chip := faultChip(t, []byte{0x00, 0xee}, nil, FaultTrap)
+			var trapped *Fault
+			chip.Trap = func(f *Fault) error {
+				trapped = f
+				if tc.skip {
+					chip.Pc = f.Pc + 2
+				}
+				return nil
+			}
+			e := chip.handleFault(chip.Cycle())
+			if trapped == nil || !errors.Is(trapped, ErrStackUnderflow) {
+				t.Errorf("trapped %v; want %v", trapped, ErrStackUnderflow)
+			}
+			if tc.halted != (e != nil) {
+				t.Errorf("handleFault = %v; want error %v", e, tc.halted)
+			}
+			if chip.Halted != tc.halted || chip.Pc != tc.pc {
+				t.Errorf("Halted, Pc = %v, 0x%03x; want %v, 0x%03x", chip.Halted, chip.Pc, tc.halted, tc.pc)
+			}
+		})
+	}
+}
+
+func TestParseFaultPolicy(t *testing.T) {
+	for _, p := range []FaultPolicy{FaultHalt, FaultSkip, FaultTrap} {
+		if got, e := ParseFaultPolicy(p.String()); e != nil || got != p {
+			t.Errorf("ParseFaultPolicy(%q) = %v, %v", p, got, e)
+		}
+	}
+	if _, e := ParseFaultPolicy("ignore"); e == nil {
+		t.Error("ParseFaultPolicy(ignore) succeeded; want an error")
+	}
+}
@@ -4,6 +4,11 @@ func fontAddr(i uint8) uint16 {
 	return uint16(i * 5)
 }
 
+// bigFontAddr points the SUPER-CHIP 8x10 font which is placed after Font.
+func bigFontAddr(i uint8) uint16 {
+	return uint16(len(Font))*5 + uint16(i&0xf)*10
+}
+
 var Font = [(0x10)][5]byte{
 	{
 		0b11110000,
@@ -118,3 +123,22 @@ var Font = [(0x10)][5]byte{
 		0b10000000,
 	},
 }
+
+var BigFont = [(0x10)][10]byte{
+	{0x3c, 0x7e, 0xe7, 0xc3, 0xc3, 0xc3, 0xc3, 0xe7, 0x7e, 0x3c},
+	{0x18, 0x38, 0x58, 0x18, 0x18, 0x18, 0x18, 0x18, 0x18, 0x3c},
+	{0x3e, 0x7f, 0xc3, 0x06, 0x0c, 0x18, 0x30, 0x60, 0xff, 0xff},
+	{0x3c, 0x7e, 0xc3, 0x03, 0x0e, 0x0e, 0x03, 0xc3, 0x7e, 0x3c},
+	{0x06, 0x0e, 0x1e, 0x36, 0x66, 0xc6, 0xff, 0xff, 0x06, 0x06},
+	{0xff, 0xff, 0xc0, 0xc0, 0xfc, 0xfe, 0x03, 0xc3, 0x7e, 0x3c},
+	{0x3e, 0x7c, 0xc0, 0xc0, 0xfc, 0xfe, 0xc3, 0xc3, 0x7e, 0x3c},
+	{0xff, 0xff, 0x03, 0x06, 0x0c, 0x18, 0x30, 0x60, 0x60, 0x60},
+	{0x3c, 0x7e, 0xc3, 0xc3, 0x7e, 0x7e, 0xc3, 0xc3, 0x7e, 0x3c},
+	{0x3c, 0x7e, 0xc3, 0xc3, 0x7f, 0x3f, 0x03, 0x03, 0x3e, 0x7c},
+	{0x3c, 0x7e, 0xc3, 0xc3, 0xff, 0xff, 0xc3, 0xc3, 0xc3, 0xc3},
+	{0xfc, 0xfe, 0xc3, 0xc3, 0xfe, 0xfe, 0xc3, 0xc3, 0xfe, 0xfc},
+	{0x3c, 0x7e, 0xc3, 0xc0, 0xc0, 0xc0, 0xc0, 0xc3, 0x7e, 0x3c},
+	{0xfc, 0xfe, 0xc3, 0xc3, 0xc3, 0xc3, 0xc3, 0xc3, 0xfe, 0xfc},
+	{0xff, 0xff, 0xc0, 0xc0, 0xfc, 0xfc, 0xc0, 0xc0, 0xff, 0xff},
+	{0xff, 0xff, 0xc0, 0xc0, 0xfc, 0xfc, 0xc0, 0xc0, 0xc0, 0xc0},
+}
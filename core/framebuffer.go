@@ -0,0 +1,194 @@
+package core
+
+import (
+	"strings"
+	"sync"
+)
+
+// FrameBuffer is the Display owning the state of the pixels.
+// It draws sprites with XOR, detects collisions and pushes finished frames to Renderers on Present.
+type FrameBuffer struct {
+	mux       sync.RWMutex
+	dirty     bool
+	hires     bool
+	width     int
+	height    int
+	pixels    []uint8
+	renderers []Renderer
+	// every are the renderers of every frame.
+	every []Renderer
+}
+
+// Renderer paints frames pushed by FrameBuffer.
+type Renderer interface {
+	Render(f *Frame)
+}
+
+// Frame is a snapshot of FrameBuffer.
+// Each pixel holds its bits of XO-CHIP's planes, so it is 0 or 1 for CHIP-8 and SUPER-CHIP programs.
+type Frame struct {
+	Hires  bool
+	Width  int
+	Height int
+	Pixels []uint8
+}
+
+// At returns the pixel at x, y.
+func (f *Frame) At(x, y int) uint8 {
+	return f.Pixels[y*f.Width+x]
+}
+
+// textPixels are characters of pixels indexed by the bits of the planes.
+var textPixels = [4]rune{'.', '#', '+', '@'}
+
+// String renders f as lines of text.
+// A pixel is '#' on the first plane, '+' on the second, '@' on both and '.' otherwise.
+func (f *Frame) String() string {
+	var b strings.Builder
+	for y := 0; y < f.Height; y++ {
+		for x := 0; x < f.Width; x++ {
+			b.WriteRune(textPixels[f.At(x, y)&3])
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// FrameHolder is a Display whose pixels can be saved and restored.
+type FrameHolder interface {
+	Frame() *Frame
+	SetFrame(f *Frame)
+}
+
+var (
+	_ Display     = &FrameBuffer{}
+	_ Presenter   = &FrameBuffer{}
+	_ FrameHolder = &FrameBuffer{}
+)
+
+func NewFrameBuffer(renderers ...Renderer) *FrameBuffer {
+	return &FrameBuffer{
+		width:     WIDTH,
+		height:    HEIGHT,
+		pixels:    make([]uint8, WIDTH*HEIGHT),
+		renderers: renderers,
+	}
+}
+
+// AddRenderer adds r to the renderers of the following frames.
+func (fb *FrameBuffer) AddRenderer(r Renderer) {
+	fb.mux.Lock()
+	defer fb.mux.Unlock()
+	fb.renderers = append(fb.renderers, r)
+	fb.dirty = true
+}
+
+func (fb *FrameBuffer) Clear(planes uint8) {
+	fb.mux.Lock()
+	for i := range fb.pixels {
+		fb.pixels[i] &^= planes
+	}
+	fb.dirty = true
+	fb.mux.Unlock()
+}
+
+func (fb *FrameBuffer) Draw(plane, x, y uint8, sprite []byte) (collision bool) {
+	fb.mux.Lock()
+	for dh, b := range sprite {
+		for dw := 0; dw < 8; dw++ {
+			if b&(0x80>>dw) == 0 {
+				continue
+			}
+			p := &fb.pixels[(int(y)+dh)%fb.height*fb.width+(int(x)+dw)%fb.width]
+			collision = collision || *p&plane != 0
+			*p ^= plane
+		}
+	}
+	fb.dirty = true
+	fb.mux.Unlock()
+	return collision
+}
+
+func (fb *FrameBuffer) SetHires(hires bool) {
+	fb.mux.Lock()
+	if fb.hires == hires {
+		fb.mux.Unlock()
+		return
+	}
+	fb.hires = hires
+	fb.width, fb.height = WIDTH, HEIGHT
+	if hires {
+		fb.width, fb.height = HIRES_WIDTH, HIRES_HEIGHT
+	}
+	fb.pixels = make([]uint8, fb.width*fb.height)
+	fb.dirty = true
+	fb.mux.Unlock()
+}
+
+func (fb *FrameBuffer) Scroll(planes uint8, dx, dy int) {
+	fb.mux.Lock()
+	src := make([]uint8, len(fb.pixels))
+	copy(src, fb.pixels)
+	for y := 0; y < fb.height; y++ {
+		for x := 0; x < fb.width; x++ {
+			var moved uint8
+			if sx, sy := x-dx, y-dy; sx >= 0 && sx < fb.width && sy >= 0 && sy < fb.height {
+				moved = src[sy*fb.width+sx] & planes
+			}
+			p := &fb.pixels[y*fb.width+x]
+			*p = *p&^planes | moved
+		}
+	}
+	fb.dirty = true
+	fb.mux.Unlock()
+}
+
+// Frame returns a snapshot of the current pixels.
+func (fb *FrameBuffer) Frame() *Frame {
+	fb.mux.RLock()
+	defer fb.mux.RUnlock()
+	f := &Frame{
+		Hires:  fb.hires,
+		Width:  fb.width,
+		Height: fb.height,
+		Pixels: make([]uint8, len(fb.pixels)),
+	}
+	copy(f.Pixels, fb.pixels)
+	return f
+}
+
+// SetFrame replaces the pixels with f.
+func (fb *FrameBuffer) SetFrame(f *Frame) {
+	fb.mux.Lock()
+	defer fb.mux.Unlock()
+	fb.hires, fb.width, fb.height = f.Hires, f.Width, f.Height
+	fb.pixels = make([]uint8, len(f.Pixels))
+	copy(fb.pixels, f.Pixels)
+	fb.dirty = true
+}
+
+// AddFrameRenderer adds r to the renderers of every following frame, even if it hasn't changed,
+// e.g. a video encoder of a constant frame rate.
+func (fb *FrameBuffer) AddFrameRenderer(r Renderer) {
+	fb.mux.Lock()
+	defer fb.mux.Unlock()
+	fb.every = append(fb.every, r)
+}
+
+// Present pushes the frame to the renderers if it has changed since the last time, and to the renderers of every frame.
+func (fb *FrameBuffer) Present() {
+	fb.mux.Lock()
+	renderers, dirty := fb.every, fb.dirty
+	if dirty {
+		renderers = append(append([]Renderer{}, fb.renderers...), fb.every...)
+	}
+	fb.dirty = false
+	fb.mux.Unlock()
+	if len(renderers) == 0 {
+		return
+	}
+	f := fb.Frame()
+	for _, r := range renderers {
+		r.Render(f)
+	}
+}
@@ -0,0 +1,97 @@
+package core
+
+import "fmt"
+
+// Until are the conditions stopping RunUntil. Zero values disable them.
+type Until struct {
+	// Cycles is the number of instructions to execute.
+	Cycles uint64
+	// Frames is the number of frames to execute.
+	Frames uint64
+	// PC stops before the instruction at PC. 0 disables it as no program runs there.
+	PC uint16
+	// Idle stops when an instruction leaves Pc as it is, e.g. a jump to itself or Fx0A waiting for a key.
+	Idle bool
+}
+
+// StopReason tells why RunUntil stopped.
+type StopReason int
+
+const (
+	StopCycles StopReason = iota
+	StopFrames
+	StopPC
+	StopIdle
+	// StopExit is the program exited by 00FD.
+	StopExit
+	// StopFault is an instruction faulted.
+	StopFault
+)
+
+func (r StopReason) String() string {
+	switch r {
+	case StopCycles:
+		return "cycles"
+	case StopFrames:
+		return "frames"
+	case StopPC:
+		return "pc"
+	case StopIdle:
+		return "idle"
+	case StopExit:
+		return "exit"
+	case StopFault:
+		return "fault"
+	}
+	return fmt.Sprintf("StopReason(%d)", int(r))
+}
+
+// RunUntil executes frames as fast as possible until one of until is met, the program exits or an instruction faults.
+// Unlike Run it needs neither a terminal nor the wall clock: a nil Display is a FrameBuffer and
+// a nil Keyboard is a Keypad nobody presses, where Fx0A waits forever, i.e. idles.
+// Break is replaced while it runs.
+func (chip *Chip8) RunUntil(until Until) (StopReason, error) {
+	if chip.Display == nil {
+		chip.Display = NewFrameBuffer()
+	}
+	if chip.Keyboard == nil {
+		chip.Keyboard = NewKeypad()
+	}
+	cpu := chip.Cpu
+	begin, frames := chip.Stats.Instructions, uint64(0)
+	var reason StopReason
+	last, started := cpu.Pc, false
+	saved := chip.Break
+	defer func() { chip.Break = saved }()
+	chip.Break = func() bool {
+		executed := chip.Stats.Instructions - begin
+		switch {
+		case until.Cycles > 0 && executed >= until.Cycles:
+			reason = StopCycles
+		case until.PC != 0 && cpu.Pc == until.PC:
+			reason = StopPC
+		case until.Idle && started && cpu.Pc == last:
+			reason = StopIdle
+		default:
+			last, started = cpu.Pc, true
+			return false
+		}
+		return true
+	}
+	for !cpu.Halted {
+		if until.Frames > 0 && frames >= until.Frames {
+			return StopFrames, nil
+		}
+		chip.Lock()
+		e := chip.Frame()
+		chip.Unlock()
+		if e == ErrBreak {
+			return reason, nil
+		}
+		if e != nil {
+			return StopFault, e
+		}
+		frames++
+	}
+	return StopExit, nil
+}
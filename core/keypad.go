@@ -0,0 +1,70 @@
+package core
+
+import (
+	"context"
+	"sync"
+)
+
+// Keypad is a Keyboard pressed by the program, e.g. by a debugger, a script or a test.
+type Keypad struct {
+	mux     sync.Mutex
+	pressed [16]bool
+	events  chan uint8
+}
+
+var _ Keyboard = &Keypad{}
+
+func NewKeypad() *Keypad {
+	return &Keypad{events: make(chan uint8, 16)}
+}
+
+func (k *Keypad) Press(key uint8) {
+	k.mux.Lock()
+	defer k.mux.Unlock()
+	k.pressed[key&0xf] = true
+	select {
+	case k.events <- key & 0xf:
+	default:
+	}
+}
+
+func (k *Keypad) Release(key uint8) {
+	k.mux.Lock()
+	defer k.mux.Unlock()
+	k.pressed[key&0xf] = false
+}
+
+func (k *Keypad) IsPressed(key uint8) bool {
+	k.mux.Lock()
+	defer k.mux.Unlock()
+	return k.pressed[key&0xf]
+}
+
+// WaitKey returns a key already held down at once, otherwise waits for Press.
+// With release the key is released as the keypad can't wait for a release by itself.
+func (k *Keypad) WaitKey(ctx context.Context, release bool) (uint8, bool) {
+	k.mux.Lock()
+	for len(k.events) > 0 {
+		<-k.events
+	}
+	held := -1
+	for key, p := range k.pressed {
+		if p {
+			held = key
+			break
+		}
+	}
+	k.mux.Unlock()
+	key := uint8(held)
+	if held < 0 {
+		select {
+		case <-ctx.Done():
+			return 0, false
+		case key = <-k.events:
+		}
+	}
+	if release {
+		k.Release(key)
+	}
+	return key, true
+}
@@ -9,8 +9,50 @@ import (
 //
 // > The Chip-8 language is capable of accessing up to 4KB (4,096 bytes) of RAM, from location 0x000 (0) to 0xFFF (4095).
 // > ref. http://devernay.free.fr/hacks/chip8/C8TECH10.HTM#2.1
+//
+// XO-CHIP extends it to 64KB.
 type Memory struct {
-	Buf [(0x1000)]uint8
+	Buf []uint8
+	// OnAccess is called on every access of Cpu to Buf when it's not nil, e.g. by watchpoints of a debugger.
+	OnAccess func(kind Access, addr uint16, n int)
+}
+
+// Access is a kind of access to Memory.
+type Access uint8
+
+const (
+	// AccessFetch reads an instruction.
+	AccessFetch Access = 1 << iota
+	AccessRead
+	AccessWrite
+)
+
+func (a Access) String() string {
+	switch a {
+	case AccessFetch:
+		return "fetch"
+	case AccessRead:
+		return "read"
+	case AccessWrite:
+		return "write"
+	}
+	return fmt.Sprintf("Access(%d)", uint8(a))
+}
+
+// access reports n bytes from addr accessed by Cpu.
+func (m *Memory) access(kind Access, addr uint16, n int) {
+	if m.OnAccess != nil {
+		m.OnAccess(kind, addr, n)
+	}
+}
+
+const (
+	MemorySize   = 0x1000
+	XOMemorySize = 0x10000
+)
+
+func NewMemory(size int) *Memory {
+	return &Memory{Buf: make([]uint8, size)}
 }
 
 // The first 512 bytes, from 0x000 to 0x1FF, are where the original interpreter was located, and should not be used by programs.
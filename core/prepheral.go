@@ -8,21 +8,50 @@ type Buzzer interface {
 	Stop()
 }
 
+// AudioBuzzer is a Buzzer which can play XO-CHIP's audio pattern.
+type AudioBuzzer interface {
+	Buzzer
+	SetPattern(pattern [16]uint8)
+	SetPitch(pitch uint8)
+}
+
 // Display
 //
 // > The original implementation of the Chip-8 language used a 64x32-pixel monochrome display with this format:
 // > http://devernay.free.fr/hacks/chip8/C8TECH10.HTM#2.4
+//
+// SUPER-CHIP adds a 128x64-pixel high resolution mode which can be switched at runtime,
+// and scrolling of the whole screen.
+// XO-CHIP adds the second bit plane. Each pixel shows one of four colours by its bits of the planes.
+// planes is a mask of the bit planes, 1 is the first plane and 2 is the second.
 type Display interface {
-	Clear()
-	Draw(x, y uint8, sprite []byte) (collision bool)
+	Clear(planes uint8)
+	// Draw draws sprite on a single plane.
+	Draw(plane uint8, x, y uint8, sprite []byte) (collision bool)
+	SetHires(hires bool)
+	// Scroll moves every pixel by dx, dy in the current resolution. Pixels moved out of the screen are lost.
+	Scroll(planes uint8, dx, dy int)
+}
+
+// Presenter is a Display showing the result of drawing at once when Present is called.
+// Chip8 presents once per frame.
+type Presenter interface {
+	Present()
 }
 
 const (
 	WIDTH  = 64
 	HEIGHT = 32
+
+	HIRES_WIDTH  = 128
+	HIRES_HEIGHT = 64
 )
 
+// Keyboard is the 16-key hexadecimal keypad.
 type Keyboard interface {
 	IsPressed(key uint8) bool
-	Wait(ctx context.Context, key uint8)
+	// WaitKey blocks until any key is pressed and returns it.
+	// With release it also waits for the key to be released as the COSMAC VIP did.
+	// ok is false when ctx is done before that.
+	WaitKey(ctx context.Context, release bool) (key uint8, ok bool)
 }
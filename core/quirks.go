@@ -0,0 +1,75 @@
+package core
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Quirks selects how ambiguous instructions behave.
+//
+// > Different interpreters made different choices for some instructions and programs depend on them.
+// > ref. https://github.com/Timendus/chip8-test-suite#quirks-test
+type Quirks struct {
+	// ShiftVy makes 8xy6/8xyE shift Vy and store the result in Vx (COSMAC VIP).
+	// Otherwise Vx is shifted in place (CHIP-48, SUPER-CHIP).
+	ShiftVy bool
+	// LoadStoreIncI makes Fx55/Fx65 leave I pointing after the last register (COSMAC VIP).
+	LoadStoreIncI bool
+	// JumpVx makes Bnnn behave as Bxnn, jumping to xnn + Vx (CHIP-48, SUPER-CHIP).
+	JumpVx bool
+	// VFReset makes 8xy1/8xy2/8xy3 reset VF to 0 (COSMAC VIP).
+	VFReset bool
+	// ClipSprites makes Dxyn clip sprites at the screen edge instead of wrapping them around.
+	ClipSprites bool
+	// DisplayWait makes Dxyn wait for the next vertical blank (COSMAC VIP).
+	DisplayWait bool
+	// WaitRelease makes Fx0A wait until the pressed key is released (COSMAC VIP).
+	WaitRelease bool
+}
+
+// QuirkPresets are the named quirk profiles of well-known interpreters.
+var QuirkPresets = map[string]Quirks{
+	"cosmac-vip": {
+		ShiftVy:       true,
+		LoadStoreIncI: true,
+		VFReset:       true,
+		ClipSprites:   true,
+		DisplayWait:   true,
+		WaitRelease:   true,
+	},
+	"chip-48": {
+		JumpVx:      true,
+		ClipSprites: true,
+	},
+	"super-chip": {
+		JumpVx:      true,
+		ClipSprites: true,
+	},
+	"xo-chip": {
+		ShiftVy:       true,
+		LoadStoreIncI: true,
+	},
+	"modern": {},
+}
+
+// DefaultQuirks is the name of the preset used when none is given.
+const DefaultQuirks = "modern"
+
+// LookupQuirks returns the preset registered as name.
+func LookupQuirks(name string) (Quirks, error) {
+	q, ok := QuirkPresets[name]
+	if !ok {
+		return Quirks{}, fmt.Errorf("unknown quirks `%s` (available: %v)", name, QuirkPresetNames())
+	}
+	return q, nil
+}
+
+// QuirkPresetNames returns the names of QuirkPresets in order.
+func QuirkPresetNames() []string {
+	names := make([]string, 0, len(QuirkPresets))
+	for n := range QuirkPresets {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,123 @@
+package core
+
+import (
+	"reflect"
+	"testing"
+)
+
+// quirkProbes run an instruction which behaves differently by a quirk and tell whether the quirk took effect.
+var quirkProbes = map[string]func(t *testing.T, q Quirks) bool{
+	"ShiftVy": func(t *testing.T, q Quirks) bool {
+		chip := newQuirkChip(t, q, 0x81, 0x26) // SHR V1, V2
+		chip.V[1], chip.V[2] = 0x04, 0x80
+		cycle(t, chip)
+		return chip.V[1] == 0x40
+	},
+	"LoadStoreIncI": func(t *testing.T, q Quirks) bool {
+		chip := newQuirkChip(t, q, 0xf1, 0x55) // LD [I], V1
+		chip.I = 0x300
+		cycle(t, chip)
+		return chip.I == 0x302
+	},
+	"JumpVx": func(t *testing.T, q Quirks) bool {
+		chip := newQuirkChip(t, q, 0xb2, 0x40) // JP V0, 0x240
+		chip.V[0], chip.V[2] = 0x10, 0x20
+		cycle(t, chip)
+		return chip.Pc == 0x260
+	},
+	"VFReset": func(t *testing.T, q Quirks) bool {
+		chip := newQuirkChip(t, q, 0x81, 0x21) // OR V1, V2
+		chip.V[0xF] = 5
+		cycle(t, chip)
+		return chip.V[0xF] == 0
+	},
+	"ClipSprites": func(t *testing.T, q Quirks) bool {
+		chip := newQuirkChip(t, q, 0xd1, 0x21) // DRW V1, V2, 1
+		// the top row of the font of 0 is 0xF0, whose last 2 pixels are over the right edge.
+		chip.V[1], chip.I = WIDTH-2, 0
+		cycle(t, chip)
+		return chip.Display.(*testDisplay).sprite[0] == 0xc0
+	},
+	"DisplayWait": func(t *testing.T, q Quirks) bool {
+		chip := newQuirkChip(t, q, 0xd1, 0x21) // DRW V1, V2, 1
+		cycle(t, chip)
+		return chip.waitVBlank
+	},
+	"WaitRelease": func(t *testing.T, q Quirks) bool {
+		chip := newQuirkChip(t, q, 0xf1, 0x0a) // LD V1, K
+		chip.Keyboard.(*testKeyboard).key = 5
+		cycle(t, chip)
+		if chip.V[1] != 5 {
+			t.Fatalf("V1 = %d; want the key 5", chip.V[1])
+		}
+		return chip.Keyboard.(*testKeyboard).release
+	},
+}
+
+func newQuirkChip(t *testing.T, q Quirks, program ...byte) *Chip8 {
+	t.Helper()
+	chip := newTestChip(t, program...)
+	chip.Quirks = q
+	return chip
+}
+
+func cycle(t *testing.T, chip *Chip8) {
+	t.Helper()
+	if e := chip.Cycle(); e != nil {
+		t.Fatal(e)
+	}
+}
+
+func TestQuirkPresets(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		on   []string
+	}{
+		{"cosmac-vip", []string{"ShiftVy", "LoadStoreIncI", "VFReset", "ClipSprites", "DisplayWait", "WaitRelease"}},
+		{"chip-48", []string{"JumpVx", "ClipSprites"}},
+		{"super-chip", []string{"JumpVx", "ClipSprites"}},
+		{"xo-chip", []string{"ShiftVy", "LoadStoreIncI"}},
+		{"modern", nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			q, e := LookupQuirks(tc.name)
+			if e != nil {
+				t.Fatal(e)
+			}
+			on := map[string]bool{}
+			for _, name := range tc.on {
+				on[name] = true
+			}
+			for name, probe := range quirkProbes {
+				if got := probe(t, q); got != on[name] {
+					t.Errorf("%s = %v; want %v", name, got, on[name])
+				}
+			}
+		})
+	}
+}
+
+// TestQuirkProbes tests that every field of Quirks is probed.
+func TestQuirkProbes(t *testing.T) {
+	typ := reflect.TypeOf(Quirks{})
+	if typ.NumField() != len(quirkProbes) {
+		t.Errorf("%d quirks are probed; want %d", len(quirkProbes), typ.NumField())
+	}
+	for i := 0; i < typ.NumField(); i++ {
+		if _, ok := quirkProbes[typ.Field(i).Name]; !ok {
+			t.Errorf("%s isn't probed", typ.Field(i).Name)
+		}
+	}
+}
+
+func TestLookupQuirks(t *testing.T) {
+	if _, e := LookupQuirks(DefaultQuirks); e != nil {
+		t.Errorf("LookupQuirks(DefaultQuirks): %v", e)
+	}
+	if _, e := LookupQuirks("unknown"); e == nil {
+		t.Error("LookupQuirks(unknown) succeeded; want an error")
+	}
+	if names := QuirkPresetNames(); len(names) != len(QuirkPresets) {
+		t.Errorf("QuirkPresetNames() = %v", names)
+	}
+}
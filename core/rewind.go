@@ -0,0 +1,84 @@
+package core
+
+import (
+	"sync"
+	"time"
+)
+
+// Rewinder keeps snapshots of the last frames in a ring buffer.
+// While it's held, Chip8.Run steps backwards through them instead of executing frames.
+type Rewinder struct {
+	mux   sync.Mutex
+	buf   []*Snapshot
+	head  int
+	size  int
+	until time.Time
+}
+
+// NewRewinder returns Rewinder keeping the last frames.
+func NewRewinder(frames int) *Rewinder {
+	return &Rewinder{buf: make([]*Snapshot, frames)}
+}
+
+// Push records s as the latest frame. The oldest frame is dropped when the buffer is full.
+func (r *Rewinder) Push(s *Snapshot) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if len(r.buf) == 0 {
+		return
+	}
+	r.buf[r.head] = s
+	r.head = (r.head + 1) % len(r.buf)
+	if r.size < len(r.buf) {
+		r.size++
+	}
+}
+
+// Pop takes the latest frame out.
+func (r *Rewinder) Pop() (*Snapshot, bool) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	if r.size == 0 {
+		return nil, false
+	}
+	r.head = (r.head - 1 + len(r.buf)) % len(r.buf)
+	s := r.buf[r.head]
+	r.buf[r.head] = nil
+	r.size--
+	return s, true
+}
+
+// Len is the number of recorded frames.
+func (r *Rewinder) Len() int {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	return r.size
+}
+
+// Hold keeps rewinding for d. Call it repeatedly while a key is held down.
+func (r *Rewinder) Hold(d time.Duration) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	r.until = time.Now().Add(d)
+}
+
+// Holding is true while rewinding.
+func (r *Rewinder) Holding() bool {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+	return time.Now().Before(r.until)
+}
+
+// Rewind restores chip to the latest recorded frame and presents it.
+// chip stays as it is when no frame is left.
+func (r *Rewinder) Rewind(chip *Chip8) error {
+	s, ok := r.Pop()
+	if !ok {
+		return nil
+	}
+	if e := chip.Restore(s); e != nil {
+		return e
+	}
+	chip.present()
+	return nil
+}
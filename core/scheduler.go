@@ -0,0 +1,120 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// FrameRate is the rate of frames and of DT and ST counting down. The display is presented once per frame.
+const FrameRate = 60
+
+// DefaultIPF is the number of instructions per frame used when Chip8.IPF is 0.
+const DefaultIPF = 10
+
+// ErrBreak is returned by Frame and Run when Chip8.Break stops them.
+var ErrBreak = errors.New("break")
+
+// Stats are measured by Chip8.Run.
+type Stats struct {
+	Instructions uint64
+	Frames       uint64
+	Elapsed      time.Duration
+}
+
+// IPS is the measured number of instructions per second.
+func (s Stats) IPS() float64 {
+	if s.Elapsed <= 0 {
+		return 0
+	}
+	return float64(s.Instructions) / s.Elapsed.Seconds()
+}
+
+// Run runs frames at FrameRate until ctx is done, the program exits or an instruction faults.
+func (chip *Chip8) Run(ctx context.Context) error {
+	ticker := time.NewTicker(time.Second / FrameRate)
+	defer ticker.Stop()
+	begin := time.Now()
+	defer func() {
+		chip.Stats.Elapsed += time.Since(begin)
+	}()
+	for !chip.Cpu.Halted {
+		chip.Lock()
+		e := chip.step()
+		chip.Unlock()
+		if e != nil {
+			return e
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	return nil
+}
+
+// step rewinds a frame while Rewinder is held, or executes a frame and records it.
+func (chip *Chip8) step() error {
+	r := chip.Rewinder
+	if r == nil {
+		return chip.Frame()
+	}
+	if r.Holding() {
+		return r.Rewind(chip)
+	}
+	if e := chip.Frame(); e != nil {
+		return e
+	}
+	r.Push(chip.Snapshot())
+	return nil
+}
+
+// polling is the done context making Keyboard.WaitKey of Fx0A return at once within a frame.
+var polling = func() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}()
+
+// Frame executes IPF instructions in a burst, tells the end of the frame to Clock and presents the display.
+// With Quirks.DisplayWait the burst ends at the first Dxyn.
+// Fx0A doesn't block: while no key is pressed the burst ends there and the next frame waits again,
+// so the display is presented and the timers count down in the meantime.
+// When Break returns true, Frame presents the display and returns ErrBreak before the instruction.
+func (chip *Chip8) Frame() error {
+	cpu := chip.Cpu
+	ipf := chip.IPF
+	if ipf <= 0 {
+		ipf = DefaultIPF
+	}
+	// a single step outside frames, e.g. by a debugger, may have left these set.
+	cpu.waitVBlank, cpu.waitKey = false, false
+	for i := 0; i < ipf && !cpu.Halted; i++ {
+		if chip.Break != nil && chip.Break() {
+			chip.present()
+			return ErrBreak
+		}
+		e := cpu.Cycle(polling, chip.Memory, chip.Display, chip.Keyboard, chip.Buzzer)
+		chip.Stats.Instructions++
+		if e != nil {
+			if e = cpu.handleFault(e); e != nil {
+				return e
+			}
+		}
+		if cpu.waitVBlank || cpu.waitKey {
+			cpu.waitVBlank, cpu.waitKey = false, false
+			break
+		}
+	}
+	cpu.Clock.Frame()
+	chip.present()
+	chip.Stats.Frames++
+	return nil
+}
+
+func (chip *Chip8) present() {
+	if p, ok := chip.Display.(Presenter); ok {
+		p.Present()
+	}
+}
@@ -0,0 +1,57 @@
+package core
+
+import "testing"
+
+type countingRenderer struct {
+	frames int
+}
+
+func (r *countingRenderer) Render(f *Frame) {
+	r.frames++
+}
+
+func TestFrameWaitsKeyWithoutBlocking(t *testing.T) {
+	// LD V0, 5; LD DT, V0; LD F, V0; DRW V0, V0, 5; LD V1, K; JP 0x20a
+	chip := newTestChip(t, 0x60, 0x05, 0xf0, 0x15, 0xf0, 0x29, 0xd0, 0x05, 0xf1, 0x0a, 0x12, 0x0a)
+	r := &countingRenderer{}
+	chip.Display = NewFrameBuffer(r)
+	keys := &testKeyboard{idle: true}
+	chip.Keyboard = keys
+	for i := 0; i < 3; i++ {
+		if e := chip.Frame(); e != nil {
+			t.Fatal(e)
+		}
+		if chip.Pc != 0x208 {
+			t.Fatalf("frame %d: Pc = 0x%03x; want 0x208 waiting for a key", i, chip.Pc)
+		}
+	}
+	if r.frames != 1 {
+		t.Errorf("presented %d frames; want the sprite drawn before waiting", r.frames)
+	}
+	if dt := chip.Dt.GetV(); dt != 2 {
+		t.Errorf("DT = %d; want 2 counted down by 3 frames", dt)
+	}
+	keys.idle, keys.key = false, 0xa
+	if e := chip.Frame(); e != nil {
+		t.Fatal(e)
+	}
+	if chip.V[1] != 0xa || chip.Pc != 0x20a {
+		t.Errorf("V1, Pc = 0x%x, 0x%03x; want 0xa, 0x20a", chip.V[1], chip.Pc)
+	}
+}
+
+func TestFrameAfterSingleStep(t *testing.T) {
+	// DRW V0, V0, 1; JP 0x202
+	chip := newTestChip(t, 0xd0, 0x01, 0x12, 0x02)
+	chip.Quirks.DisplayWait = true
+	chip.IPF = 4
+	if e := chip.Cycle(); e != nil {
+		t.Fatal(e)
+	}
+	if e := chip.Frame(); e != nil {
+		t.Fatal(e)
+	}
+	if n := chip.Stats.Instructions; n != 4 {
+		t.Errorf("executed %d instructions in the frame after the step of Dxyn; want 4", n)
+	}
+}
@@ -0,0 +1,261 @@
+package core
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// StateVersion is the version of the format written by SaveState.
+//
+// The format is the magic "GC8S" and the version as big endian uint16
+// followed by gzip compressed fields of Snapshot in order.
+const StateVersion = 1
+
+const stateMagic = "GC8S"
+
+var (
+	ErrStateFormat  = errors.New("not a save state")
+	ErrStateVersion = errors.New("unsupported save state version")
+	ErrStateROM     = errors.New("save state is made by another ROM")
+)
+
+// CpuState is the state of Cpu saved in Snapshot.
+type CpuState struct {
+	V       [16]uint8
+	I       uint16
+	Pc      uint16
+	Sp      uint8
+	Stack   [16]uint16
+	Dt      uint8
+	St      uint8
+	RPL     [16]uint8
+	Hires   bool
+	Halted  bool
+	Planes  uint8
+	Pattern [16]uint8
+	Pitch   uint8
+}
+
+// Snapshot is a copy of the whole state of Chip8.
+type Snapshot struct {
+	ROMHash [32]byte
+	Cpu     CpuState
+	Memory  []uint8
+	// Frame is nil when Display isn't a FrameHolder.
+	Frame *Frame
+	// Keys has the bit of each pressed key.
+	Keys uint16
+}
+
+// Snapshot copies the state of chip. Call it between frames.
+func (chip *Chip8) Snapshot() *Snapshot {
+	cpu := chip.Cpu
+	s := &Snapshot{
+		ROMHash: chip.ROMHash,
+		Cpu: CpuState{
+			V:       cpu.V,
+			I:       cpu.I,
+			Pc:      cpu.Pc,
+			Sp:      cpu.Sp,
+			Stack:   cpu.Stack,
+			Dt:      cpu.Dt.GetV(),
+			St:      cpu.St.GetV(),
+			RPL:     cpu.RPL,
+			Hires:   cpu.Hires,
+			Halted:  cpu.Halted,
+			Planes:  cpu.Planes,
+			Pattern: cpu.Pattern,
+			Pitch:   cpu.Pitch,
+		},
+		Memory: make([]uint8, len(chip.Memory.Buf)),
+	}
+	copy(s.Memory, chip.Memory.Buf)
+	if h, ok := chip.Display.(FrameHolder); ok {
+		s.Frame = h.Frame()
+	}
+	if chip.Keyboard != nil {
+		for k := uint8(0); k < 16; k++ {
+			if chip.Keyboard.IsPressed(k) {
+				s.Keys |= 1 << k
+			}
+		}
+	}
+	return s
+}
+
+// Restore puts chip back to s. Keys are left as they are on the host.
+func (chip *Chip8) Restore(s *Snapshot) error {
+	if s.ROMHash != chip.ROMHash {
+		return ErrStateROM
+	}
+	if len(s.Memory) != len(chip.Memory.Buf) {
+		return fmt.Errorf("%w: memory size %d != %d", ErrStateFormat, len(s.Memory), len(chip.Memory.Buf))
+	}
+	if e := s.validate(); e != nil {
+		return e
+	}
+	cpu := chip.Cpu
+	cpu.V, cpu.I, cpu.Pc, cpu.Sp, cpu.Stack = s.Cpu.V, s.Cpu.I, s.Cpu.Pc, s.Cpu.Sp, s.Cpu.Stack
+	cpu.Dt.SetV(s.Cpu.Dt)
+	cpu.St.SetV(s.Cpu.St)
+	cpu.RPL, cpu.Hires, cpu.Halted = s.Cpu.RPL, s.Cpu.Hires, s.Cpu.Halted
+	cpu.Planes, cpu.Pattern, cpu.Pitch = s.Cpu.Planes, s.Cpu.Pattern, s.Cpu.Pitch
+	copy(chip.Memory.Buf, s.Memory)
+	if h, ok := chip.Display.(FrameHolder); ok && s.Frame != nil {
+		h.SetFrame(s.Frame)
+	}
+	return nil
+}
+
+// SaveState writes the snapshot of chip to w. Call it between frames.
+func (chip *Chip8) SaveState(w io.Writer) error {
+	return chip.Snapshot().Encode(w)
+}
+
+// LoadState restores chip from the state written by SaveState.
+func (chip *Chip8) LoadState(r io.Reader) error {
+	s, e := DecodeSnapshot(r)
+	if e != nil {
+		return e
+	}
+	return chip.Restore(s)
+}
+
+// Encode writes s in the format of SaveState.
+func (s *Snapshot) Encode(w io.Writer) error {
+	if _, e := io.WriteString(w, stateMagic); e != nil {
+		return e
+	}
+	if e := binary.Write(w, binary.BigEndian, uint16(StateVersion)); e != nil {
+		return e
+	}
+	z := gzip.NewWriter(w)
+	bw := &binaryWriter{w: z}
+	bw.write(s.ROMHash)
+	bw.write(s.Cpu)
+	bw.write(s.Keys)
+	bw.write(uint32(len(s.Memory)))
+	bw.write(s.Memory)
+	bw.write(s.Frame != nil)
+	if s.Frame != nil {
+		bw.write(s.Frame.Hires)
+		bw.write(uint16(s.Frame.Width))
+		bw.write(uint16(s.Frame.Height))
+		bw.write(s.Frame.Pixels)
+	}
+	if bw.err != nil {
+		return bw.err
+	}
+	return z.Close()
+}
+
+// DecodeSnapshot reads the snapshot written by Encode.
+func DecodeSnapshot(r io.Reader) (*Snapshot, error) {
+	br := bufio.NewReader(r)
+	magic := make([]byte, len(stateMagic))
+	if _, e := io.ReadFull(br, magic); e != nil || string(magic) != stateMagic {
+		return nil, ErrStateFormat
+	}
+	var version uint16
+	if e := binary.Read(br, binary.BigEndian, &version); e != nil {
+		return nil, ErrStateFormat
+	}
+	if version != StateVersion {
+		return nil, fmt.Errorf("%w: %d", ErrStateVersion, version)
+	}
+	z, e := gzip.NewReader(br)
+	if e != nil {
+		return nil, fmt.Errorf("%w: %v", ErrStateFormat, e)
+	}
+	defer z.Close()
+	rr := &binaryReader{r: z}
+	s := &Snapshot{}
+	rr.read(&s.ROMHash)
+	rr.read(&s.Cpu)
+	rr.read(&s.Keys)
+	var size uint32
+	rr.read(&size)
+	if size > XOMemorySize {
+		return nil, fmt.Errorf("%w: memory size %d", ErrStateFormat, size)
+	}
+	s.Memory = make([]uint8, size)
+	rr.read(s.Memory)
+	var hasFrame bool
+	rr.read(&hasFrame)
+	if hasFrame {
+		var w, h uint16
+		f := &Frame{}
+		rr.read(&f.Hires)
+		rr.read(&w)
+		rr.read(&h)
+		if !validFrameSize(f.Hires, int(w), int(h)) {
+			return nil, fmt.Errorf("%w: frame size %dx%d", ErrStateFormat, w, h)
+		}
+		f.Width, f.Height = int(w), int(h)
+		f.Pixels = make([]uint8, f.Width*f.Height)
+		rr.read(f.Pixels)
+		s.Frame = f
+	}
+	if rr.err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrStateFormat, rr.err)
+	}
+	if e := s.validate(); e != nil {
+		return nil, e
+	}
+	return s, nil
+}
+
+// validate rejects the states which would crash the machine.
+func (s *Snapshot) validate() error {
+	if int(s.Cpu.Sp) > len(s.Cpu.Stack) {
+		return fmt.Errorf("%w: stack pointer %d", ErrStateFormat, s.Cpu.Sp)
+	}
+	if f := s.Frame; f != nil {
+		if f.Hires != s.Cpu.Hires || !validFrameSize(f.Hires, f.Width, f.Height) || len(f.Pixels) != f.Width*f.Height {
+			return fmt.Errorf("%w: frame size %dx%d in %s resolution", ErrStateFormat, f.Width, f.Height, resolution(s.Cpu.Hires))
+		}
+	}
+	return nil
+}
+
+// validFrameSize tells whether w x h is the size of the resolution.
+func validFrameSize(hires bool, w, h int) bool {
+	if hires {
+		return w == HIRES_WIDTH && h == HIRES_HEIGHT
+	}
+	return w == WIDTH && h == HEIGHT
+}
+
+func resolution(hires bool) string {
+	if hires {
+		return "high"
+	}
+	return "low"
+}
+
+// binaryWriter keeps the first error of consecutive writes.
+type binaryWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (bw *binaryWriter) write(v interface{}) {
+	if bw.err == nil {
+		bw.err = binary.Write(bw.w, binary.BigEndian, v)
+	}
+}
+
+type binaryReader struct {
+	r   io.Reader
+	err error
+}
+
+func (br *binaryReader) read(v interface{}) {
+	if br.err == nil {
+		br.err = binary.Read(br.r, binary.BigEndian, v)
+	}
+}
@@ -0,0 +1,81 @@
+package core
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestStateRoundTrip(t *testing.T) {
+	// LD V0, 5; LD DT, V0; CALL 0x208; JP 0x206; HIGH; LD F, V0; DRW V0, V0, 5; JP 0x20e
+	program := []byte{0x60, 0x05, 0xf0, 0x15, 0x22, 0x08, 0x12, 0x06, 0x00, 0xff, 0xf0, 0x29, 0xd0, 0x05, 0x12, 0x0e}
+	chip := newTestChip(t, program...)
+	chip.Display = NewFrameBuffer()
+	for i := 0; i < 7; i++ {
+		if e := chip.Cycle(); e != nil {
+			t.Fatal(e)
+		}
+	}
+	var b bytes.Buffer
+	if e := chip.SaveState(&b); e != nil {
+		t.Fatal(e)
+	}
+
+	restored := newTestChip(t, program...)
+	restored.Display = NewFrameBuffer()
+	if e := restored.LoadState(&b); e != nil {
+		t.Fatal(e)
+	}
+	want, got := chip.Snapshot(), restored.Snapshot()
+	if want.Cpu != got.Cpu {
+		t.Errorf("Cpu = %+v; want %+v", got.Cpu, want.Cpu)
+	}
+	if !bytes.Equal(want.Memory, got.Memory) {
+		t.Error("memory differs")
+	}
+	if !reflect.DeepEqual(want.Frame, got.Frame) || !got.Frame.Hires {
+		t.Error("frame differs")
+	}
+}
+
+func TestStateRejects(t *testing.T) {
+	chip := newTestChip(t, 0x12, 0x00)
+	for _, tc := range []struct {
+		name   string
+		modify func(s *Snapshot)
+		want   error
+	}{
+		{"another ROM", func(s *Snapshot) { s.ROMHash[0]++ }, ErrStateROM},
+		{"stack pointer over the stack", func(s *Snapshot) { s.Cpu.Sp = 17 }, ErrStateFormat},
+		{"empty frame", func(s *Snapshot) { s.Frame = &Frame{} }, ErrStateFormat},
+		{"frame of 0 width", func(s *Snapshot) {
+			s.Frame = &Frame{Width: 0, Height: HEIGHT}
+		}, ErrStateFormat},
+		{"high resolution frame in low resolution", func(s *Snapshot) {
+			s.Frame = &Frame{Hires: true, Width: HIRES_WIDTH, Height: HIRES_HEIGHT, Pixels: make([]uint8, HIRES_WIDTH*HIRES_HEIGHT)}
+		}, ErrStateFormat},
+		{"memory of another size", func(s *Snapshot) { s.Memory = s.Memory[:0x800] }, ErrStateFormat},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s := chip.Snapshot()
+			tc.modify(s)
+			var b bytes.Buffer
+			if e := s.Encode(&b); e != nil {
+				t.Fatal(e)
+			}
+			if e := chip.LoadState(&b); !errors.Is(e, tc.want) {
+				t.Errorf("LoadState = %v; want %v", e, tc.want)
+			}
+		})
+	}
+}
+
+func TestStateRejectsGarbage(t *testing.T) {
+	chip := newTestChip(t, 0x12, 0x00)
+	for _, b := range [][]byte{nil, []byte("GC8"), []byte("GC8S\x00\x01garbage")} {
+		if e := chip.LoadState(bytes.NewReader(b)); !errors.Is(e, ErrStateFormat) {
+			t.Errorf("LoadState(%q) = %v; want %v", b, e, ErrStateFormat)
+		}
+	}
+}
@@ -2,13 +2,12 @@ package core
 
 import (
 	"sync"
-	"time"
 )
 
 type DelayedTimer struct {
-	mux    sync.Mutex
-	ticker *time.Ticker
-	h      TimerHandler
+	mux         sync.Mutex
+	h           TimerHandler
+	unsubscribe func()
 
 	v uint8
 }
@@ -18,27 +17,34 @@ type TimerHandler interface {
 	Stop()
 }
 
-func NewDelayedTimer(hz uint, h TimerHandler) *DelayedTimer {
+// NewDelayedTimer returns the timer counting down on every tick of clock.
+func NewDelayedTimer(clock Clock, h TimerHandler) *DelayedTimer {
 	t := &DelayedTimer{
-		ticker: time.NewTicker(time.Second / time.Duration(hz)),
-		h:      h,
+		h: h,
 	}
-	go func() {
-		for {
-			_ = <-t.ticker.C
-			t.mux.Lock()
-			if t.v > 0 {
-				t.v--
-				if t.v == 0 && t.h != nil {
-					t.h.Stop()
-				}
-			}
-			t.mux.Unlock()
-		}
-	}()
+	t.unsubscribe = clock.Subscribe(t.Tick)
 	return t
 }
 
+// Tick counts down the timer.
+func (dt *DelayedTimer) Tick() {
+	dt.mux.Lock()
+	defer dt.mux.Unlock()
+	if dt.v > 0 {
+		dt.v--
+		if dt.v == 0 && dt.h != nil {
+			dt.h.Stop()
+		}
+	}
+}
+
+// SetHandler replaces the handler started and stopped by the timer.
+func (dt *DelayedTimer) SetHandler(h TimerHandler) {
+	dt.mux.Lock()
+	defer dt.mux.Unlock()
+	dt.h = h
+}
+
 func (dt *DelayedTimer) GetV() uint8 {
 	dt.mux.Lock()
 	defer dt.mux.Unlock()
@@ -48,8 +54,22 @@ func (dt *DelayedTimer) GetV() uint8 {
 func (dt *DelayedTimer) SetV(v uint8) {
 	dt.mux.Lock()
 	defer dt.mux.Unlock()
-	if dt.v == 0 && dt.h != nil {
-		defer dt.h.Start()
+	if dt.h != nil {
+		if dt.v == 0 && v > 0 {
+			defer dt.h.Start()
+		} else if dt.v > 0 && v == 0 {
+			defer dt.h.Stop()
+		}
 	}
 	dt.v = v
 }
+
+// Close detaches the timer from its clock. The timer doesn't count down any more.
+func (dt *DelayedTimer) Close() {
+	dt.mux.Lock()
+	defer dt.mux.Unlock()
+	if dt.unsubscribe != nil {
+		dt.unsubscribe()
+		dt.unsubscribe = nil
+	}
+}
@@ -0,0 +1,158 @@
+package dap
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"sync"
+)
+
+// request is a request from the client.
+// > ref. https://microsoft.github.io/debug-adapter-protocol/specification#Base_Protocol_Request
+type request struct {
+	Seq       int             `json:"seq"`
+	Type      string          `json:"type"`
+	Command   string          `json:"command"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+type response struct {
+	Seq        int         `json:"seq"`
+	Type       string      `json:"type"`
+	RequestSeq int         `json:"request_seq"`
+	Success    bool        `json:"success"`
+	Command    string      `json:"command"`
+	Message    string      `json:"message,omitempty"`
+	Body       interface{} `json:"body,omitempty"`
+}
+
+type event struct {
+	Seq   int         `json:"seq"`
+	Type  string      `json:"type"`
+	Event string      `json:"event"`
+	Body  interface{} `json:"body,omitempty"`
+}
+
+// stream reads and writes messages framed by Content-Length headers.
+type stream struct {
+	r *textproto.Reader
+
+	mux sync.Mutex
+	w   io.Writer
+	seq int
+}
+
+func newStream(r io.Reader, w io.Writer) *stream {
+	return &stream{r: textproto.NewReader(bufio.NewReader(r)), w: w}
+}
+
+func (s *stream) read() (*request, error) {
+	h, e := s.r.ReadMIMEHeader()
+	if e != nil {
+		return nil, e
+	}
+	n, e := strconv.Atoi(h.Get("Content-Length"))
+	if e != nil || n < 0 {
+		return nil, fmt.Errorf("invalid Content-Length: %q", h.Get("Content-Length"))
+	}
+	body := make([]byte, n)
+	if _, e := io.ReadFull(s.r.R, body); e != nil {
+		return nil, e
+	}
+	req := &request{}
+	if e := json.Unmarshal(body, req); e != nil {
+		return nil, e
+	}
+	return req, nil
+}
+
+// write sends a response or an event numbered by the stream.
+func (s *stream) write(m interface{}) error {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+	s.seq++
+	switch m := m.(type) {
+	case *response:
+		m.Seq = s.seq
+	case *event:
+		m.Seq = s.seq
+	}
+	body, e := json.Marshal(m)
+	if e != nil {
+		return e
+	}
+	_, e = fmt.Fprintf(s.w, "Content-Length: %d\r\n\r\n%s", len(body), body)
+	return e
+}
+
+func (s *stream) respond(req *request, body interface{}) error {
+	return s.write(&response{Type: "response", RequestSeq: req.Seq, Success: true, Command: req.Command, Body: body})
+}
+
+func (s *stream) fail(req *request, e error) error {
+	return s.write(&response{Type: "response", RequestSeq: req.Seq, Command: req.Command, Message: e.Error()})
+}
+
+func (s *stream) event(name string, body interface{}) error {
+	return s.write(&event{Type: "event", Event: name, Body: body})
+}
+
+// Types of the bodies. Only the fields used by the server are declared.
+
+type capabilities struct {
+	SupportsConfigurationDoneRequest bool `json:"supportsConfigurationDoneRequest"`
+	SupportsConditionalBreakpoints   bool `json:"supportsConditionalBreakpoints"`
+	SupportsInstructionBreakpoints   bool `json:"supportsInstructionBreakpoints"`
+	SupportsReadMemoryRequest        bool `json:"supportsReadMemoryRequest"`
+	SupportsDisassembleRequest       bool `json:"supportsDisassembleRequest"`
+	SupportsSetVariable              bool `json:"supportsSetVariable"`
+	SupportsTerminateRequest         bool `json:"supportsTerminateRequest"`
+}
+
+type source struct {
+	Name string `json:"name,omitempty"`
+	Path string `json:"path,omitempty"`
+}
+
+type breakpoint struct {
+	ID                   int     `json:"id,omitempty"`
+	Verified             bool    `json:"verified"`
+	Message              string  `json:"message,omitempty"`
+	Source               *source `json:"source,omitempty"`
+	Line                 int     `json:"line,omitempty"`
+	InstructionReference string  `json:"instructionReference,omitempty"`
+}
+
+type stackFrame struct {
+	ID                          int     `json:"id"`
+	Name                        string  `json:"name"`
+	Source                      *source `json:"source,omitempty"`
+	Line                        int     `json:"line"`
+	Column                      int     `json:"column"`
+	InstructionPointerReference string  `json:"instructionPointerReference"`
+}
+
+type scope struct {
+	Name               string `json:"name"`
+	VariablesReference int    `json:"variablesReference"`
+	Expensive          bool   `json:"expensive"`
+}
+
+type variable struct {
+	Name               string `json:"name"`
+	Value              string `json:"value"`
+	VariablesReference int    `json:"variablesReference"`
+	MemoryReference    string `json:"memoryReference,omitempty"`
+}
+
+type instruction struct {
+	Address     string  `json:"address"`
+	Bytes       string  `json:"instructionBytes"`
+	Instruction string  `json:"instruction"`
+	Symbol      string  `json:"symbol,omitempty"`
+	Location    *source `json:"location,omitempty"`
+	Line        int     `json:"line,omitempty"`
+}
@@ -0,0 +1,612 @@
+// Package dap serves a CHIP-8 machine to editors over the Debug Adapter Protocol.
+//
+// > ref. https://microsoft.github.io/debug-adapter-protocol/specification
+package dap
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/masu-mi/gochip-8/core"
+	"github.com/masu-mi/gochip-8/debugger"
+	"github.com/masu-mi/gochip-8/symbols"
+)
+
+// LaunchArguments are the arguments of the launch request.
+type LaunchArguments struct {
+	// Program is the path of the ROM.
+	Program string `json:"program"`
+	// Symbols is the path of the symbol table, symbols.PathFor(Program) by default.
+	Symbols     string `json:"symbols"`
+	Quirks      string `json:"quirks"`
+	XOChip      bool   `json:"xoChip"`
+	IPF         int    `json:"ipf"`
+	StopOnEntry bool   `json:"stopOnEntry"`
+}
+
+// Launcher loads the machine of a launch request.
+type Launcher func(a *LaunchArguments) (*core.Chip8, *core.Keypad, error)
+
+// Variable references of scopes.
+const (
+	refRegisters = 1 + iota
+	refStack
+)
+
+const threadID = 1
+
+var (
+	errNotLaunched = errors.New("no program is launched")
+	errRunning     = errors.New("the program is running; pause it first")
+)
+
+// Server is a debug adapter of a session.
+type Server struct {
+	launch Launcher
+	s      *stream
+
+	chip   *core.Chip8
+	d      *debugger.Debugger
+	keypad *core.Keypad
+	syms   *symbols.Table
+	entry  bool
+
+	// breakpoints are IDs of Debugger's breakpoints by source path, "" for instruction breakpoints.
+	breakpoints map[string][]int
+
+	mux    sync.Mutex
+	cancel context.CancelFunc
+	// running is true while a goroutine of run executes the program.
+	running bool
+	wg      sync.WaitGroup
+}
+
+func New(launch Launcher) *Server {
+	return &Server{
+		launch:      launch,
+		breakpoints: map[string][]int{},
+	}
+}
+
+// Serve talks with the client on r and w until it disconnects.
+func (srv *Server) Serve(r io.Reader, w io.Writer) error {
+	srv.s = newStream(r, w)
+	defer func() {
+		srv.pause()
+		srv.wg.Wait()
+		if srv.chip != nil {
+			srv.chip.Close()
+		}
+	}()
+	for {
+		req, e := srv.s.read()
+		if errors.Is(e, io.EOF) {
+			return nil
+		}
+		if e != nil {
+			return e
+		}
+		body, e := srv.handle(req)
+		if e != nil {
+			if e := srv.s.fail(req, e); e != nil {
+				return e
+			}
+			continue
+		}
+		if e := srv.s.respond(req, body); e != nil {
+			return e
+		}
+		if e := srv.after(req); errors.Is(e, errDisconnect) {
+			return nil
+		} else if e != nil {
+			return e
+		}
+	}
+}
+
+// errDisconnect ends Serve after the response.
+var errDisconnect = errors.New("disconnect")
+
+// after sends events and resumes the program following the response of req.
+func (srv *Server) after(req *request) error {
+	switch req.Command {
+	case "continue":
+		srv.run(srv.d.Continue)
+	case "next":
+		srv.run(srv.d.Next)
+	case "stepIn":
+		srv.run(srv.d.Step)
+	case "stepOut":
+		srv.run(srv.d.Finish)
+	case "launch":
+		return srv.s.event("initialized", nil)
+	case "configurationDone":
+		if srv.entry {
+			return srv.s.event("stopped", map[string]interface{}{"reason": "entry", "threadId": threadID, "allThreadsStopped": true})
+		}
+		srv.run(srv.d.Continue)
+	case "disconnect", "terminate":
+		srv.pause()
+		srv.wg.Wait()
+		if e := srv.s.event("terminated", nil); e != nil {
+			return e
+		}
+		if req.Command == "disconnect" {
+			return errDisconnect
+		}
+	}
+	return nil
+}
+
+func (srv *Server) handle(req *request) (interface{}, error) {
+	if srv.d == nil {
+		switch req.Command {
+		case "initialize", "launch", "disconnect", "terminate":
+		default:
+			return nil, errNotLaunched
+		}
+	}
+	if stoppedOnly[req.Command] && srv.isRunning() {
+		return nil, errRunning
+	}
+	switch req.Command {
+	case "initialize":
+		return capabilities{
+			SupportsConfigurationDoneRequest: true,
+			SupportsConditionalBreakpoints:   true,
+			SupportsInstructionBreakpoints:   true,
+			SupportsReadMemoryRequest:        true,
+			SupportsDisassembleRequest:       true,
+			SupportsSetVariable:              true,
+			SupportsTerminateRequest:         true,
+		}, nil
+	case "launch":
+		return nil, srv.doLaunch(req.Arguments)
+	case "configurationDone", "setExceptionBreakpoints", "disconnect", "terminate":
+		return nil, nil
+	case "setBreakpoints":
+		return srv.setBreakpoints(req.Arguments)
+	case "setInstructionBreakpoints":
+		return srv.setInstructionBreakpoints(req.Arguments)
+	case "threads":
+		return map[string]interface{}{"threads": []map[string]interface{}{{"id": threadID, "name": "CHIP-8"}}}, nil
+	case "stackTrace":
+		var body interface{}
+		srv.locked(func() { body = srv.stackTrace() })
+		return body, nil
+	case "scopes":
+		return map[string]interface{}{"scopes": []scope{
+			{Name: "Registers", VariablesReference: refRegisters},
+			{Name: "Stack", VariablesReference: refStack},
+		}}, nil
+	case "variables":
+		return srv.variables(req.Arguments)
+	case "setVariable":
+		return srv.setVariable(req.Arguments)
+	case "continue":
+		return map[string]interface{}{"allThreadsContinued": true}, nil
+	case "next", "stepIn":
+		return nil, nil
+	case "stepOut":
+		var e error
+		srv.locked(func() {
+			if srv.chip.Cpu.Sp == 0 {
+				e = debugger.ErrNotInSubroutine
+			}
+		})
+		return nil, e
+	case "pause":
+		srv.pause()
+		return nil, nil
+	case "readMemory":
+		return srv.readMemory(req.Arguments)
+	case "disassemble":
+		return srv.disassemble(req.Arguments)
+	case "evaluate":
+		return srv.evaluate(req.Arguments)
+	}
+	return nil, fmt.Errorf("unsupported request `%s`", req.Command)
+}
+
+// stoppedOnly are the requests which read or write the state of the machine or run it,
+// answered only while the program is stopped.
+var stoppedOnly = map[string]bool{
+	"stackTrace": true, "variables": true, "setVariable": true, "readMemory": true, "disassemble": true, "evaluate": true,
+	"continue": true, "next": true, "stepIn": true, "stepOut": true,
+}
+
+func (srv *Server) doLaunch(raw json.RawMessage) error {
+	if srv.d != nil {
+		return errors.New("a program is already launched")
+	}
+	a := &LaunchArguments{}
+	if e := json.Unmarshal(raw, a); e != nil {
+		return e
+	}
+	chip, keypad, e := srv.launch(a)
+	if e != nil {
+		return e
+	}
+	srv.chip, srv.keypad, srv.entry = chip, keypad, a.StopOnEntry
+	srv.d = debugger.New(chip)
+	srv.syms = symbols.New()
+	path := a.Symbols
+	if path == "" {
+		path = symbols.PathFor(a.Program)
+	}
+	f, e := os.Open(path)
+	if e != nil {
+		if a.Symbols != "" {
+			return e
+		}
+		return nil
+	}
+	defer f.Close()
+	t, e := symbols.Read(f)
+	if e != nil {
+		return fmt.Errorf("%s: %w", path, e)
+	}
+	srv.syms = t
+	return nil
+}
+
+// run runs f in background and reports the stop as an event.
+func (srv *Server) run(f func(context.Context) (debugger.Stop, error)) {
+	ctx, cancel := context.WithCancel(context.Background())
+	srv.mux.Lock()
+	srv.cancel = cancel
+	srv.running = true
+	srv.mux.Unlock()
+	srv.wg.Add(1)
+	go func() {
+		defer srv.wg.Done()
+		defer cancel()
+		s, e := f(ctx)
+		if e != nil {
+			srv.s.event("output", map[string]interface{}{"category": "stderr", "output": e.Error() + "\n"})
+			s = debugger.Stop{Reason: debugger.ReasonInterrupt}
+			srv.locked(func() { s.Pc = srv.chip.Cpu.Pc })
+		}
+		// the program is stopped before the client knows it by the event.
+		srv.mux.Lock()
+		srv.running = false
+		srv.mux.Unlock()
+		srv.stopped(s)
+	}()
+}
+
+func (srv *Server) isRunning() bool {
+	srv.mux.Lock()
+	defer srv.mux.Unlock()
+	return srv.running
+}
+
+func (srv *Server) pause() {
+	srv.mux.Lock()
+	defer srv.mux.Unlock()
+	if srv.cancel != nil {
+		srv.cancel()
+	}
+}
+
+var stopReasons = map[debugger.Reason]string{
+	debugger.ReasonStep:       "step",
+	debugger.ReasonBreakpoint: "breakpoint",
+	debugger.ReasonWatchpoint: "data breakpoint",
+	debugger.ReasonFault:      "exception",
+	debugger.ReasonInterrupt:  "pause",
+}
+
+func (srv *Server) stopped(s debugger.Stop) {
+	if s.Reason == debugger.ReasonExit {
+		srv.s.event("exited", map[string]interface{}{"exitCode": 0})
+		srv.s.event("terminated", nil)
+		return
+	}
+	body := map[string]interface{}{
+		"reason":            stopReasons[s.Reason],
+		"threadId":          threadID,
+		"allThreadsStopped": true,
+		"description":       s.String(),
+	}
+	if s.Fault != nil {
+		body["text"] = s.Fault.Error()
+	}
+	if s.Breakpoint != nil {
+		body["hitBreakpointIds"] = []int{s.Breakpoint.ID}
+	}
+	srv.s.event("stopped", body)
+}
+
+// locked calls f with the machine locked against a running program.
+func (srv *Server) locked(f func()) {
+	srv.chip.Lock()
+	defer srv.chip.Unlock()
+	f()
+}
+
+func (srv *Server) setBreakpoints(raw json.RawMessage) (interface{}, error) {
+	var a struct {
+		Source      source `json:"source"`
+		Breakpoints []struct {
+			Line      int    `json:"line"`
+			Condition string `json:"condition"`
+		} `json:"breakpoints"`
+	}
+	if e := json.Unmarshal(raw, &a); e != nil {
+		return nil, e
+	}
+	path := a.Source.Path
+	if path == "" {
+		path = a.Source.Name
+	}
+	bps := make([]breakpoint, 0, len(a.Breakpoints))
+	srv.locked(func() {
+		for _, id := range srv.breakpoints[path] {
+			srv.d.Delete(id)
+		}
+		ids := []int{}
+		for _, b := range a.Breakpoints {
+			addr, line, ok := srv.syms.AddrOf(path, b.Line)
+			if !ok {
+				bps = append(bps, breakpoint{Line: b.Line, Message: "no code at the line"})
+				continue
+			}
+			cond, e := condition(b.Condition)
+			if e != nil {
+				bps = append(bps, breakpoint{Line: b.Line, Message: e.Error()})
+				continue
+			}
+			bp := srv.d.SetBreakpoint(addr, cond)
+			ids = append(ids, bp.ID)
+			bps = append(bps, breakpoint{ID: bp.ID, Verified: true, Source: &a.Source, Line: line, InstructionReference: reference(addr)})
+		}
+		srv.breakpoints[path] = ids
+	})
+	return map[string]interface{}{"breakpoints": bps}, nil
+}
+
+func (srv *Server) setInstructionBreakpoints(raw json.RawMessage) (interface{}, error) {
+	var a struct {
+		Breakpoints []struct {
+			InstructionReference string `json:"instructionReference"`
+			Offset               int    `json:"offset"`
+			Condition            string `json:"condition"`
+		} `json:"breakpoints"`
+	}
+	if e := json.Unmarshal(raw, &a); e != nil {
+		return nil, e
+	}
+	bps := make([]breakpoint, 0, len(a.Breakpoints))
+	srv.locked(func() {
+		for _, id := range srv.breakpoints[""] {
+			srv.d.Delete(id)
+		}
+		ids := []int{}
+		for _, b := range a.Breakpoints {
+			addr, e := parseReference(b.InstructionReference, b.Offset)
+			if e != nil {
+				bps = append(bps, breakpoint{Message: e.Error()})
+				continue
+			}
+			cond, e := condition(b.Condition)
+			if e != nil {
+				bps = append(bps, breakpoint{Message: e.Error()})
+				continue
+			}
+			bp := srv.d.SetBreakpoint(addr, cond)
+			ids = append(ids, bp.ID)
+			bps = append(bps, breakpoint{ID: bp.ID, Verified: true, InstructionReference: reference(addr)})
+		}
+		srv.breakpoints[""] = ids
+	})
+	return map[string]interface{}{"breakpoints": bps}, nil
+}
+
+func condition(src string) (*debugger.Expr, error) {
+	if strings.TrimSpace(src) == "" {
+		return nil, nil
+	}
+	return debugger.ParseExpr(src)
+}
+
+// reference formats addr as a memory or instruction reference.
+func reference(addr uint16) string {
+	return fmt.Sprintf("0x%04x", addr)
+}
+
+func parseReference(ref string, offset int) (uint16, error) {
+	v, e := strconv.ParseInt(ref, 0, 32)
+	if e != nil {
+		return 0, fmt.Errorf("invalid reference `%s`", ref)
+	}
+	v += int64(offset)
+	if v < 0 || v > 0xffff {
+		return 0, fmt.Errorf("out of memory: %s%+d", ref, offset)
+	}
+	return uint16(v), nil
+}
+
+func (srv *Server) stackTrace() interface{} {
+	frames := []stackFrame{}
+	for i, f := range srv.d.Backtrace() {
+		name := "main"
+		if i < len(srv.d.Backtrace())-1 || f.Entry != 0 {
+			name = fmt.Sprintf("sub_%03x", f.Entry)
+		}
+		if label, ok := srv.syms.LabelOf(f.Entry); ok && f.Entry != 0 {
+			name = label
+		}
+		sf := stackFrame{ID: i + 1, Name: name, InstructionPointerReference: reference(f.Pc)}
+		if l, ok := srv.syms.LineOf(f.Pc); ok {
+			sf.Source = &source{Name: filepath.Base(l.File), Path: l.File}
+			sf.Line, sf.Column = l.Line, 1
+		}
+		frames = append(frames, sf)
+	}
+	return map[string]interface{}{"stackFrames": frames, "totalFrames": len(frames)}
+}
+
+func (srv *Server) variables(raw json.RawMessage) (interface{}, error) {
+	var a struct {
+		VariablesReference int `json:"variablesReference"`
+	}
+	if e := json.Unmarshal(raw, &a); e != nil {
+		return nil, e
+	}
+	vars := []variable{}
+	srv.chip.Lock()
+	defer srv.chip.Unlock()
+	cpu := srv.chip.Cpu
+	switch a.VariablesReference {
+	case refRegisters:
+		for _, name := range debugger.Registers {
+			v, _ := srv.d.Register(name)
+			vr := variable{Name: name, Value: fmt.Sprintf("0x%02x", v)}
+			switch name {
+			case "I", "PC":
+				vr.Value, vr.MemoryReference = reference(v), reference(v)
+			}
+			vars = append(vars, vr)
+		}
+	case refStack:
+		for i := 0; i < int(cpu.Sp); i++ {
+			vars = append(vars, variable{Name: fmt.Sprintf("[%d]", i), Value: reference(cpu.Stack[i]), MemoryReference: reference(cpu.Stack[i])})
+		}
+	}
+	return map[string]interface{}{"variables": vars}, nil
+}
+
+func (srv *Server) setVariable(raw json.RawMessage) (interface{}, error) {
+	var a struct {
+		VariablesReference int    `json:"variablesReference"`
+		Name               string `json:"name"`
+		Value              string `json:"value"`
+	}
+	if e := json.Unmarshal(raw, &a); e != nil {
+		return nil, e
+	}
+	if a.VariablesReference != refRegisters {
+		return nil, errors.New("only registers can be set")
+	}
+	v, e := debugger.ParseValue(a.Value)
+	if e != nil {
+		return nil, e
+	}
+	srv.locked(func() { e = srv.d.SetRegister(a.Name, v) })
+	if e != nil {
+		return nil, e
+	}
+	return map[string]interface{}{"value": fmt.Sprintf("0x%02x", v)}, nil
+}
+
+func (srv *Server) readMemory(raw json.RawMessage) (interface{}, error) {
+	var a struct {
+		MemoryReference string `json:"memoryReference"`
+		Offset          int    `json:"offset"`
+		Count           int    `json:"count"`
+	}
+	if e := json.Unmarshal(raw, &a); e != nil {
+		return nil, e
+	}
+	addr, e := parseReference(a.MemoryReference, a.Offset)
+	if e != nil {
+		return nil, e
+	}
+	if a.Count < 0 || a.Count > 0x10000 {
+		return nil, fmt.Errorf("invalid count %d", a.Count)
+	}
+	buf := make([]byte, a.Count)
+	var n int
+	srv.locked(func() { n = srv.d.ReadMemory(addr, buf) })
+	return map[string]interface{}{
+		"address":         reference(addr),
+		"data":            base64.StdEncoding.EncodeToString(buf[:n]),
+		"unreadableBytes": a.Count - n,
+	}, nil
+}
+
+func (srv *Server) disassemble(raw json.RawMessage) (interface{}, error) {
+	var a struct {
+		MemoryReference   string `json:"memoryReference"`
+		Offset            int    `json:"offset"`
+		InstructionOffset int    `json:"instructionOffset"`
+		InstructionCount  int    `json:"instructionCount"`
+	}
+	if e := json.Unmarshal(raw, &a); e != nil {
+		return nil, e
+	}
+	base, e := parseReference(a.MemoryReference, a.Offset)
+	if e != nil {
+		return nil, e
+	}
+	// instructions are assumed to be 2 bytes long before the reference.
+	addr := int(base) + a.InstructionOffset*2
+	insts := make([]instruction, 0, a.InstructionCount)
+	srv.chip.Lock()
+	defer srv.chip.Unlock()
+	mem := srv.chip.Memory.Buf
+	for i := 0; i < a.InstructionCount; i++ {
+		if addr < 0 || addr >= len(mem) {
+			insts = append(insts, instruction{Address: fmt.Sprintf("0x%04x", addr&0xffff), Instruction: "??"})
+			addr += 2
+			continue
+		}
+		l := srv.d.Disassemble(uint16(addr), 1)[0]
+		end := addr + l.Inst.Size
+		if end > len(mem) {
+			end = len(mem)
+		}
+		inst := instruction{Address: reference(l.Addr), Bytes: fmt.Sprintf("%X", mem[addr:end]), Instruction: l.Inst.String()}
+		if name, ok := srv.syms.LabelOf(l.Addr); ok {
+			inst.Symbol = name
+		}
+		if sl, ok := srv.syms.LineOf(l.Addr); ok {
+			inst.Location = &source{Name: filepath.Base(sl.File), Path: sl.File}
+			inst.Line = sl.Line
+		}
+		insts = append(insts, inst)
+		addr += l.Inst.Size
+	}
+	return map[string]interface{}{"instructions": insts}, nil
+}
+
+// evaluate evaluates an expression of debugger.Expr.
+// In the debug console it also runs the commands of debugger.REPL, e.g. `press 5`,
+// except those running the program, which is run by the requests of the client.
+func (srv *Server) evaluate(raw json.RawMessage) (interface{}, error) {
+	var a struct {
+		Expression string `json:"expression"`
+		Context    string `json:"context"`
+	}
+	if e := json.Unmarshal(raw, &a); e != nil {
+		return nil, e
+	}
+	srv.chip.Lock()
+	defer srv.chip.Unlock()
+	x, e := debugger.ParseExpr(a.Expression)
+	if e == nil {
+		v := x.Eval(srv.d)
+		return map[string]interface{}{"result": fmt.Sprintf("%d (0x%x)", v, v), "variablesReference": 0}, nil
+	}
+	if a.Context != "repl" {
+		return nil, e
+	}
+	out := &bytes.Buffer{}
+	repl := debugger.NewREPL(srv.d, out)
+	repl.Keypad, repl.Passive = srv.keypad, true
+	if e := repl.Exec(context.Background(), a.Expression); e != nil {
+		return nil, e
+	}
+	return map[string]interface{}{"result": strings.TrimRight(out.String(), "\n"), "variablesReference": 0}, nil
+}
@@ -0,0 +1,157 @@
+package dap
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/masu-mi/gochip-8/core"
+	"github.com/masu-mi/gochip-8/symbols"
+)
+
+// client talks to Server as an editor does.
+type client struct {
+	t   *testing.T
+	w   io.Writer
+	r   *textproto.Reader
+	seq int
+}
+
+type message struct {
+	Type    string          `json:"type"`
+	Command string          `json:"command"`
+	Event   string          `json:"event"`
+	Success bool            `json:"success"`
+	Message string          `json:"message"`
+	Body    json.RawMessage `json:"body"`
+}
+
+func (c *client) request(command string, args interface{}) {
+	c.t.Helper()
+	c.seq++
+	body, e := json.Marshal(map[string]interface{}{"seq": c.seq, "type": "request", "command": command, "arguments": args})
+	if e != nil {
+		c.t.Fatal(e)
+	}
+	if _, e := fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n%s", len(body), body); e != nil {
+		c.t.Fatal(e)
+	}
+}
+
+// expect reads the next message, which must be the response or the event of name.
+func (c *client) expect(typ, name string) *message {
+	c.t.Helper()
+	h, e := c.r.ReadMIMEHeader()
+	if e != nil {
+		c.t.Fatal(e)
+	}
+	n, _ := strconv.Atoi(h.Get("Content-Length"))
+	body := make([]byte, n)
+	if _, e := io.ReadFull(c.r.R, body); e != nil {
+		c.t.Fatal(e)
+	}
+	m := &message{}
+	if e := json.Unmarshal(body, m); e != nil {
+		c.t.Fatal(e)
+	}
+	if m.Type != typ || m.Command+m.Event != name {
+		c.t.Fatalf("got %s; want the %s of %s", body, typ, name)
+	}
+	if typ == "response" && !m.Success {
+		c.t.Fatalf("%s failed: %s", name, m.Message)
+	}
+	return m
+}
+
+func TestLaunchBreakpointContinue(t *testing.T) {
+	// game.8o: LD V0, 1 / LD V1, 2 / JP 0x204
+	table := symbols.New()
+	for i := 0; i < 3; i++ {
+		table.AddLine(uint16(0x200+2*i), "game.8o", i+1)
+	}
+	sym := filepath.Join(t.TempDir(), "game.ch8.sym")
+	f, e := os.Create(sym)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if e := table.Write(f); e != nil {
+		t.Fatal(e)
+	}
+	f.Close()
+	launch := func(a *LaunchArguments) (*core.Chip8, *core.Keypad, error) {
+		keypad := core.NewKeypad()
+		chip := &core.Chip8{
+			Cpu:      core.NewCpu(nil, nil),
+			Memory:   core.NewMemory(core.MemorySize),
+			Display:  core.NewFrameBuffer(),
+			Keyboard: keypad,
+		}
+		_, e := chip.Init(bytes.NewReader([]byte{0x60, 0x01, 0x61, 0x02, 0x12, 0x04}))
+		return chip, keypad, e
+	}
+
+	in, toServer := io.Pipe()
+	fromServer, out := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- New(launch).Serve(in, out)
+		out.Close()
+	}()
+	c := &client{t: t, w: toServer, r: textproto.NewReader(bufio.NewReader(fromServer))}
+
+	c.request("initialize", map[string]interface{}{"adapterID": "gochip-8"})
+	c.expect("response", "initialize")
+	c.request("launch", LaunchArguments{Program: "game.ch8", Symbols: sym})
+	c.expect("response", "launch")
+	c.expect("event", "initialized")
+
+	c.request("setBreakpoints", map[string]interface{}{
+		"source":      map[string]interface{}{"path": "/src/game.8o"},
+		"breakpoints": []map[string]interface{}{{"line": 3}},
+	})
+	var set struct {
+		Breakpoints []struct {
+			ID       int  `json:"id"`
+			Verified bool `json:"verified"`
+		} `json:"breakpoints"`
+	}
+	if e := json.Unmarshal(c.expect("response", "setBreakpoints").Body, &set); e != nil {
+		t.Fatal(e)
+	}
+	if len(set.Breakpoints) != 1 || !set.Breakpoints[0].Verified {
+		t.Fatalf("breakpoints = %+v; want one verified", set.Breakpoints)
+	}
+
+	c.request("configurationDone", nil)
+	c.expect("response", "configurationDone")
+	var stopped struct {
+		Reason           string `json:"reason"`
+		HitBreakpointIds []int  `json:"hitBreakpointIds"`
+	}
+	if e := json.Unmarshal(c.expect("event", "stopped").Body, &stopped); e != nil {
+		t.Fatal(e)
+	}
+	if stopped.Reason != "breakpoint" || len(stopped.HitBreakpointIds) != 1 || stopped.HitBreakpointIds[0] != set.Breakpoints[0].ID {
+		t.Errorf("stopped = %+v; want the breakpoint %d", stopped, set.Breakpoints[0].ID)
+	}
+
+	// JP 0x204 loops back onto the breakpoint.
+	c.request("continue", nil)
+	c.expect("response", "continue")
+	c.expect("event", "stopped")
+
+	c.request("disconnect", nil)
+	c.expect("response", "disconnect")
+	c.expect("event", "terminated")
+	toServer.Close()
+	if e := <-done; e != nil {
+		t.Errorf("Serve = %v", e)
+	}
+}
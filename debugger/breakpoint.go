@@ -0,0 +1,174 @@
+package debugger
+
+import (
+	"fmt"
+
+	"github.com/masu-mi/gochip-8/core"
+)
+
+// Kind is a kind of Breakpoint.
+type Kind int
+
+const (
+	// KindBreakpoint stops at Addr when Expr is nil or true.
+	KindBreakpoint Kind = iota
+	// KindCondition stops when Expr becomes true.
+	KindCondition
+	// KindWatch stops when the value of Expr changes.
+	KindWatch
+	// KindMemory stops after an instruction accesses Len bytes from Addr as Access.
+	KindMemory
+)
+
+// Breakpoint is a condition to stop the program.
+type Breakpoint struct {
+	ID     int
+	Kind   Kind
+	Addr   uint16
+	Len    int
+	Access core.Access
+	Expr   *Expr
+
+	// value is the last value of Expr for KindCondition and KindWatch.
+	value int
+}
+
+func (bp *Breakpoint) String() string {
+	switch bp.Kind {
+	case KindCondition:
+		return fmt.Sprintf("%d: condition %v", bp.ID, bp.Expr)
+	case KindWatch:
+		return fmt.Sprintf("%d: watch %v", bp.ID, bp.Expr)
+	case KindMemory:
+		return fmt.Sprintf("%d: watch %s of 0x%03x-0x%03x", bp.ID, accessNames[bp.Access], bp.Addr, int(bp.Addr)+bp.Len-1)
+	}
+	if bp.Expr != nil {
+		return fmt.Sprintf("%d: breakpoint at 0x%03x if %v", bp.ID, bp.Addr, bp.Expr)
+	}
+	return fmt.Sprintf("%d: breakpoint at 0x%03x", bp.ID, bp.Addr)
+}
+
+// Accesses watched by rwatch, wwatch and awatch. Fetching instructions counts as reading.
+const (
+	WatchRead   = core.AccessFetch | core.AccessRead
+	WatchWrite  = core.AccessWrite
+	WatchAccess = WatchRead | WatchWrite
+)
+
+var accessNames = map[core.Access]string{
+	WatchRead:   "reads",
+	WatchWrite:  "writes",
+	WatchAccess: "accesses",
+}
+
+func (d *Debugger) add(bp *Breakpoint) *Breakpoint {
+	d.lastID++
+	bp.ID = d.lastID
+	if bp.Expr != nil && bp.Kind != KindBreakpoint {
+		bp.value = bp.Expr.Eval(d)
+		if bp.Kind == KindCondition {
+			bp.value = truth(bp.value != 0)
+		}
+	}
+	d.breakpoints = append(d.breakpoints, bp)
+	return bp
+}
+
+// SetBreakpoint stops at addr when cond is nil or true.
+func (d *Debugger) SetBreakpoint(addr uint16, cond *Expr) *Breakpoint {
+	return d.add(&Breakpoint{Kind: KindBreakpoint, Addr: addr, Expr: cond})
+}
+
+// SetCondition stops when cond becomes true.
+func (d *Debugger) SetCondition(cond *Expr) *Breakpoint {
+	return d.add(&Breakpoint{Kind: KindCondition, Expr: cond})
+}
+
+// SetWatch stops when the value of x changes.
+func (d *Debugger) SetWatch(x *Expr) *Breakpoint {
+	return d.add(&Breakpoint{Kind: KindWatch, Expr: x})
+}
+
+// SetWatchpoint stops after an instruction accesses n bytes from addr as access.
+func (d *Debugger) SetWatchpoint(access core.Access, addr uint16, n int) *Breakpoint {
+	return d.add(&Breakpoint{Kind: KindMemory, Addr: addr, Len: n, Access: access})
+}
+
+// Delete deletes the breakpoint of id.
+func (d *Debugger) Delete(id int) bool {
+	for i, bp := range d.breakpoints {
+		if bp.ID == id {
+			d.breakpoints = append(d.breakpoints[:i], d.breakpoints[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Breakpoints returns breakpoints and watchpoints in the order of ID.
+func (d *Debugger) Breakpoints() []*Breakpoint {
+	return append([]*Breakpoint(nil), d.breakpoints...)
+}
+
+// HasBreakpoint tells whether a breakpoint is set at addr.
+func (d *Debugger) HasBreakpoint(addr uint16) bool {
+	for _, bp := range d.breakpoints {
+		if bp.Kind == KindBreakpoint && bp.Addr == addr {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *Debugger) breakpointAt(pc uint16) *Breakpoint {
+	for _, bp := range d.breakpoints {
+		if bp.Kind == KindBreakpoint && bp.Addr == pc && (bp.Expr == nil || bp.Expr.Eval(d) != 0) {
+			return bp
+		}
+	}
+	return nil
+}
+
+func (d *Debugger) onAccess(kind core.Access, addr uint16, n int) {
+	if d.pending != nil {
+		return
+	}
+	for _, bp := range d.breakpoints {
+		if bp.Kind != KindMemory || bp.Access&kind == 0 {
+			continue
+		}
+		if int(addr) < int(bp.Addr)+bp.Len && int(bp.Addr) < int(addr)+n {
+			d.pending = &Stop{Reason: ReasonWatchpoint, Breakpoint: bp, Access: kind, Addr: addr}
+			return
+		}
+	}
+}
+
+// check returns the stop by watchpoints after an instruction.
+// It updates the values of all expressions and returns the first hit.
+func (d *Debugger) check() *Stop {
+	s := d.pending
+	d.pending = nil
+	for _, bp := range d.breakpoints {
+		switch bp.Kind {
+		case KindWatch:
+			v := bp.Expr.Eval(d)
+			if v != bp.value && s == nil {
+				s = &Stop{Reason: ReasonWatchpoint, Breakpoint: bp, Old: bp.value, New: v}
+			}
+			bp.value = v
+		case KindCondition:
+			v := truth(bp.Expr.Eval(d) != 0)
+			if v != 0 && bp.value == 0 && s == nil {
+				s = &Stop{Reason: ReasonWatchpoint, Breakpoint: bp}
+			}
+			bp.value = v
+		}
+	}
+	return s
+}
+
+// rebase takes the values changed while the program is stopped, e.g. by SetRegister.
+func (d *Debugger) rebase() {
+	d.check()
+}
@@ -0,0 +1,359 @@
+// Package debugger controls the execution of core.Chip8 for debugging programs.
+package debugger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/masu-mi/gochip-8/core"
+	"github.com/masu-mi/gochip-8/disasm"
+)
+
+// Reason tells why the program stopped.
+type Reason int
+
+const (
+	ReasonStep Reason = iota
+	ReasonBreakpoint
+	ReasonWatchpoint
+	ReasonFault
+	ReasonExit
+	ReasonInterrupt
+)
+
+var reasonNames = map[Reason]string{
+	ReasonStep:       "step",
+	ReasonBreakpoint: "breakpoint",
+	ReasonWatchpoint: "watchpoint",
+	ReasonFault:      "fault",
+	ReasonExit:       "exit",
+	ReasonInterrupt:  "interrupt",
+}
+
+func (r Reason) String() string {
+	return reasonNames[r]
+}
+
+// Stop describes where and why the program stopped.
+type Stop struct {
+	Reason Reason
+	Pc     uint16
+	// Fault is set with ReasonFault.
+	Fault *core.Fault
+	// Breakpoint is set with ReasonBreakpoint and ReasonWatchpoint.
+	Breakpoint *Breakpoint
+	// Old and New are the values of the expression of KindWatch.
+	Old, New int
+	// Access and Addr are the access hitting KindMemory.
+	Access core.Access
+	Addr   uint16
+}
+
+func (s Stop) String() string {
+	switch {
+	case s.Fault != nil:
+		return fmt.Sprintf("%s at 0x%03x: %v", s.Reason, s.Pc, s.Fault)
+	case s.Breakpoint == nil:
+		return fmt.Sprintf("%s at 0x%03x", s.Reason, s.Pc)
+	}
+	bp := s.Breakpoint
+	msg := fmt.Sprintf("%s %d at 0x%03x", s.Reason, bp.ID, s.Pc)
+	switch bp.Kind {
+	case KindWatch:
+		return fmt.Sprintf("%s: %v: %d (0x%x) -> %d (0x%x)", msg, bp.Expr, s.Old, s.Old, s.New, s.New)
+	case KindCondition:
+		return fmt.Sprintf("%s: %v", msg, bp.Expr)
+	case KindMemory:
+		return fmt.Sprintf("%s: %v 0x%03x", msg, s.Access, s.Addr)
+	}
+	return msg
+}
+
+var ErrNotInSubroutine = errors.New("not in a subroutine")
+
+// Debugger steps and runs Chip8 with breakpoints and watchpoints.
+// It takes over Chip8.Break and Memory.OnAccess and traps the faults of Cpu.
+type Debugger struct {
+	Chip *core.Chip8
+
+	breakpoints []*Breakpoint
+	lastID      int
+	// pending is the stop by a watchpoint found during an instruction.
+	pending *Stop
+	// stopped is the reason of the last ErrBreak.
+	stopped *Stop
+	// until is the temporary stop condition of Next and Finish.
+	until func() bool
+	// resumed lets the first instruction of Continue run on a breakpoint.
+	resumed bool
+}
+
+func New(chip *core.Chip8) *Debugger {
+	d := &Debugger{
+		Chip: chip,
+	}
+	chip.Break = d.shouldBreak
+	chip.Memory.OnAccess = d.onAccess
+	chip.Cpu.FaultPolicy = core.FaultTrap
+	chip.Cpu.Trap = func(f *core.Fault) error {
+		return f
+	}
+	return d
+}
+
+func (d *Debugger) shouldBreak() bool {
+	if d.resumed {
+		d.resumed = false
+		return false
+	}
+	if s := d.check(); s != nil {
+		d.stopped = s
+		return true
+	}
+	if d.until != nil && d.until() {
+		return true
+	}
+	if bp := d.breakpointAt(d.Chip.Cpu.Pc); bp != nil {
+		d.stopped = &Stop{Reason: ReasonBreakpoint, Breakpoint: bp}
+		return true
+	}
+	return false
+}
+
+// Step executes an instruction.
+func (d *Debugger) Step(ctx context.Context) (Stop, error) {
+	chip := d.Chip
+	chip.Lock()
+	defer chip.Unlock()
+	if chip.Cpu.Halted {
+		return d.stop(ReasonExit, nil), nil
+	}
+	d.rebase()
+	pc := chip.Cpu.Pc
+	e := chip.Cpu.Cycle(ctx, chip.Memory, chip.Display, chip.Keyboard, chip.Buzzer)
+	if p, ok := chip.Display.(core.Presenter); ok {
+		p.Present()
+	}
+	if ctx.Err() != nil && chip.Cpu.Pc == pc {
+		return d.stop(ReasonInterrupt, nil), nil
+	}
+	if s := d.check(); s != nil && e == nil {
+		s.Pc = chip.Cpu.Pc
+		return *s, nil
+	}
+	return d.result(e, ReasonStep)
+}
+
+// Next executes an instruction, or runs a called subroutine until it returns.
+func (d *Debugger) Next(ctx context.Context) (Stop, error) {
+	cpu := d.Chip.Cpu
+	d.Chip.Lock()
+	inst := d.Decode(cpu.Pc)
+	ret, sp := cpu.Pc+2, cpu.Sp
+	d.Chip.Unlock()
+	if inst.Flow != disasm.FlowCall {
+		return d.Step(ctx)
+	}
+	return d.runUntil(ctx, func() bool {
+		return cpu.Pc == ret && cpu.Sp == sp
+	})
+}
+
+// Finish runs until the current subroutine returns.
+func (d *Debugger) Finish(ctx context.Context) (Stop, error) {
+	cpu := d.Chip.Cpu
+	d.Chip.Lock()
+	sp := cpu.Sp
+	d.Chip.Unlock()
+	if sp == 0 {
+		return Stop{}, ErrNotInSubroutine
+	}
+	return d.runUntil(ctx, func() bool {
+		return cpu.Sp < sp
+	})
+}
+
+// Continue runs until a breakpoint, a fault, the exit of the program or ctx is done.
+func (d *Debugger) Continue(ctx context.Context) (Stop, error) {
+	return d.runUntil(ctx, nil)
+}
+
+// runUntil runs the chip until until returns true or it stops otherwise.
+// The state shared with shouldBreak is guarded by the lock of the chip, which Run takes for every frame.
+func (d *Debugger) runUntil(ctx context.Context, until func() bool) (Stop, error) {
+	chip := d.Chip
+	chip.Lock()
+	d.rebase()
+	d.until, d.resumed, d.stopped = until, true, nil
+	chip.Unlock()
+	e := chip.Run(ctx)
+	chip.Lock()
+	defer chip.Unlock()
+	d.until, d.resumed = nil, false
+	if e == nil && !chip.Cpu.Halted {
+		return d.stop(ReasonInterrupt, nil), nil
+	}
+	if errors.Is(e, core.ErrBreak) {
+		if d.stopped != nil {
+			s := *d.stopped
+			s.Pc = chip.Cpu.Pc
+			return s, nil
+		}
+		return d.stop(ReasonStep, nil), nil
+	}
+	return d.result(e, ReasonStep)
+}
+
+func (d *Debugger) result(e error, ok Reason) (Stop, error) {
+	var f *core.Fault
+	if errors.As(e, &f) {
+		return d.stop(ReasonFault, f), nil
+	}
+	if e != nil {
+		return Stop{}, e
+	}
+	if d.Chip.Cpu.Halted {
+		return d.stop(ReasonExit, nil), nil
+	}
+	return d.stop(ok, nil), nil
+}
+
+func (d *Debugger) stop(r Reason, f *core.Fault) Stop {
+	return Stop{Reason: r, Pc: d.Chip.Cpu.Pc, Fault: f}
+}
+
+// Decode decodes the instruction at addr.
+func (d *Debugger) Decode(addr uint16) disasm.Inst {
+	return disasm.At(d.Chip.Memory.Buf, addr)
+}
+
+// Line is a line of disassembly.
+type Line struct {
+	Addr uint16
+	Inst disasm.Inst
+}
+
+// Disassemble decodes n instructions from addr.
+func (d *Debugger) Disassemble(addr uint16, n int) []Line {
+	lines := make([]Line, 0, n)
+	for i := 0; i < n && int(addr) < len(d.Chip.Memory.Buf); i++ {
+		inst := d.Decode(addr)
+		lines = append(lines, Line{Addr: addr, Inst: inst})
+		addr += uint16(inst.Size)
+	}
+	return lines
+}
+
+// CallFrame is a frame of Backtrace.
+type CallFrame struct {
+	// Pc is the current address in the frame.
+	Pc uint16
+	// Entry is the address of the subroutine, 0 for the outermost frame.
+	Entry uint16
+}
+
+// Backtrace returns the frames of the call stack from the innermost.
+// The stack holds the addresses of CALL instructions, which give the entries of the callees.
+func (d *Debugger) Backtrace() []CallFrame {
+	cpu := d.Chip.Cpu
+	frames := make([]CallFrame, 0, int(cpu.Sp)+1)
+	pc := cpu.Pc
+	for i := int(cpu.Sp) - 1; i >= 0; i-- {
+		call := cpu.Stack[i]
+		frames = append(frames, CallFrame{Pc: pc, Entry: d.Decode(call).Target})
+		pc = call
+	}
+	return append(frames, CallFrame{Pc: pc})
+}
+
+// Registers are the names accepted by Register and SetRegister.
+var Registers = []string{
+	"V0", "V1", "V2", "V3", "V4", "V5", "V6", "V7",
+	"V8", "V9", "VA", "VB", "VC", "VD", "VE", "VF",
+	"I", "PC", "SP", "DT", "ST",
+}
+
+func (d *Debugger) Register(name string) (uint16, error) {
+	cpu := d.Chip.Cpu
+	name = strings.ToUpper(name)
+	if v, ok := vIndex(name); ok {
+		return uint16(cpu.V[v]), nil
+	}
+	switch name {
+	case "I":
+		return cpu.I, nil
+	case "PC":
+		return cpu.Pc, nil
+	case "SP":
+		return uint16(cpu.Sp), nil
+	case "DT":
+		return uint16(cpu.Dt.GetV()), nil
+	case "ST":
+		return uint16(cpu.St.GetV()), nil
+	}
+	return 0, fmt.Errorf("unknown register `%s`", name)
+}
+
+func (d *Debugger) SetRegister(name string, v uint16) error {
+	cpu := d.Chip.Cpu
+	name = strings.ToUpper(name)
+	if r, ok := vIndex(name); ok {
+		if v > 0xff {
+			return fmt.Errorf("V%X is 8 bits: 0x%x", r, v)
+		}
+		cpu.V[r] = uint8(v)
+		return nil
+	}
+	switch name {
+	case "I":
+		cpu.I = v
+	case "PC":
+		cpu.Pc = v
+	case "SP":
+		if int(v) > len(cpu.Stack) {
+			return fmt.Errorf("SP is up to %d: %d", len(cpu.Stack), v)
+		}
+		cpu.Sp = uint8(v)
+	case "DT", "ST":
+		if v > 0xff {
+			return fmt.Errorf("%s is 8 bits: 0x%x", name, v)
+		}
+		if name == "DT" {
+			cpu.Dt.SetV(uint8(v))
+		} else {
+			cpu.St.SetV(uint8(v))
+		}
+	default:
+		return fmt.Errorf("unknown register `%s`", name)
+	}
+	return nil
+}
+
+func vIndex(name string) (int, bool) {
+	if len(name) != 2 || name[0] != 'V' {
+		return 0, false
+	}
+	i := strings.IndexByte("0123456789ABCDEF", name[1])
+	return i, i >= 0
+}
+
+// ReadMemory copies memory from addr into buf and returns the number of bytes.
+func (d *Debugger) ReadMemory(addr uint16, buf []byte) int {
+	mem := d.Chip.Memory.Buf
+	if int(addr) >= len(mem) {
+		return 0
+	}
+	return copy(buf, mem[addr:])
+}
+
+// WriteMemory copies data into memory from addr.
+func (d *Debugger) WriteMemory(addr uint16, data []byte) error {
+	mem := d.Chip.Memory.Buf
+	if int(addr)+len(data) > len(mem) {
+		return fmt.Errorf("%w: 0x%x+%d", core.ErrMemoryBounds, addr, len(data))
+	}
+	copy(mem[addr:], data)
+	return nil
+}
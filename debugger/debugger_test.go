@@ -0,0 +1,23 @@
+package debugger
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTimersStandStillWhileStopped(t *testing.T) {
+	d := newTestDebugger(t)
+	d.Chip.Dt.SetV(10)
+	d.SetBreakpoint(0x200, nil)
+	// JP 0x200 runs once before the program stops on the breakpoint again.
+	s, e := d.Continue(context.Background())
+	if e != nil || s.Reason != ReasonBreakpoint {
+		t.Fatalf("Continue = %v, %v; want the breakpoint", s, e)
+	}
+	dt := d.Chip.Dt.GetV()
+	time.Sleep(50 * time.Millisecond)
+	if got := d.Chip.Dt.GetV(); got != dt {
+		t.Errorf("DT = %d after a pause at the breakpoint; want %d", got, dt)
+	}
+}
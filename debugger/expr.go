@@ -0,0 +1,248 @@
+package debugger
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Expr is an expression over registers and memory, evaluated as int.
+//
+// Operands are numbers (decimal, 0x, 0b or 0o), registers in Registers and
+// [addr] for the byte of memory at addr. Operators are those of C with C precedence,
+// from the loosest:
+//
+//	|| && | ^ & (== !=) (< <= > >=) (<< >>) (+ -) (* / %) and unary ! - ~
+//
+// So `&` binds looser than `==` as in C, unlike Go: `V0 & 1 == 1` is `V0 & (1 == 1)`.
+// Comparisons and logical operators give 1 or 0. Division by zero gives 0.
+type Expr struct {
+	src  string
+	eval func(d *Debugger) int
+}
+
+// ParseExpr compiles src.
+func ParseExpr(src string) (*Expr, error) {
+	toks, e := tokenize(src)
+	if e != nil {
+		return nil, e
+	}
+	p := &parser{toks: toks}
+	f, e := p.expr(0)
+	if e != nil {
+		return nil, e
+	}
+	if p.pos < len(p.toks) {
+		return nil, fmt.Errorf("unexpected `%s` in `%s`", p.toks[p.pos], src)
+	}
+	return &Expr{src: src, eval: f}, nil
+}
+
+// Eval evaluates x in the current state of d.
+func (x *Expr) Eval(d *Debugger) int {
+	return x.eval(d)
+}
+
+func (x *Expr) String() string {
+	return x.src
+}
+
+// operators are listed longest first to be tokenized greedily.
+var operators = []string{
+	"||", "&&", "==", "!=", "<=", ">=", "<<", ">>",
+	"<", ">", "|", "^", "&", "+", "-", "*", "/", "%", "!", "~", "(", ")", "[", "]",
+}
+
+func tokenize(src string) ([]string, error) {
+	var toks []string
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+		case isWord(c):
+			j := i
+			for j < len(src) && isWord(src[j]) {
+				j++
+			}
+			toks = append(toks, src[i:j])
+			i = j
+		default:
+			op := ""
+			for _, o := range operators {
+				if strings.HasPrefix(src[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected `%c` in `%s`", c, src)
+			}
+			toks = append(toks, op)
+			i += len(op)
+		}
+	}
+	return toks, nil
+}
+
+func isWord(c byte) bool {
+	return c == '_' || '0' <= c && c <= '9' || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z'
+}
+
+type evalFunc func(d *Debugger) int
+
+// binaries are the binary operators by precedence, from the loosest.
+var binaries = []map[string]func(a, b int) int{
+	{"||": func(a, b int) int { return truth(a != 0 || b != 0) }},
+	{"&&": func(a, b int) int { return truth(a != 0 && b != 0) }},
+	{"|": func(a, b int) int { return a | b }},
+	{"^": func(a, b int) int { return a ^ b }},
+	{"&": func(a, b int) int { return a & b }},
+	{
+		"==": func(a, b int) int { return truth(a == b) },
+		"!=": func(a, b int) int { return truth(a != b) },
+	},
+	{
+		"<":  func(a, b int) int { return truth(a < b) },
+		"<=": func(a, b int) int { return truth(a <= b) },
+		">":  func(a, b int) int { return truth(a > b) },
+		">=": func(a, b int) int { return truth(a >= b) },
+	},
+	{
+		"<<": func(a, b int) int { return a << (uint(b) & 63) },
+		">>": func(a, b int) int { return a >> (uint(b) & 63) },
+	},
+	{
+		"+": func(a, b int) int { return a + b },
+		"-": func(a, b int) int { return a - b },
+	},
+	{
+		"*": func(a, b int) int { return a * b },
+		"/": func(a, b int) int {
+			if b == 0 {
+				return 0
+			}
+			return a / b
+		},
+		"%": func(a, b int) int {
+			if b == 0 {
+				return 0
+			}
+			return a % b
+		},
+	},
+}
+
+func truth(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+type parser struct {
+	toks []string
+	pos  int
+}
+
+func (p *parser) peek() string {
+	if p.pos < len(p.toks) {
+		return p.toks[p.pos]
+	}
+	return ""
+}
+
+func (p *parser) next() string {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+// expr parses binary operators of the level and the tighter ones.
+func (p *parser) expr(level int) (evalFunc, error) {
+	if level == len(binaries) {
+		return p.unary()
+	}
+	lhs, e := p.expr(level + 1)
+	if e != nil {
+		return nil, e
+	}
+	for {
+		op, ok := binaries[level][p.peek()]
+		if !ok {
+			return lhs, nil
+		}
+		p.next()
+		rhs, e := p.expr(level + 1)
+		if e != nil {
+			return nil, e
+		}
+		l := lhs
+		lhs = func(d *Debugger) int { return op(l(d), rhs(d)) }
+	}
+}
+
+func (p *parser) unary() (evalFunc, error) {
+	switch p.peek() {
+	case "!", "-", "~":
+		op := p.next()
+		x, e := p.unary()
+		if e != nil {
+			return nil, e
+		}
+		switch op {
+		case "!":
+			return func(d *Debugger) int { return truth(x(d) == 0) }, nil
+		case "-":
+			return func(d *Debugger) int { return -x(d) }, nil
+		default:
+			return func(d *Debugger) int { return ^x(d) }, nil
+		}
+	}
+	return p.primary()
+}
+
+func (p *parser) primary() (evalFunc, error) {
+	t := p.next()
+	switch {
+	case t == "":
+		return nil, fmt.Errorf("unexpected end of expression")
+	case t == "(" || t == "[":
+		x, e := p.expr(0)
+		if e != nil {
+			return nil, e
+		}
+		closing := map[string]string{"(": ")", "[": "]"}[t]
+		if p.next() != closing {
+			return nil, fmt.Errorf("missing `%s`", closing)
+		}
+		if t == "(" {
+			return x, nil
+		}
+		return func(d *Debugger) int {
+			a := x(d)
+			if a < 0 || a >= len(d.Chip.Memory.Buf) {
+				return 0
+			}
+			return int(d.Chip.Memory.Buf[a])
+		}, nil
+	case '0' <= t[0] && t[0] <= '9':
+		v, e := strconv.ParseInt(t, 0, 64)
+		if e != nil {
+			return nil, fmt.Errorf("invalid number `%s`", t)
+		}
+		return func(*Debugger) int { return int(v) }, nil
+	case isWord(t[0]):
+		name := strings.ToUpper(t)
+		for _, r := range Registers {
+			if r == name {
+				return func(d *Debugger) int {
+					v, _ := d.Register(name)
+					return int(v)
+				}, nil
+			}
+		}
+		return nil, fmt.Errorf("unknown register `%s`", t)
+	}
+	return nil, fmt.Errorf("unexpected `%s`", t)
+}
@@ -0,0 +1,103 @@
+package debugger
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/masu-mi/gochip-8/core"
+)
+
+func newTestDebugger(t *testing.T) *Debugger {
+	t.Helper()
+	chip := &core.Chip8{
+		Cpu:      core.NewCpu(nil, nil),
+		Memory:   core.NewMemory(core.MemorySize),
+		Display:  core.NewFrameBuffer(),
+		Keyboard: core.NewKeypad(),
+	}
+	t.Cleanup(chip.Close)
+	if _, e := chip.Init(bytes.NewReader([]byte{0x12, 0x00})); e != nil {
+		t.Fatal(e)
+	}
+	return New(chip)
+}
+
+func TestExprEval(t *testing.T) {
+	d := newTestDebugger(t)
+	d.Chip.V[0], d.Chip.V[3], d.Chip.I = 0x03, 0x10, 0x300
+	d.Chip.Memory.Buf[0x300] = 0x42
+	for _, tc := range []struct {
+		src  string
+		want int
+	}{
+		{"42", 42},
+		{"0x2a", 42},
+		{"0b101010", 42},
+		{"0o52", 42},
+		{"V3", 0x10},
+		{"v3", 0x10},
+		{"I", 0x300},
+		{"PC", 0x200},
+		{"[I]", 0x42},
+		{"[0x300] + 1", 0x43},
+		{"[0x10000]", 0},
+		{"-1", -1},
+		{"~0", -1},
+		{"!V3", 0},
+		{"!!V3", 1},
+		{"--1", 1},
+		{"7 / 0", 0},
+		{"7 % 0", 0},
+		{"1 << 4", 16},
+		{"0x100 >> 4", 16},
+		// precedence of C, from the loosest.
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"10 - 4 - 3", 3},
+		{"24 / 4 / 2", 3},
+		{"1 << 2 + 1", 8},
+		{"1 < 2 == 1", 1},
+		{"V0 & 1 == 1", 1},
+		{"V3 & 0x10 == 0x10", 0},
+		{"(V3 & 0x10) == 0x10", 1},
+		{"1 | 2 ^ 3 & 1", 3},
+		{"6 ^ 3 | 8", 13},
+		{"0 && 1 || 1", 1},
+		{"1 || 0 && 0", 1},
+		{"V3 == 0x10 && I > 0x2ff", 1},
+		{"V3 != 0x10 || I >= 0x301", 0},
+		{"-V0 * 2", -6},
+		{"-(V0 * 2) + 10", 4},
+	} {
+		x, e := ParseExpr(tc.src)
+		if e != nil {
+			t.Errorf("ParseExpr(%q): %v", tc.src, e)
+			continue
+		}
+		if v := x.Eval(d); v != tc.want {
+			t.Errorf("%s = %d; want %d", tc.src, v, tc.want)
+		}
+		if x.String() != tc.src {
+			t.Errorf("String() = %q; want %q", x.String(), tc.src)
+		}
+	}
+}
+
+func TestParseExprErrors(t *testing.T) {
+	for _, src := range []string{
+		"",
+		"1 +",
+		"(1 + 2",
+		"[I",
+		"(1]",
+		"1 2",
+		"V16",
+		"0x",
+		"1 $ 2",
+		")",
+	} {
+		if _, e := ParseExpr(src); e == nil {
+			t.Errorf("ParseExpr(%q) succeeded; want an error", src)
+		}
+	}
+}
@@ -0,0 +1,469 @@
+package debugger
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/masu-mi/gochip-8/core"
+)
+
+// ErrQuit is returned by Exec for the quit command.
+var ErrQuit = errors.New("quit")
+
+// REPL reads commands of Debugger line by line.
+type REPL struct {
+	*Debugger
+	Out io.Writer
+	// Keypad is pressed by the press and release commands when it's not nil.
+	Keypad *core.Keypad
+	// Interrupt derives the context of commands running the program, e.g. to stop them by SIGINT.
+	Interrupt func(ctx context.Context) (context.Context, context.CancelFunc)
+	// Passive refuses the commands running the program, e.g. when a debugger front end runs it by itself.
+	Passive bool
+
+	last string
+}
+
+type command struct {
+	names []string
+	usage string
+	help  string
+	run   func(r *REPL, ctx context.Context, args []string) error
+}
+
+var commands []command
+
+// runControls are the commands running the program.
+var runControls = map[string]bool{"step": true, "next": true, "finish": true, "continue": true}
+
+func init() {
+	commands = []command{
+		{[]string{"step", "s"}, "step [n]", "execute n instructions (default: 1)", (*REPL).step},
+		{[]string{"next", "n"}, "next", "execute an instruction, stepping over CALL", (*REPL).next},
+		{[]string{"finish", "fin"}, "finish", "run until the current subroutine returns", (*REPL).finish},
+		{[]string{"continue", "c"}, "continue", "run until a breakpoint or a fault", (*REPL).cont},
+		{[]string{"break", "b"}, "break <addr> [if <expr>]", "set a breakpoint, optionally with a condition", (*REPL).setBreak},
+		{[]string{"cond"}, "cond <expr>", "stop when expr becomes true, e.g. `V3 == 0x10 && I > 0x300`", (*REPL).setCondition},
+		{[]string{"watch"}, "watch <expr>", "stop when the value of expr changes, e.g. `VA` or `[0x300]`", (*REPL).watch},
+		{[]string{"rwatch"}, "rwatch <addr> [len]", "stop when memory is read", watchMemory(WatchRead)},
+		{[]string{"wwatch"}, "wwatch <addr> [len]", "stop when memory is written", watchMemory(WatchWrite)},
+		{[]string{"awatch"}, "awatch <addr> [len]", "stop when memory is read or written", watchMemory(WatchAccess)},
+		{[]string{"delete", "d"}, "delete [id]", "delete the breakpoint or watchpoint of id, or all of them", (*REPL).deleteBreak},
+		{[]string{"info", "i"}, "info breakpoints", "list breakpoints and watchpoints", (*REPL).info},
+		{[]string{"print", "p"}, "print <expr>", "evaluate expr", (*REPL).print},
+		{[]string{"regs", "r"}, "regs", "show registers", (*REPL).regs},
+		{[]string{"set"}, "set <reg> <value>", "set V0-VF, I, PC, SP, DT or ST", (*REPL).set},
+		{[]string{"x"}, "x <addr> [len]", "dump memory (default: 16 bytes)", (*REPL).examine},
+		{[]string{"write", "w"}, "write <addr> <byte>...", "write bytes into memory", (*REPL).write},
+		{[]string{"disasm", "l"}, "disasm [addr] [n]", "disassemble around PC or from addr", (*REPL).disasm},
+		{[]string{"backtrace", "bt"}, "backtrace", "show the call stack", (*REPL).backtrace},
+		{[]string{"screen"}, "screen", "show the display", (*REPL).screen},
+		{[]string{"press"}, "press <key>", "hold down a key of the keypad", (*REPL).press},
+		{[]string{"release"}, "release <key>", "release a key of the keypad", (*REPL).release},
+		{[]string{"help", "h"}, "help", "show commands", (*REPL).help},
+		{[]string{"quit", "q"}, "quit", "exit the debugger", (*REPL).quit},
+	}
+}
+
+func NewREPL(d *Debugger, out io.Writer) *REPL {
+	return &REPL{
+		Debugger:  d,
+		Out:       out,
+		Interrupt: context.WithCancel,
+	}
+}
+
+// Run reads commands from in until EOF or quit.
+func (r *REPL) Run(ctx context.Context, in io.Reader) error {
+	sc := bufio.NewScanner(in)
+	r.location()
+	for {
+		fmt.Fprint(r.Out, "(chip-8) ")
+		if !sc.Scan() {
+			fmt.Fprintln(r.Out)
+			return sc.Err()
+		}
+		e := r.Exec(ctx, sc.Text())
+		if errors.Is(e, ErrQuit) {
+			return nil
+		}
+		if e != nil {
+			fmt.Fprintln(r.Out, e)
+		}
+	}
+}
+
+// Exec executes a command line. An empty line repeats the last command.
+func (r *REPL) Exec(ctx context.Context, line string) error {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		if r.last == "" {
+			return nil
+		}
+		fields = strings.Fields(r.last)
+	} else {
+		r.last = line
+	}
+	for _, c := range commands {
+		for _, n := range c.names {
+			if n == fields[0] {
+				if r.Passive && runControls[c.names[0]] {
+					return fmt.Errorf("`%s` runs the program; use the controls of the debugger", fields[0])
+				}
+				return c.run(r, ctx, fields[1:])
+			}
+		}
+	}
+	return fmt.Errorf("unknown command `%s` (try `help`)", fields[0])
+}
+
+func (r *REPL) running(ctx context.Context, f func(ctx context.Context) (Stop, error)) error {
+	c, cancel := r.Interrupt(ctx)
+	defer cancel()
+	s, e := f(c)
+	if e != nil {
+		return e
+	}
+	if s.Reason != ReasonStep {
+		fmt.Fprintln(r.Out, s)
+	}
+	r.location()
+	return nil
+}
+
+func (r *REPL) location() {
+	pc := r.Chip.Cpu.Pc
+	fmt.Fprintf(r.Out, "=> 0x%03x: %v\n", pc, r.Decode(pc))
+}
+
+func (r *REPL) step(ctx context.Context, args []string) error {
+	n := 1
+	if len(args) > 0 {
+		v, e := strconv.Atoi(args[0])
+		if e != nil || v < 1 {
+			return fmt.Errorf("invalid count `%s`", args[0])
+		}
+		n = v
+	}
+	return r.running(ctx, func(ctx context.Context) (Stop, error) {
+		var s Stop
+		var e error
+		for i := 0; i < n; i++ {
+			if s, e = r.Step(ctx); e != nil || s.Reason != ReasonStep {
+				break
+			}
+		}
+		return s, e
+	})
+}
+
+func (r *REPL) next(ctx context.Context, _ []string) error {
+	return r.running(ctx, r.Next)
+}
+
+func (r *REPL) finish(ctx context.Context, _ []string) error {
+	return r.running(ctx, r.Finish)
+}
+
+func (r *REPL) cont(ctx context.Context, _ []string) error {
+	return r.running(ctx, r.Continue)
+}
+
+func (r *REPL) setBreak(_ context.Context, args []string) error {
+	if len(args) == 0 || len(args) == 2 || len(args) > 1 && args[1] != "if" {
+		return errors.New("usage: break <addr> [if <expr>]")
+	}
+	a, e := ParseValue(args[0])
+	if e != nil {
+		return e
+	}
+	var cond *Expr
+	if len(args) > 2 {
+		if cond, e = ParseExpr(strings.Join(args[2:], " ")); e != nil {
+			return e
+		}
+	}
+	bp := r.SetBreakpoint(a, cond)
+	fmt.Fprintf(r.Out, "%v: %v\n", bp, r.Decode(a))
+	return nil
+}
+
+func (r *REPL) setCondition(_ context.Context, args []string) error {
+	x, e := r.expr(args, "usage: cond <expr>")
+	if e != nil {
+		return e
+	}
+	fmt.Fprintln(r.Out, r.SetCondition(x))
+	return nil
+}
+
+func (r *REPL) watch(_ context.Context, args []string) error {
+	x, e := r.expr(args, "usage: watch <expr>")
+	if e != nil {
+		return e
+	}
+	fmt.Fprintln(r.Out, r.SetWatch(x))
+	return nil
+}
+
+func watchMemory(access core.Access) func(r *REPL, ctx context.Context, args []string) error {
+	return func(r *REPL, _ context.Context, args []string) error {
+		if len(args) < 1 || len(args) > 2 {
+			return errors.New("usage: rwatch|wwatch|awatch <addr> [len]")
+		}
+		a, e := ParseValue(args[0])
+		if e != nil {
+			return e
+		}
+		n := uint16(1)
+		if len(args) > 1 {
+			if n, e = ParseValue(args[1]); e != nil {
+				return e
+			}
+		}
+		if n == 0 {
+			return errors.New("len must be positive")
+		}
+		fmt.Fprintln(r.Out, r.SetWatchpoint(access, a, int(n)))
+		return nil
+	}
+}
+
+func (r *REPL) deleteBreak(_ context.Context, args []string) error {
+	if len(args) == 0 {
+		for _, bp := range r.Breakpoints() {
+			r.Delete(bp.ID)
+		}
+		return nil
+	}
+	id, e := strconv.Atoi(args[0])
+	if e != nil {
+		return fmt.Errorf("invalid id `%s`", args[0])
+	}
+	if !r.Delete(id) {
+		return fmt.Errorf("no breakpoint %d", id)
+	}
+	return nil
+}
+
+func (r *REPL) info(_ context.Context, args []string) error {
+	if len(args) != 1 || !strings.HasPrefix("breakpoints", args[0]) {
+		return errors.New("usage: info breakpoints")
+	}
+	for _, bp := range r.Breakpoints() {
+		fmt.Fprintln(r.Out, bp)
+	}
+	return nil
+}
+
+func (r *REPL) print(_ context.Context, args []string) error {
+	x, e := r.expr(args, "usage: print <expr>")
+	if e != nil {
+		return e
+	}
+	v := x.Eval(r.Debugger)
+	fmt.Fprintf(r.Out, "%d (0x%x)\n", v, v)
+	return nil
+}
+
+func (r *REPL) expr(args []string, usage string) (*Expr, error) {
+	if len(args) == 0 {
+		return nil, errors.New(usage)
+	}
+	return ParseExpr(strings.Join(args, " "))
+}
+
+func (r *REPL) regs(_ context.Context, _ []string) error {
+	cpu := r.Chip.Cpu
+	for i, v := range cpu.V {
+		fmt.Fprintf(r.Out, "V%X=0x%02x", i, v)
+		if i%8 == 7 {
+			fmt.Fprintln(r.Out)
+		} else {
+			fmt.Fprint(r.Out, " ")
+		}
+	}
+	fmt.Fprintf(r.Out, "I=0x%04x PC=0x%04x SP=%d DT=%d ST=%d\n", cpu.I, cpu.Pc, cpu.Sp, cpu.Dt.GetV(), cpu.St.GetV())
+	return nil
+}
+
+func (r *REPL) set(_ context.Context, args []string) error {
+	if len(args) != 2 {
+		return errors.New("usage: set <reg> <value>")
+	}
+	v, e := ParseValue(args[1])
+	if e != nil {
+		return e
+	}
+	return r.SetRegister(args[0], v)
+}
+
+func (r *REPL) examine(_ context.Context, args []string) error {
+	if len(args) < 1 {
+		return errors.New("usage: x <addr> [len]")
+	}
+	a, e := ParseValue(args[0])
+	if e != nil {
+		return e
+	}
+	n := uint16(16)
+	if len(args) > 1 {
+		if n, e = ParseValue(args[1]); e != nil {
+			return e
+		}
+	}
+	buf := make([]byte, n)
+	buf = buf[:r.ReadMemory(a, buf)]
+	for i := 0; i < len(buf); i += 16 {
+		end := i + 16
+		if end > len(buf) {
+			end = len(buf)
+		}
+		fmt.Fprintf(r.Out, "%04x: % x\n", int(a)+i, buf[i:end])
+	}
+	return nil
+}
+
+func (r *REPL) write(_ context.Context, args []string) error {
+	if len(args) < 2 {
+		return errors.New("usage: write <addr> <byte>...")
+	}
+	a, e := ParseValue(args[0])
+	if e != nil {
+		return e
+	}
+	data := make([]byte, 0, len(args)-1)
+	for _, s := range args[1:] {
+		v, e := ParseValue(s)
+		if e != nil {
+			return e
+		}
+		if v > 0xff {
+			return fmt.Errorf("not a byte: %s", s)
+		}
+		data = append(data, uint8(v))
+	}
+	return r.WriteMemory(a, data)
+}
+
+func (r *REPL) disasm(_ context.Context, args []string) error {
+	pc := r.Chip.Cpu.Pc
+	from, n := pc, uint16(10)
+	if pc >= core.StartOfProgram+8 {
+		from = pc - 8
+	}
+	var e error
+	if len(args) > 0 {
+		if from, e = ParseValue(args[0]); e != nil {
+			return e
+		}
+	}
+	if len(args) > 1 {
+		if n, e = ParseValue(args[1]); e != nil {
+			return e
+		}
+	}
+	for _, l := range r.Disassemble(from, int(n)) {
+		mark := "  "
+		if l.Addr == pc {
+			mark = "=>"
+		} else if r.HasBreakpoint(l.Addr) {
+			mark = "b "
+		}
+		fmt.Fprintf(r.Out, "%s 0x%03x: %04X  %v\n", mark, l.Addr, l.Inst.Op, l.Inst)
+	}
+	return nil
+}
+
+func (r *REPL) backtrace(_ context.Context, _ []string) error {
+	for i, f := range r.Backtrace() {
+		if f.Entry == 0 && i == len(r.Backtrace())-1 {
+			fmt.Fprintf(r.Out, "#%d 0x%03x\n", i, f.Pc)
+			continue
+		}
+		fmt.Fprintf(r.Out, "#%d 0x%03x in sub_%03x\n", i, f.Pc, f.Entry)
+	}
+	return nil
+}
+
+func (r *REPL) screen(_ context.Context, _ []string) error {
+	h, ok := r.Chip.Display.(core.FrameHolder)
+	if !ok {
+		return errors.New("the display can't be read")
+	}
+	fmt.Fprint(r.Out, h.Frame())
+	return nil
+}
+
+func (r *REPL) press(_ context.Context, args []string) error {
+	k, e := r.key(args)
+	if e != nil {
+		return e
+	}
+	r.Keypad.Press(k)
+	return nil
+}
+
+func (r *REPL) release(_ context.Context, args []string) error {
+	k, e := r.key(args)
+	if e != nil {
+		return e
+	}
+	r.Keypad.Release(k)
+	return nil
+}
+
+func (r *REPL) key(args []string) (uint8, error) {
+	if r.Keypad == nil {
+		return 0, errors.New("no keypad")
+	}
+	if len(args) != 1 {
+		return 0, errors.New("usage: press|release <key>")
+	}
+	k, e := strconv.ParseUint(args[0], 16, 4)
+	if e != nil {
+		return 0, fmt.Errorf("invalid key `%s` (0-F)", args[0])
+	}
+	return uint8(k), nil
+}
+
+// exprHelp describes expressions below the commands.
+const exprHelp = `
+expressions: numbers, V0-VF, I, PC, SP, DT, ST and [addr] for a byte of memory,
+with the operators of C in C precedence, from the loosest:
+  || && | ^ & (== !=) (< <= > >=) (<< >>) (+ -) (* / %) and unary ! - ~
+so & binds looser than ==: parenthesize ` + "`(V0 & 1) == 1`" + `.`
+
+func (r *REPL) help(_ context.Context, _ []string) error {
+	usages := make([]string, 0, len(commands))
+	for _, c := range commands {
+		usage := c.usage
+		if len(c.names) > 1 {
+			usage += " (" + strings.Join(c.names[1:], ", ") + ")"
+		}
+		usages = append(usages, fmt.Sprintf("  %-30s %s", usage, c.help))
+	}
+	sort.Strings(usages)
+	fmt.Fprintln(r.Out, strings.Join(usages, "\n"))
+	fmt.Fprintln(r.Out, exprHelp)
+	return nil
+}
+
+func (r *REPL) quit(_ context.Context, _ []string) error {
+	return ErrQuit
+}
+
+// ParseValue parses numbers in decimal, 0x hexadecimal, 0b binary or 0o octal.
+func ParseValue(s string) (uint16, error) {
+	v, e := strconv.ParseUint(s, 0, 16)
+	if e != nil {
+		return 0, fmt.Errorf("invalid value `%s`", s)
+	}
+	return uint16(v), nil
+}
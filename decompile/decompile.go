@@ -0,0 +1,286 @@
+// Package decompile writes the functions of a ROM as structured Octo-like code.
+//
+// The patterns of the skips of Cpu.Cycle are recovered as Octo's control flow:
+//
+//	SE/SNE/SKP/SKNP; stmt            if cond then stmt
+//	SE...; JP L; ...; L:             if cond begin ... end
+//	SE...; JP L; ...; JP M; L: ...; M:   if cond begin ... else ... end
+//	H: ...; JP H                     loop ... again
+//	SE...; JP after again (in loop)  while cond
+//
+// The other jumps are written as `jump label`, and so are the tables of JP V0 under their labels:
+//
+//	jump0 table_20e
+//	: table_20e
+//	jump loc_212
+//	jump loc_21a
+package decompile
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/masu-mi/gochip-8/cfg"
+	"github.com/masu-mi/gochip-8/disasm"
+)
+
+// Write writes the functions of g.
+func Write(w io.Writer, g *cfg.Graph) error {
+	d := &decompiler{p: g.Program, refs: map[uint16]bool{}, targets: map[uint16]bool{}, tables: map[uint16]bool{}}
+	for a, inst := range g.Program.Code {
+		for _, e := range cfg.Succs(g.Program, a) {
+			if e.Kind == cfg.EdgeJump {
+				d.targets[e.To] = true
+			}
+			if inst.Flow == disasm.FlowJumpIndirect {
+				d.tables[e.To] = true
+			}
+		}
+	}
+	var funcs [][]line
+	for _, f := range g.Funcs {
+		d.f, d.lines = f, nil
+		d.index = map[uint16]int{}
+		for i, a := range f.Insts {
+			d.index[a] = i
+		}
+		d.body(0, len(f.Insts), 1, nil)
+		funcs = append(funcs, d.lines)
+	}
+	var b strings.Builder
+	for i, f := range g.Funcs {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		fmt.Fprintf(&b, ": %s\n", d.label(f.Entry))
+		labeled := map[uint16]bool{f.Entry: true}
+		for _, l := range funcs[i] {
+			if l.label && d.refs[l.addr] && !labeled[l.addr] {
+				labeled[l.addr] = true
+				fmt.Fprintf(&b, "%s: %s\n", strings.Repeat("\t", l.depth-1), d.label(l.addr))
+			}
+			fmt.Fprintf(&b, "%s%s\n", strings.Repeat("\t", l.depth), l.text)
+		}
+	}
+	_, e := io.WriteString(w, b.String())
+	return e
+}
+
+// line is a line of code. label tells that the label of addr can be put before it.
+type line struct {
+	addr  uint16
+	label bool
+	depth int
+	text  string
+}
+
+// loop is the loop being written.
+type loop struct {
+	start int
+	// exit is the address after `again`.
+	exit uint16
+}
+
+type decompiler struct {
+	p *disasm.Program
+	f *cfg.Func
+	// index is the index of the instructions of f by address.
+	index map[uint16]int
+	lines []line
+	// refs are the addresses referred by `jump`, which need labels.
+	refs map[uint16]bool
+	// targets are the addresses where any jump goes.
+	targets map[uint16]bool
+	// tables are the addresses of the entries of the tables of JP V0.
+	tables map[uint16]bool
+}
+
+func (d *decompiler) label(a uint16) string {
+	if name, ok := d.p.Labels[a]; ok {
+		return name
+	}
+	return fmt.Sprintf("loc_%03x", a)
+}
+
+func (d *decompiler) emit(a uint16, depth int, text string) {
+	d.lines = append(d.lines, line{addr: a, label: true, depth: depth, text: text})
+}
+
+// keyword emits a keyword of a block, which can't have a label.
+func (d *decompiler) keyword(a uint16, depth int, text string) {
+	d.lines = append(d.lines, line{addr: a, depth: depth, text: text})
+}
+
+func (d *decompiler) inst(i int) (uint16, disasm.Inst) {
+	a := d.f.Insts[i]
+	return a, d.p.Code[a]
+}
+
+// end returns the address after the instruction i.
+func (d *decompiler) end(i int) uint16 {
+	a, inst := d.inst(i)
+	return a + uint16(inst.Size)
+}
+
+// contiguous tells whether the instructions from i to j follow each other without gaps.
+func (d *decompiler) contiguous(i, j int) bool {
+	for k := i; k+1 < j; k++ {
+		if d.end(k) != d.f.Insts[k+1] {
+			return false
+		}
+	}
+	return true
+}
+
+// at returns the index of the instruction at a in [lo, hi], where hi is the address after the range.
+func (d *decompiler) at(a uint16, lo, hi int) (int, bool) {
+	if hi > lo && a == d.end(hi-1) {
+		return hi, true
+	}
+	i, ok := d.index[a]
+	return i, ok && lo <= i && i <= hi
+}
+
+// body writes the instructions from lo to hi.
+func (d *decompiler) body(lo, hi, depth int, in *loop) {
+	for i := lo; i < hi; {
+		i = d.statement(i, hi, depth, in)
+	}
+}
+
+// statement writes the statement at i and returns the index of the next one.
+func (d *decompiler) statement(i, hi, depth int, in *loop) int {
+	a, inst := d.inst(i)
+	if d.tables[a] {
+		// an entry of a table is kept as a jump to be indexed by JP V0.
+		d.emit(a, depth, d.format(inst))
+		return i + 1
+	}
+	if in == nil || in.start != i {
+		// the farthest jump back to a makes the loop. The entries of tables can't be `again`.
+		for j := hi - 1; j >= i; j-- {
+			ja, jinst := d.inst(j)
+			if jinst.Op>>12 == 0x1 && jinst.Target == a && !d.tables[ja] && d.contiguous(i, j+1) {
+				// jumps to a enter the loop.
+				d.emit(a, depth, "loop")
+				body := &loop{start: i, exit: ja + 2}
+				if j > i && !d.targets[ja] {
+					if sa, s := d.inst(j - 1); s.Flow == disasm.FlowSkip {
+						// the skip before again leaves the loop.
+						d.body(i, j-1, depth+1, body)
+						d.keyword(sa, depth, fmt.Sprintf("if %s then again", negate(cond(s))))
+						return j + 1
+					}
+				}
+				d.body(i, j, depth+1, body)
+				d.keyword(ja, depth, "again")
+				return j + 1
+			}
+		}
+	}
+	if inst.Flow == disasm.FlowSkip && i+1 < hi && d.contiguous(i, i+2) {
+		if next, ok := d.conditional(i, hi, depth, in); ok {
+			return next
+		}
+	}
+	d.emit(a, depth, d.format(inst))
+	switch inst.Flow {
+	case disasm.FlowJump, disasm.FlowJumpIndirect, disasm.FlowReturn, disasm.FlowExit:
+		return i + 1
+	}
+	// control falls to another function or over data.
+	if next := d.end(i); i+1 >= len(d.f.Insts) || d.f.Insts[i+1] != next {
+		if _, ok := d.p.Code[next]; ok {
+			d.refs[next] = true
+			d.emit(a, depth, "jump "+d.label(next))
+		}
+	}
+	return i + 1
+}
+
+// conditional writes the skip at i with the following instructions as if, while or if-else.
+func (d *decompiler) conditional(i, hi, depth int, in *loop) (int, bool) {
+	a, inst := d.inst(i)
+	na, next := d.inst(i + 1)
+	skipped := cond(inst)
+	if d.tables[na] {
+		return 0, false
+	}
+	if next.Op>>12 != 0x1 {
+		switch next.Flow {
+		case disasm.FlowSkip, disasm.FlowJump, disasm.FlowJumpIndirect:
+			return 0, false
+		}
+		if d.targets[na] {
+			// the label of the next statement is needed.
+			return 0, false
+		}
+		// the next statement runs unless it's skipped.
+		d.emit(a, depth, fmt.Sprintf("if %s then %s", negate(skipped), d.format(next)))
+		return i + 2, true
+	}
+	t := next.Target
+	if in != nil && t == in.exit {
+		d.emit(a, depth, "while "+skipped)
+		return i + 2, true
+	}
+	if t <= na {
+		return 0, false
+	}
+	k, ok := d.at(t, i+2, hi)
+	if !ok || !d.contiguous(i+1, k) {
+		return 0, false
+	}
+	// the skip runs the block and the jump goes to else or end.
+	d.emit(a, depth, fmt.Sprintf("if %s begin", skipped))
+	if k-1 >= i+2 {
+		ea, e := d.inst(k - 1)
+		if e.Op>>12 == 0x1 && !d.tables[ea] && e.Target > ea && (in == nil || e.Target != in.exit) {
+			if m, ok := d.at(e.Target, k, hi); ok && d.contiguous(k-1, m) {
+				d.body(i+2, k-1, depth+1, in)
+				d.keyword(ea, depth, "else")
+				d.body(k, m, depth+1, in)
+				d.keyword(ea, depth, "end")
+				return m, true
+			}
+		}
+	}
+	d.body(i+2, k, depth+1, in)
+	d.keyword(na, depth, "end")
+	return k, true
+}
+
+// format formats inst in Octo and records the targets of the jumps.
+func (d *decompiler) format(inst disasm.Inst) string {
+	switch inst.Flow {
+	case disasm.FlowJump, disasm.FlowJumpIndirect:
+		d.refs[inst.Target] = true
+	}
+	return d.p.Format(inst, disasm.SyntaxOcto)
+}
+
+// cond returns the condition on which the skip inst skips.
+func cond(inst disasm.Inst) string {
+	vx, vy := fmt.Sprintf("v%x", inst.X), fmt.Sprintf("v%x", inst.Y)
+	switch inst.Op >> 12 {
+	case 0x3:
+		return fmt.Sprintf("%s == 0x%02X", vx, inst.KK)
+	case 0x4:
+		return fmt.Sprintf("%s != 0x%02X", vx, inst.KK)
+	case 0x5:
+		return fmt.Sprintf("%s == %s", vx, vy)
+	case 0x9:
+		return fmt.Sprintf("%s != %s", vx, vy)
+	}
+	if inst.KK == 0x9e {
+		return vx + " key"
+	}
+	return vx + " -key"
+}
+
+var negations = strings.NewReplacer(" == ", " != ", " != ", " == ", " -key", " key", " key", " -key")
+
+func negate(c string) string {
+	return negations.Replace(c)
+}
@@ -0,0 +1,47 @@
+package decompile
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/masu-mi/gochip-8/cfg"
+	"github.com/masu-mi/gochip-8/disasm"
+)
+
+func decompile(t *testing.T, rom ...byte) string {
+	t.Helper()
+	var b strings.Builder
+	if e := Write(&b, cfg.Build(disasm.Analyze(rom, 0x200))); e != nil {
+		t.Fatal(e)
+	}
+	return b.String()
+}
+
+func TestJumpTable(t *testing.T) {
+	// the first entry of the table jumps back as `again` would do.
+	got := decompile(t,
+		0x00, 0xe0, // clear
+		0x60, 0x02, // v0 := 2
+		0xb2, 0x06, // jump0 table_206
+		0x12, 0x02, // jump loc_202
+		0x12, 0x0a, // jump loc_20a
+		0x00, 0xe0, // clear
+		0x12, 0x0a, // jump loc_20a
+	)
+	want := `: main
+	clear
+: loc_202
+	v0 := 0x02
+	jump0 table_206
+: table_206
+	jump loc_202
+	jump loc_20a
+: loc_20a
+	loop
+		clear
+	again
+`
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
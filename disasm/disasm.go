@@ -0,0 +1,233 @@
+// Package disasm decodes CHIP-8, SUPER-CHIP and XO-CHIP instructions.
+//
+// Mnemonics follow Cowgod's Chip-8 Technical Reference.
+// > ref. http://devernay.free.fr/hacks/chip8/C8TECH10.HTM#3.1
+package disasm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Flow tells how an instruction passes control to the next one.
+type Flow int
+
+const (
+	// FlowNext continues to the following instruction.
+	FlowNext Flow = iota
+	// FlowJump jumps to Target (1nnn, 0nnn).
+	FlowJump
+	// FlowJumpIndirect jumps to Target plus a register (Bnnn).
+	FlowJumpIndirect
+	// FlowCall calls the subroutine at Target (2nnn).
+	FlowCall
+	// FlowReturn returns from a subroutine (00EE).
+	FlowReturn
+	// FlowSkip may skip the following instruction (3xkk, 4xkk, 5xy0, 9xy0, Ex9E, ExA1).
+	FlowSkip
+	// FlowExit stops the program (00FD).
+	FlowExit
+	// FlowInvalid can't be executed.
+	FlowInvalid
+)
+
+// Inst is a decoded instruction.
+type Inst struct {
+	Op uint16
+	// Size is 4 for XO-CHIP's F000 nnnn and 2 for the others.
+	Size int
+
+	X, Y, N uint8
+	KK      uint8
+	NNN     uint16
+	// Long is the operand of F000 nnnn.
+	Long uint16
+
+	Name string
+	Args []string
+	Flow Flow
+	// Target is the destination of jumps and calls.
+	Target uint16
+}
+
+func (inst Inst) String() string {
+	if len(inst.Args) == 0 {
+		return inst.Name
+	}
+	return inst.Name + " " + strings.Join(inst.Args, ", ")
+}
+
+// Decode decodes the instruction at the head of code.
+// The second word is read only for F000 nnnn and is treated as 0 when code is too short.
+func Decode(code []byte) Inst {
+	var op uint16
+	if len(code) > 0 {
+		op = uint16(code[0]) << 8
+	}
+	if len(code) > 1 {
+		op |= uint16(code[1])
+	}
+	inst := Inst{
+		Op:   op,
+		Size: 2,
+		X:    uint8(op >> 8 & 0xf),
+		Y:    uint8(op >> 4 & 0xf),
+		N:    uint8(op & 0xf),
+		KK:   uint8(op & 0xff),
+		NNN:  op & 0xfff,
+	}
+	vx, vy := fmt.Sprintf("V%X", inst.X), fmt.Sprintf("V%X", inst.Y)
+	kk, nnn := fmt.Sprintf("0x%02X", inst.KK), fmt.Sprintf("0x%03X", inst.NNN)
+	set := func(name string, args ...string) {
+		inst.Name, inst.Args = name, nil
+		for _, a := range args {
+			if a != "" {
+				inst.Args = append(inst.Args, a)
+			}
+		}
+	}
+	switch op >> 12 {
+	case 0x0:
+		switch {
+		case op == 0x00e0:
+			set("CLS")
+		case op == 0x00ee:
+			set("RET")
+			inst.Flow = FlowReturn
+		case op&0xfff0 == 0x00c0:
+			set("SCD", fmt.Sprint(inst.N))
+		case op&0xfff0 == 0x00d0:
+			set("SCU", fmt.Sprint(inst.N))
+		case op == 0x00fb:
+			set("SCR")
+		case op == 0x00fc:
+			set("SCL")
+		case op == 0x00fd:
+			set("EXIT")
+			inst.Flow = FlowExit
+		case op == 0x00fe:
+			set("LOW")
+		case op == 0x00ff:
+			set("HIGH")
+		default:
+			set("SYS", nnn)
+			inst.Flow, inst.Target = FlowJump, inst.NNN
+		}
+	case 0x1:
+		set("JP", nnn)
+		inst.Flow, inst.Target = FlowJump, inst.NNN
+	case 0x2:
+		set("CALL", nnn)
+		inst.Flow, inst.Target = FlowCall, inst.NNN
+	case 0x3:
+		set("SE", vx, kk)
+		inst.Flow = FlowSkip
+	case 0x4:
+		set("SNE", vx, kk)
+		inst.Flow = FlowSkip
+	case 0x5:
+		switch inst.N {
+		case 0x0:
+			set("SE", vx, vy)
+			inst.Flow = FlowSkip
+		case 0x2:
+			set("SAVE", vx+" - "+vy)
+		case 0x3:
+			set("LOAD", vx+" - "+vy)
+		}
+	case 0x6:
+		set("LD", vx, kk)
+	case 0x7:
+		set("ADD", vx, kk)
+	case 0x8:
+		if name, ok := alu[inst.N]; ok {
+			set(name, vx, vy)
+		}
+	case 0x9:
+		if inst.N == 0 {
+			set("SNE", vx, vy)
+			inst.Flow = FlowSkip
+		}
+	case 0xA:
+		set("LD", "I", nnn)
+	case 0xB:
+		set("JP", "V0", nnn)
+		inst.Flow, inst.Target = FlowJumpIndirect, inst.NNN
+	case 0xC:
+		set("RND", vx, kk)
+	case 0xD:
+		set("DRW", vx, vy, fmt.Sprint(inst.N))
+	case 0xE:
+		switch inst.KK {
+		case 0x9e:
+			set("SKP", vx)
+			inst.Flow = FlowSkip
+		case 0xa1:
+			set("SKNP", vx)
+			inst.Flow = FlowSkip
+		}
+	case 0xF:
+		switch {
+		case op == 0xf000:
+			inst.Size = 4
+			if len(code) > 3 {
+				inst.Long = uint16(code[2])<<8 | uint16(code[3])
+			}
+			set("LD", "I", fmt.Sprintf("LONG 0x%04X", inst.Long))
+		case inst.KK == 0x01:
+			set("PLANE", fmt.Sprint(inst.X))
+		case op == 0xf002:
+			set("AUDIO")
+		default:
+			if f, ok := misc[inst.KK]; ok {
+				set(f.name, strings.ReplaceAll(f.a, "Vx", vx), strings.ReplaceAll(f.b, "Vx", vx))
+			}
+		}
+	}
+	if inst.Name == "" {
+		set("DW", fmt.Sprintf("0x%04X", op))
+		inst.Flow = FlowInvalid
+	}
+	return inst
+}
+
+// At decodes the instruction at addr of mem.
+// The instruction is DW 0x0000 when addr is out of mem.
+func At(mem []byte, addr uint16) Inst {
+	if int(addr) >= len(mem) {
+		return Inst{Size: 2, Name: "DW", Args: []string{"0x0000"}, Flow: FlowInvalid}
+	}
+	end := int(addr) + 4
+	if end > len(mem) {
+		end = len(mem)
+	}
+	return Decode(mem[addr:end])
+}
+
+var alu = map[uint8]string{
+	0x0: "LD",
+	0x1: "OR",
+	0x2: "AND",
+	0x3: "XOR",
+	0x4: "ADD",
+	0x5: "SUB",
+	0x6: "SHR",
+	0x7: "SUBN",
+	0xE: "SHL",
+}
+
+var misc = map[uint8]struct{ name, a, b string }{
+	0x07: {"LD", "Vx", "DT"},
+	0x0A: {"LD", "Vx", "K"},
+	0x15: {"LD", "DT", "Vx"},
+	0x18: {"LD", "ST", "Vx"},
+	0x1E: {"ADD", "I", "Vx"},
+	0x29: {"LD", "F", "Vx"},
+	0x30: {"LD", "HF", "Vx"},
+	0x33: {"LD", "B", "Vx"},
+	0x3A: {"PITCH", "Vx", ""},
+	0x55: {"LD", "[I]", "Vx"},
+	0x65: {"LD", "Vx", "[I]"},
+	0x75: {"LD", "R", "Vx"},
+	0x85: {"LD", "Vx", "R"},
+}
@@ -0,0 +1,377 @@
+package disasm
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Program is a ROM separated into code and data by Analyze.
+type Program struct {
+	// Base is the address where the ROM is loaded.
+	Base uint16
+	ROM  []byte
+	// Code holds the instructions reachable from Base by their addresses.
+	Code map[uint16]Inst
+	// Labels name the targets of jumps and calls and the data pointed by LD I.
+	Labels map[uint16]string
+	// Entries are the addresses of main and the subroutines.
+	Entries []uint16
+}
+
+// Label prefixes in the order of priority.
+var labelPrefixes = []string{"main", "sub", "loc", "table", "data"}
+
+// Analyze follows jumps, calls and skips from base by recursive descent.
+// Bytes which aren't reached are data. Bnnn is assumed to jump into a table of JP instructions at nnn.
+func Analyze(rom []byte, base uint16) *Program {
+	p := &Program{
+		Base:   base,
+		ROM:    rom,
+		Code:   map[uint16]Inst{},
+		Labels: map[uint16]string{},
+	}
+	p.label(base, "main")
+	p.Entries = append(p.Entries, base)
+	work := []uint16{base}
+	push := func(a uint16) {
+		if p.inROM(a) {
+			work = append(work, a)
+		}
+	}
+	for len(work) > 0 {
+		a := work[len(work)-1]
+		work = work[:len(work)-1]
+		for p.inROM(a) {
+			if _, ok := p.Code[a]; ok {
+				break
+			}
+			inst := p.decode(a)
+			if inst.Flow == FlowInvalid {
+				break
+			}
+			p.Code[a] = inst
+			if t, ok := dataRef(inst); ok && p.inROM(t) {
+				p.label(t, "data")
+			}
+			next := a + uint16(inst.Size)
+			stop := false
+			switch inst.Flow {
+			case FlowSkip:
+				push(next + uint16(p.decode(next).Size))
+			case FlowJump:
+				if p.inROM(inst.Target) {
+					p.label(inst.Target, "loc")
+				}
+				push(inst.Target)
+				stop = true
+			case FlowCall:
+				if p.inROM(inst.Target) {
+					if p.label(inst.Target, "sub") {
+						p.Entries = append(p.Entries, inst.Target)
+					}
+				}
+				push(inst.Target)
+			case FlowJumpIndirect:
+				if p.inROM(inst.Target) {
+					p.label(inst.Target, "table")
+				}
+				for t := inst.Target; p.inROM(t) && p.decode(t).Op>>12 == 0x1; t += 2 {
+					push(t)
+				}
+				stop = true
+			case FlowReturn, FlowExit:
+				stop = true
+			}
+			if stop {
+				break
+			}
+			a = next
+		}
+	}
+	sort.Slice(p.Entries, func(i, j int) bool { return p.Entries[i] < p.Entries[j] })
+	return p
+}
+
+func (p *Program) inROM(a uint16) bool {
+	return a >= p.Base && int(a) < int(p.Base)+len(p.ROM)
+}
+
+func (p *Program) decode(a uint16) Inst {
+	if !p.inROM(a) {
+		return Decode(nil)
+	}
+	return Decode(p.ROM[a-p.Base:])
+}
+
+// label names a by prefix unless it has a label of a higher priority.
+// It returns true when a has been named by prefix for the first time.
+func (p *Program) label(a uint16, prefix string) bool {
+	if name, ok := p.Labels[a]; ok {
+		if priority(labelPrefix(name)) <= priority(prefix) {
+			return false
+		}
+	}
+	if prefix == "main" {
+		p.Labels[a] = prefix
+		return true
+	}
+	p.Labels[a] = fmt.Sprintf("%s_%03x", prefix, a)
+	return true
+}
+
+func labelPrefix(name string) string {
+	if i := strings.IndexByte(name, '_'); i >= 0 {
+		return name[:i]
+	}
+	return name
+}
+
+func priority(prefix string) int {
+	for i, p := range labelPrefixes {
+		if p == prefix {
+			return i
+		}
+	}
+	return len(labelPrefixes)
+}
+
+// dataRef returns the address loaded into I by LD I, nnn or LD I, nnnn.
+func dataRef(inst Inst) (uint16, bool) {
+	switch {
+	case inst.Op>>12 == 0xA:
+		return inst.NNN, true
+	case inst.Op == 0xf000:
+		return inst.Long, true
+	}
+	return 0, false
+}
+
+// Syntax is a syntax of the output of Program.
+type Syntax int
+
+const (
+	// SyntaxOcto is the language of Octo.
+	// > ref. https://github.com/JohnEarnest/Octo/blob/gh-pages/docs/Manual.md
+	SyntaxOcto Syntax = iota
+	// SyntaxCowgod is the mnemonics of Cowgod's Chip-8 Technical Reference read by the asm package.
+	SyntaxCowgod
+)
+
+var syntaxNames = map[string]Syntax{
+	"octo":   SyntaxOcto,
+	"cowgod": SyntaxCowgod,
+}
+
+func ParseSyntax(name string) (Syntax, error) {
+	s, ok := syntaxNames[name]
+	if !ok {
+		return 0, fmt.Errorf("unknown syntax `%s` (octo, cowgod)", name)
+	}
+	return s, nil
+}
+
+// Write writes the program in syntax. Code is indented under labels and data is written a byte per line
+// in binary with the pixels of the byte as a comment.
+func (p *Program) Write(w io.Writer, syntax Syntax) error {
+	placed := p.placed()
+	f := &formatter{labels: placed}
+	var b strings.Builder
+	if syntax == SyntaxCowgod {
+		fmt.Fprintf(&b, "\tORG 0x%03X\n", p.Base)
+	}
+	end := int(p.Base) + len(p.ROM)
+	for a := int(p.Base); a < end; {
+		addr := uint16(a)
+		if name, ok := placed[addr]; ok {
+			if syntax == SyntaxOcto {
+				fmt.Fprintf(&b, ": %s\n", name)
+			} else {
+				fmt.Fprintf(&b, "%s:\n", name)
+			}
+		}
+		if inst, ok := p.Code[addr]; ok {
+			if syntax == SyntaxOcto {
+				fmt.Fprintf(&b, "\t%s\n", f.octo(inst))
+			} else {
+				fmt.Fprintf(&b, "\t%s\n", f.cowgod(inst))
+			}
+			a += inst.Size
+			continue
+		}
+		v := p.ROM[a-int(p.Base)]
+		if syntax == SyntaxOcto {
+			fmt.Fprintf(&b, "\t0b%08b # %s\n", v, pixels(v))
+		} else {
+			fmt.Fprintf(&b, "\tDB 0b%08b ; %s\n", v, pixels(v))
+		}
+		a++
+	}
+	_, e := io.WriteString(w, b.String())
+	return e
+}
+
+// Format formats inst in syntax with the labels of p.
+func (p *Program) Format(inst Inst, syntax Syntax) string {
+	f := &formatter{labels: p.Labels}
+	if syntax == SyntaxOcto {
+		return f.octo(inst)
+	}
+	return f.cowgod(inst)
+}
+
+// placed returns the labels at the heads of lines. Labels inside instructions can't be written.
+func (p *Program) placed() map[uint16]string {
+	placed := map[uint16]string{}
+	end := int(p.Base) + len(p.ROM)
+	for a := int(p.Base); a < end; {
+		if name, ok := p.Labels[uint16(a)]; ok {
+			placed[uint16(a)] = name
+		}
+		if inst, ok := p.Code[uint16(a)]; ok {
+			a += inst.Size
+			continue
+		}
+		a++
+	}
+	return placed
+}
+
+func pixels(v uint8) string {
+	return strings.NewReplacer("0", ".", "1", "#").Replace(fmt.Sprintf("%08b", v))
+}
+
+type formatter struct {
+	labels map[uint16]string
+}
+
+// addr returns the label of a or a as a number.
+func (f *formatter) addr(a uint16, digits int) string {
+	if name, ok := f.labels[a]; ok {
+		return name
+	}
+	return fmt.Sprintf("0x%0*X", digits, a)
+}
+
+func (f *formatter) cowgod(inst Inst) string {
+	args := append([]string(nil), inst.Args...)
+	switch {
+	case inst.Op == 0xf000:
+		args[1] = "LONG " + f.addr(inst.Long, 4)
+	case inst.Op>>12 == 0xA || inst.Op>>12 == 0xB:
+		args[1] = f.addr(inst.NNN, 3)
+	case inst.Flow == FlowJump || inst.Flow == FlowCall:
+		args[0] = f.addr(inst.NNN, 3)
+	}
+	return Inst{Name: inst.Name, Args: args}.String()
+}
+
+// octoNames are Octo's statements of instructions without operands.
+var octoNames = map[uint16]string{
+	0x00e0: "clear",
+	0x00ee: "return",
+	0x00fb: "scroll-right",
+	0x00fc: "scroll-left",
+	0x00fd: "exit",
+	0x00fe: "lores",
+	0x00ff: "hires",
+	0xf002: "audio",
+}
+
+// octoALU are Octo's operators of 8xyn.
+var octoALU = map[uint8]string{
+	0x0: ":=",
+	0x1: "|=",
+	0x2: "&=",
+	0x3: "^=",
+	0x4: "+=",
+	0x5: "-=",
+	0x6: ">>=",
+	0x7: "=-",
+	0xE: "<<=",
+}
+
+// octoMisc are Octo's statements of Fxkk with the register as %s.
+var octoMisc = map[uint8]string{
+	0x07: "%s := delay",
+	0x0A: "%s := key",
+	0x15: "delay := %s",
+	0x18: "buzzer := %s",
+	0x1E: "i += %s",
+	0x29: "i := hex %s",
+	0x30: "i := bighex %s",
+	0x33: "bcd %s",
+	0x3A: "pitch := %s",
+	0x55: "save %s",
+	0x65: "load %s",
+	0x75: "saveflags %s",
+	0x85: "loadflags %s",
+}
+
+func (f *formatter) octo(inst Inst) string {
+	vx, vy := fmt.Sprintf("v%x", inst.X), fmt.Sprintf("v%x", inst.Y)
+	kk := fmt.Sprintf("0x%02X", inst.KK)
+	if s, ok := octoNames[inst.Op]; ok {
+		return s
+	}
+	switch inst.Op >> 12 {
+	case 0x0:
+		switch inst.Op & 0xfff0 {
+		case 0x00c0:
+			return fmt.Sprintf("scroll-down %d", inst.N)
+		case 0x00d0:
+			return fmt.Sprintf("scroll-up %d", inst.N)
+		}
+		// Octo has no statement of 0nnn.
+		return fmt.Sprintf("0x%02X 0x%02X # SYS 0x%03X", inst.Op>>8, inst.Op&0xff, inst.NNN)
+	case 0x1:
+		return "jump " + f.addr(inst.NNN, 3)
+	case 0x2:
+		if _, ok := f.labels[inst.NNN]; ok {
+			return f.labels[inst.NNN]
+		}
+		return ":call " + f.addr(inst.NNN, 3)
+	// Octo's if executes the next statement when the condition holds, so it's the negation of the skip.
+	case 0x3:
+		return fmt.Sprintf("if %s != %s then", vx, kk)
+	case 0x4:
+		return fmt.Sprintf("if %s == %s then", vx, kk)
+	case 0x5:
+		switch inst.N {
+		case 0x2:
+			return fmt.Sprintf("save %s - %s", vx, vy)
+		case 0x3:
+			return fmt.Sprintf("load %s - %s", vx, vy)
+		}
+		return fmt.Sprintf("if %s != %s then", vx, vy)
+	case 0x6:
+		return fmt.Sprintf("%s := %s", vx, kk)
+	case 0x7:
+		return fmt.Sprintf("%s += %s", vx, kk)
+	case 0x8:
+		return fmt.Sprintf("%s %s %s", vx, octoALU[inst.N], vy)
+	case 0x9:
+		return fmt.Sprintf("if %s == %s then", vx, vy)
+	case 0xA:
+		return "i := " + f.addr(inst.NNN, 3)
+	case 0xB:
+		return "jump0 " + f.addr(inst.NNN, 3)
+	case 0xC:
+		return fmt.Sprintf("%s := random %s", vx, kk)
+	case 0xD:
+		return fmt.Sprintf("sprite %s %s %d", vx, vy, inst.N)
+	case 0xE:
+		if inst.KK == 0x9e {
+			return fmt.Sprintf("if %s -key then", vx)
+		}
+		return fmt.Sprintf("if %s key then", vx)
+	}
+	switch {
+	case inst.Op == 0xf000:
+		return "i := long " + f.addr(inst.Long, 4)
+	case inst.KK == 0x01:
+		return fmt.Sprintf("plane %d", inst.X)
+	}
+	return fmt.Sprintf(octoMisc[inst.KK], vx)
+}
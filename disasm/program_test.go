@@ -0,0 +1,151 @@
+package disasm
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/masu-mi/gochip-8/asm"
+	"github.com/masu-mi/gochip-8/octo"
+)
+
+// roundTrip has every instruction, a subroutine, a table of JP V0 and data.
+const roundTrip = `
+main:
+	CLS
+	HIGH
+	LOW
+	SCD 4
+	SCU 4
+	SCR
+	SCL
+	CALL sub
+	SE V1, 0x22
+	SNE V1, 0x22
+	SE V1, V2
+	SNE V1, V2
+	SKP V1
+	SKNP V1
+	LD V1, 0x22
+	ADD V1, 0x22
+	LD V1, V2
+	OR V1, V2
+	AND V1, V2
+	XOR V1, V2
+	ADD V1, V2
+	SUB V1, V2
+	SHR V1
+	SHR V1, V2
+	SUBN V1, V2
+	SHL V1
+	SHL V1, V2
+	RND V1, 0x22
+	LD I, sprite
+	DRW V1, V2, 5
+	LD I, LONG sprite
+	JP V0, table
+	DB 0xff, 0x00
+table:
+	JP loop
+	JP done
+	JP native
+sub:
+	LD V1, DT
+	LD V1, K
+	LD DT, V1
+	LD ST, V1
+	LD F, V1
+	LD HF, V1
+	LD B, V1
+	LD [I], V1
+	LD V1, [I]
+	LD R, V1
+	LD V1, R
+	ADD I, V1
+	PLANE 3
+	AUDIO
+	PITCH V1
+	SAVE V1 - V3
+	LOAD V1 - V3
+	RET
+loop:
+	JP loop
+done:
+	EXIT
+native:
+	SYS 0x123
+sprite:
+	DB 0xf0, 0x90, 0x90, 0x90, 0xf0
+`
+
+func TestRoundTrip(t *testing.T) {
+	src, e := asm.Assemble("test.asm", []byte(roundTrip))
+	if e != nil {
+		t.Fatal(e)
+	}
+	p := Analyze(src.ROM, src.Base)
+	for _, tc := range []struct {
+		syntax  Syntax
+		compile func(file string, src []byte) (*asm.Program, error)
+	}{
+		{SyntaxCowgod, asm.Assemble},
+		{SyntaxOcto, octo.Compile},
+	} {
+		var b strings.Builder
+		if e := p.Write(&b, tc.syntax); e != nil {
+			t.Fatal(e)
+		}
+		got, e := tc.compile("test", []byte(b.String()))
+		if e != nil {
+			t.Errorf("syntax %d: %v\n%s", tc.syntax, e, b.String())
+			continue
+		}
+		if !bytes.Equal(got.ROM, src.ROM) {
+			t.Errorf("syntax %d: % x; want % x\n%s", tc.syntax, got.ROM, src.ROM, b.String())
+		}
+	}
+}
+
+func TestAt(t *testing.T) {
+	mem := []byte{0x00, 0xe0, 0x12}
+	for _, tc := range []struct {
+		addr uint16
+		want string
+		flow Flow
+	}{
+		{0, "CLS", FlowNext},
+		{2, "JP 0x200", FlowJump},
+		{3, "DW 0x0000", FlowInvalid},
+		{0x1000, "DW 0x0000", FlowInvalid},
+	} {
+		if got := At(mem, tc.addr); got.String() != tc.want || got.Flow != tc.flow {
+			t.Errorf("At(0x%x) = %s (flow %d); want %s (flow %d)", tc.addr, got, got.Flow, tc.want, tc.flow)
+		}
+	}
+}
+
+func TestAnalyze(t *testing.T) {
+	src, e := asm.Assemble("test.asm", []byte(roundTrip))
+	if e != nil {
+		t.Fatal(e)
+	}
+	p := Analyze(src.ROM, src.Base)
+	for addr, want := range map[uint16]string{
+		src.Base:                     "main",
+		src.Symbols.Labels["table"]:  "table_",
+		src.Symbols.Labels["sub"]:    "sub_",
+		src.Symbols.Labels["loop"]:   "loc_",
+		src.Symbols.Labels["sprite"]: "data_",
+	} {
+		if !strings.HasPrefix(p.Labels[addr], want) {
+			t.Errorf("label of 0x%03x = %q; want %s", addr, p.Labels[addr], want)
+		}
+	}
+	if want := []uint16{src.Base, src.Symbols.Labels["sub"]}; len(p.Entries) != 2 || p.Entries[0] != want[0] || p.Entries[1] != want[1] {
+		t.Errorf("Entries = %x; want %x", p.Entries, want)
+	}
+	// the bytes after JP V0 aren't reached.
+	if _, ok := p.Code[src.Symbols.Labels["table"]-2]; ok {
+		t.Error("data before the table is decoded as code")
+	}
+}
@@ -0,0 +1,390 @@
+// Package gdbstub serves a CHIP-8 machine to gdb over the Remote Serial Protocol.
+//
+// > ref. https://sourceware.org/gdb/onlinedocs/gdb/Remote-Protocol.html
+package gdbstub
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/masu-mi/gochip-8/core"
+	"github.com/masu-mi/gochip-8/debugger"
+)
+
+// Signals of stop replies.
+const (
+	sigInt  = 0x02
+	sigIll  = 0x04
+	sigTrap = 0x05
+	sigSegv = 0x0b
+)
+
+// Server is a stub of gdb debugging the machine of Debugger.
+type Server struct {
+	Debugger *debugger.Debugger
+	// Keypad is pressed by the monitor commands press and release when it's not nil.
+	Keypad *core.Keypad
+
+	// points map the breakpoints and watchpoints of Z packets to Debugger.
+	points map[string]int
+	stop   debugger.Stop
+}
+
+func New(d *debugger.Debugger) *Server {
+	return &Server{
+		Debugger: d,
+		points:   map[string]int{},
+		stop:     debugger.Stop{Reason: debugger.ReasonStep},
+	}
+}
+
+// Serve accepts gdb on l one by one until ctx is done.
+func (s *Server) Serve(ctx context.Context, l net.Listener) error {
+	go func() {
+		<-ctx.Done()
+		l.Close()
+	}()
+	for {
+		c, e := l.Accept()
+		if e != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return e
+		}
+		e = s.ServeConn(ctx, c)
+		c.Close()
+		if e != nil && !errors.Is(e, io.EOF) {
+			return e
+		}
+	}
+}
+
+// errDetach ends a session by D or k packets.
+var errDetach = errors.New("detached")
+
+// ServeConn talks with gdb on rw until it detaches.
+func (s *Server) ServeConn(ctx context.Context, rw io.ReadWriter) error {
+	c := newConn(rw)
+	events := make(chan event)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			ev := c.read()
+			select {
+			case events <- ev:
+			case <-done:
+				return
+			}
+			if ev.err != nil {
+				return
+			}
+		}
+	}()
+	for {
+		var ev event
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev = <-events:
+		}
+		if ev.err != nil {
+			return ev.err
+		}
+		if ev.interrupt {
+			continue
+		}
+		reply, e := s.handle(ctx, c, ev.packet, events)
+		if errors.Is(e, errDetach) {
+			return c.send("OK")
+		}
+		if e != nil {
+			return e
+		}
+		if e := c.send(reply); e != nil {
+			return e
+		}
+	}
+}
+
+// handle returns the reply to p. Unsupported packets have the empty reply.
+func (s *Server) handle(ctx context.Context, c *conn, p string, events <-chan event) (string, error) {
+	d := s.Debugger
+	switch {
+	case strings.HasPrefix(p, "qSupported"):
+		return "PacketSize=4000;qXfer:features:read+;QStartNoAckMode+;swbreak+;hwbreak+", nil
+	case p == "QStartNoAckMode":
+		return "OK", nil
+	case strings.HasPrefix(p, "qXfer:features:read:target.xml:"):
+		return xfer(targetXML, strings.TrimPrefix(p, "qXfer:features:read:target.xml:"))
+	case p == "?":
+		return s.stopReply(), nil
+	case p == "qAttached":
+		return "1", nil
+	case p == "qC":
+		return "QC1", nil
+	case p == "qfThreadInfo":
+		return "m1", nil
+	case p == "qsThreadInfo":
+		return "l", nil
+	case strings.HasPrefix(p, "H"), strings.HasPrefix(p, "T"):
+		return "OK", nil
+	case p == "g":
+		return s.readRegisters(), nil
+	case strings.HasPrefix(p, "G"):
+		return s.writeRegisters(p[1:]), nil
+	case strings.HasPrefix(p, "p"):
+		n, e := strconv.ParseUint(p[1:], 16, 8)
+		if e != nil || int(n) >= len(registers) {
+			return "E01", nil
+		}
+		return s.readRegister(registers[n]), nil
+	case strings.HasPrefix(p, "P"):
+		return s.writeRegister(p[1:]), nil
+	case strings.HasPrefix(p, "m"):
+		addr, n, ok := addrLen(p[1:])
+		if !ok {
+			return "E01", nil
+		}
+		buf := make([]byte, n)
+		return hex.EncodeToString(buf[:d.ReadMemory(addr, buf)]), nil
+	case strings.HasPrefix(p, "M"):
+		return s.writeMemory(p[1:]), nil
+	case strings.HasPrefix(p, "c"):
+		return s.resume(ctx, d.Continue, events)
+	case strings.HasPrefix(p, "s"):
+		return s.resume(ctx, d.Step, events)
+	case strings.HasPrefix(p, "Z"), strings.HasPrefix(p, "z"):
+		return s.point(p), nil
+	case strings.HasPrefix(p, "qRcmd,"):
+		return s.monitor(ctx, c, p[len("qRcmd,"):])
+	case p == "D" || strings.HasPrefix(p, "D;"), p == "k":
+		return "", errDetach
+	}
+	return "", nil
+}
+
+// xfer returns the part of doc requested by "offset,length" of qXfer.
+func xfer(doc, args string) (string, error) {
+	var off, n int
+	if _, e := fmt.Sscanf(args, "%x,%x", &off, &n); e != nil {
+		return "E01", nil
+	}
+	if off >= len(doc) {
+		return "l", nil
+	}
+	if off+n >= len(doc) {
+		return "l" + doc[off:], nil
+	}
+	return "m" + doc[off:off+n], nil
+}
+
+func (s *Server) readRegister(r register) string {
+	v, _ := s.Debugger.Register(r.name)
+	return r.encode(v)
+}
+
+func (s *Server) readRegisters() string {
+	var b strings.Builder
+	for _, r := range registers {
+		b.WriteString(s.readRegister(r))
+	}
+	return b.String()
+}
+
+func (s *Server) writeRegisters(data string) string {
+	for _, r := range registers {
+		if len(data) < r.size*2 {
+			return "E01"
+		}
+		if reply := s.setRegister(r, data[:r.size*2]); reply != "OK" {
+			return reply
+		}
+		data = data[r.size*2:]
+	}
+	return "OK"
+}
+
+func (s *Server) writeRegister(arg string) string {
+	i := strings.IndexByte(arg, '=')
+	if i < 0 {
+		return "E01"
+	}
+	n, e := strconv.ParseUint(arg[:i], 16, 8)
+	if e != nil || int(n) >= len(registers) {
+		return "E01"
+	}
+	return s.setRegister(registers[n], arg[i+1:])
+}
+
+func (s *Server) setRegister(r register, data string) string {
+	v, ok := r.decode(data)
+	if !ok {
+		return "E01"
+	}
+	if e := s.Debugger.SetRegister(r.name, v); e != nil {
+		return "E02"
+	}
+	return "OK"
+}
+
+func (s *Server) writeMemory(arg string) string {
+	i := strings.IndexByte(arg, ':')
+	if i < 0 {
+		return "E01"
+	}
+	addr, n, ok := addrLen(arg[:i])
+	if !ok {
+		return "E01"
+	}
+	data, e := hex.DecodeString(arg[i+1:])
+	if e != nil || len(data) != n {
+		return "E01"
+	}
+	if e := s.Debugger.WriteMemory(addr, data); e != nil {
+		return "E02"
+	}
+	return "OK"
+}
+
+// addrLen parses "addr,length".
+func addrLen(arg string) (uint16, int, bool) {
+	var addr, n uint64
+	if _, e := fmt.Sscanf(arg, "%x,%x", &addr, &n); e != nil || addr > 0xffff || n > 0x10000 {
+		return 0, 0, false
+	}
+	return uint16(addr), int(n), true
+}
+
+// resume runs f until it stops or gdb interrupts it.
+func (s *Server) resume(ctx context.Context, f func(context.Context) (debugger.Stop, error), events <-chan event) (string, error) {
+	c, cancel := context.WithCancel(ctx)
+	defer cancel()
+	type result struct {
+		stop debugger.Stop
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		stop, e := f(c)
+		done <- result{stop, e}
+	}()
+	for {
+		select {
+		case r := <-done:
+			if r.err != nil {
+				return "E01", nil
+			}
+			s.stop = r.stop
+			return s.stopReply(), nil
+		case ev := <-events:
+			if ev.err != nil {
+				cancel()
+				<-done
+				return "", ev.err
+			}
+			if ev.interrupt {
+				cancel()
+			}
+		}
+	}
+}
+
+func (s *Server) stopReply() string {
+	stop := s.stop
+	switch stop.Reason {
+	case debugger.ReasonExit:
+		return "W00"
+	case debugger.ReasonInterrupt:
+		return fmt.Sprintf("S%02x", sigInt)
+	case debugger.ReasonFault:
+		if errors.Is(stop.Fault, core.ErrInvalidOpcode) {
+			return fmt.Sprintf("S%02x", sigIll)
+		}
+		return fmt.Sprintf("S%02x", sigSegv)
+	case debugger.ReasonBreakpoint:
+		return fmt.Sprintf("T%02xswbreak:;", sigTrap)
+	case debugger.ReasonWatchpoint:
+		if bp := stop.Breakpoint; bp != nil && bp.Kind == debugger.KindMemory {
+			kind := map[core.Access]string{
+				debugger.WatchWrite:  "watch",
+				debugger.WatchRead:   "rwatch",
+				debugger.WatchAccess: "awatch",
+			}[bp.Access]
+			return fmt.Sprintf("T%02x%s:%x;", sigTrap, kind, bp.Addr)
+		}
+	}
+	return fmt.Sprintf("S%02x", sigTrap)
+}
+
+// point inserts or removes a breakpoint or a watchpoint by "Ztype,addr,kind".
+func (s *Server) point(p string) string {
+	insert := p[0] == 'Z'
+	var typ, kind int
+	var addr uint64
+	if _, e := fmt.Sscanf(p[1:], "%d,%x,%x", &typ, &addr, &kind); e != nil || addr > 0xffff {
+		return "E01"
+	}
+	access := map[int]core.Access{
+		2: debugger.WatchWrite,
+		3: debugger.WatchRead,
+		4: debugger.WatchAccess,
+	}
+	key := p[1:]
+	if typ > 1 {
+		if _, ok := access[typ]; !ok {
+			return ""
+		}
+	}
+	if !insert {
+		if id, ok := s.points[key]; ok {
+			s.Debugger.Delete(id)
+			delete(s.points, key)
+		}
+		return "OK"
+	}
+	if _, ok := s.points[key]; ok {
+		return "OK"
+	}
+	var bp *debugger.Breakpoint
+	if typ <= 1 {
+		bp = s.Debugger.SetBreakpoint(uint16(addr), nil)
+	} else {
+		if kind < 1 {
+			kind = 1
+		}
+		bp = s.Debugger.SetWatchpoint(access[typ], uint16(addr), kind)
+	}
+	s.points[key] = bp.ID
+	return "OK"
+}
+
+// monitor runs the command of `monitor` in gdb with the commands of debugger.REPL.
+// Commands running the program are refused as Ctrl-C can't interrupt them: gdb's c and s run it instead.
+func (s *Server) monitor(ctx context.Context, c *conn, arg string) (string, error) {
+	cmd, e := hex.DecodeString(arg)
+	if e != nil {
+		return "E01", nil
+	}
+	out := &bytes.Buffer{}
+	repl := debugger.NewREPL(s.Debugger, out)
+	repl.Keypad = s.Keypad
+	repl.Passive = true
+	if e := repl.Exec(ctx, string(cmd)); e != nil {
+		fmt.Fprintln(out, e)
+	}
+	if out.Len() > 0 {
+		if e := c.send("O" + hex.EncodeToString(out.Bytes())); e != nil {
+			return "", e
+		}
+	}
+	return "OK", nil
+}
@@ -0,0 +1,129 @@
+package gdbstub
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/masu-mi/gochip-8/core"
+	"github.com/masu-mi/gochip-8/debugger"
+)
+
+// client talks to Server as gdb does, acknowledging every packet.
+type client struct {
+	t *testing.T
+	c net.Conn
+	r *bufio.Reader
+}
+
+func (c *client) send(p string) {
+	c.t.Helper()
+	if _, e := fmt.Fprintf(c.c, "$%s#%02x", p, checksum([]byte(p))); e != nil {
+		c.t.Fatal(e)
+	}
+	if ack, e := c.r.ReadByte(); e != nil || ack != '+' {
+		c.t.Fatalf("ack of %q = %q, %v", p, ack, e)
+	}
+}
+
+func (c *client) receive() string {
+	c.t.Helper()
+	if _, e := c.r.ReadString('$'); e != nil {
+		c.t.Fatal(e)
+	}
+	data, e := c.r.ReadString('#')
+	if e != nil {
+		c.t.Fatal(e)
+	}
+	if _, e := c.r.Discard(2); e != nil {
+		c.t.Fatal(e)
+	}
+	if _, e := c.c.Write([]byte{'+'}); e != nil {
+		c.t.Fatal(e)
+	}
+	return string(unescape([]byte(data[:len(data)-1])))
+}
+
+func (c *client) exchange(p string) string {
+	c.t.Helper()
+	c.send(p)
+	return c.receive()
+}
+
+func TestServeConn(t *testing.T) {
+	chip := &core.Chip8{
+		Cpu:      core.NewCpu(nil, nil),
+		Memory:   core.NewMemory(core.MemorySize),
+		Display:  core.NewFrameBuffer(),
+		Keyboard: core.NewKeypad(),
+	}
+	t.Cleanup(chip.Close)
+	// LD V0, 1; LD V1, 2; JP 0x204
+	if _, e := chip.Init(bytes.NewReader([]byte{0x60, 0x01, 0x61, 0x02, 0x12, 0x04})); e != nil {
+		t.Fatal(e)
+	}
+	server, gdb := net.Pipe()
+	defer gdb.Close()
+	done := make(chan error, 1)
+	go func() {
+		done <- New(debugger.New(chip)).ServeConn(context.Background(), server)
+		server.Close()
+	}()
+	c := &client{t: t, c: gdb, r: bufio.NewReader(gdb)}
+
+	regs := strings.Repeat("00", 16) + "0000" + "0200" + "000000"
+	for _, tc := range []struct {
+		packet, want string
+	}{
+		{"g", regs},
+		{"m200,4", "60016102"},
+		{"Z0,202,2", "OK"},
+		{"c", "T05swbreak:;"},
+		{"p0", "01"},
+		{"p11", "0202"},
+		{"P1=ff", "OK"},
+		{"g", "01ff" + strings.Repeat("00", 14) + "0000" + "0202" + "000000"},
+		{"z0,202,2", "OK"},
+		{"M300,2:abcd", "OK"},
+		{"m300,2", "abcd"},
+	} {
+		if got := c.exchange(tc.packet); got != tc.want {
+			t.Errorf("%s = %q; want %q", tc.packet, got, tc.want)
+		}
+	}
+
+	// monitor refuses running the program, which Ctrl-C couldn't stop.
+	c.send("qRcmd," + hex.EncodeToString([]byte("continue")))
+	out, e := hex.DecodeString(strings.TrimPrefix(c.receive(), "O"))
+	if e != nil || !strings.Contains(string(out), "runs the program") {
+		t.Errorf("monitor continue printed %q, %v", out, e)
+	}
+	if got := c.receive(); got != "OK" {
+		t.Errorf("monitor continue = %q; want OK", got)
+	}
+	if chip.Pc != 0x202 {
+		t.Errorf("Pc = 0x%03x after monitor continue; want 0x202", chip.Pc)
+	}
+
+	// the server hangs up after the reply, which isn't acknowledged then.
+	c.send("D")
+	if got, _ := c.r.ReadString('#'); got != "$OK#" {
+		t.Errorf("D = %q; want OK", got)
+	}
+	if e := <-done; e != nil {
+		t.Errorf("ServeConn = %v", e)
+	}
+}
+
+func TestTargetXML(t *testing.T) {
+	for _, want := range []string{"<architecture>chip8</architecture>", `name="pc" bitsize="16"`, `name="vf" bitsize="8"`} {
+		if !strings.Contains(targetXML, want) {
+			t.Errorf("target description lacks %s", want)
+		}
+	}
+}
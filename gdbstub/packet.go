@@ -0,0 +1,121 @@
+package gdbstub
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// interrupt is the byte sent by gdb on Ctrl-C out of packets.
+const interrupt = 0x03
+
+var errChecksum = errors.New("checksum mismatch")
+
+// event is a packet or an interrupt read from gdb.
+type event struct {
+	packet    string
+	interrupt bool
+	err       error
+}
+
+// conn frames packets of the Remote Serial Protocol.
+// > ref. https://sourceware.org/gdb/onlinedocs/gdb/Overview.html
+type conn struct {
+	r     *bufio.Reader
+	w     io.Writer
+	noAck bool
+}
+
+func newConn(rw io.ReadWriter) *conn {
+	return &conn{r: bufio.NewReader(rw), w: rw}
+}
+
+// read reads the next packet or interrupt. Acknowledgments from gdb are skipped
+// as retransmissions aren't needed on TCP.
+func (c *conn) read() event {
+	for {
+		b, e := c.r.ReadByte()
+		if e != nil {
+			return event{err: e}
+		}
+		switch b {
+		case interrupt:
+			return event{interrupt: true}
+		case '$':
+		default:
+			continue
+		}
+		data, e := c.r.ReadBytes('#')
+		if e != nil {
+			return event{err: e}
+		}
+		sum := make([]byte, 2)
+		if _, e := io.ReadFull(c.r, sum); e != nil {
+			return event{err: e}
+		}
+		data = data[:len(data)-1]
+		var want uint8
+		if _, e := fmt.Sscanf(string(sum), "%02x", &want); e != nil || want != checksum(data) {
+			if !c.noAck {
+				if _, e := c.w.Write([]byte{'-'}); e != nil {
+					return event{err: e}
+				}
+			}
+			continue
+		}
+		if !c.noAck {
+			if _, e := c.w.Write([]byte{'+'}); e != nil {
+				return event{err: e}
+			}
+		}
+		p := string(unescape(data))
+		// gdb stops acknowledging after the reply of QStartNoAckMode.
+		if p == "QStartNoAckMode" {
+			c.noAck = true
+		}
+		return event{packet: p}
+	}
+}
+
+// send writes data as a packet.
+func (c *conn) send(data string) error {
+	esc := escape([]byte(data))
+	_, e := fmt.Fprintf(c.w, "$%s#%02x", esc, checksum(esc))
+	return e
+}
+
+func checksum(data []byte) uint8 {
+	var sum uint8
+	for _, b := range data {
+		sum += b
+	}
+	return sum
+}
+
+// escape escapes the bytes reserved by the framing.
+func escape(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	for _, b := range data {
+		switch b {
+		case '$', '#', '}', '*':
+			out = append(out, '}', b^0x20)
+		default:
+			out = append(out, b)
+		}
+	}
+	return out
+}
+
+func unescape(data []byte) []byte {
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); i++ {
+		if data[i] == '}' && i+1 < len(data) {
+			i++
+			out = append(out, data[i]^0x20)
+			continue
+		}
+		out = append(out, data[i])
+	}
+	return out
+}
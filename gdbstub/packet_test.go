@@ -0,0 +1,50 @@
+package gdbstub
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSendFrames(t *testing.T) {
+	for _, tc := range []struct {
+		data, want string
+	}{
+		{"OK", "$OK#9a"},
+		{"", "$#00"},
+		{"a$b", "$a}\x04b#44"},
+	} {
+		var b bytes.Buffer
+		if e := newConn(&b).send(tc.data); e != nil {
+			t.Fatal(e)
+		}
+		if got := b.String(); got != tc.want {
+			t.Errorf("send(%q) = %q; want %q", tc.data, got, tc.want)
+		}
+	}
+}
+
+func TestEscape(t *testing.T) {
+	data := []byte("a$b#c}d*e")
+	esc := escape(data)
+	if bytes.ContainsAny(esc, "$#*") {
+		t.Errorf("escape(%q) = %q has reserved bytes", data, esc)
+	}
+	if got := unescape(esc); !bytes.Equal(got, data) {
+		t.Errorf("unescape(%q) = %q; want %q", esc, got, data)
+	}
+}
+
+func TestReadPackets(t *testing.T) {
+	in := bytes.NewBufferString("+$g#67$m0,1#00$m0,1#fa\x03")
+	var out bytes.Buffer
+	c := &conn{r: newConn(in).r, w: &out}
+	for _, want := range []event{{packet: "g"}, {packet: "m0,1"}, {interrupt: true}} {
+		if got := c.read(); got != want {
+			t.Errorf("read() = %+v; want %+v", got, want)
+		}
+	}
+	// the packet with the wrong checksum is refused and skipped.
+	if got := out.String(); got != "+-+" {
+		t.Errorf("acknowledged %q; want %q", got, "+-+")
+	}
+}
@@ -0,0 +1,75 @@
+package gdbstub
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// architecture names the target in the target description.
+// gdb has no CHIP-8 architecture; it ignores the name with a warning and debugs the registers described here.
+const architecture = "chip8"
+
+// byteOrder is the order of the bytes of registers in g, G, p and P packets: most-significant byte first
+// like instructions of CHIP-8. gdb has to be told it by `set endian big`.
+var byteOrder = binary.BigEndian
+
+// register is a register of the target description in the order of the g packet.
+type register struct {
+	name string
+	// size is in bytes.
+	size int
+	typ  string
+}
+
+// registers are V0-VF, I, PC, SP, DT and ST.
+var registers = func() []register {
+	regs := make([]register, 0, 21)
+	for i := 0; i < 16; i++ {
+		regs = append(regs, register{name: fmt.Sprintf("V%X", i), size: 1, typ: "uint8"})
+	}
+	return append(regs,
+		register{name: "I", size: 2, typ: "data_ptr"},
+		register{name: "PC", size: 2, typ: "code_ptr"},
+		register{name: "SP", size: 1, typ: "uint8"},
+		register{name: "DT", size: 1, typ: "uint8"},
+		register{name: "ST", size: 1, typ: "uint8"},
+	)
+}()
+
+// targetXML is the target description read by qXfer:features:read.
+// > ref. https://sourceware.org/gdb/onlinedocs/gdb/Target-Descriptions.html
+var targetXML = func() string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0"?>
+<!DOCTYPE target SYSTEM "gdb-target.dtd">
+<target version="1.0">
+`)
+	fmt.Fprintf(&b, "  <architecture>%s</architecture>\n", architecture)
+	b.WriteString(`  <feature name="org.gochip-8.cpu">
+`)
+	for i, r := range registers {
+		fmt.Fprintf(&b, "    <reg name=\"%s\" bitsize=\"%d\" regnum=\"%d\" type=\"%s\" group=\"general\"/>\n", strings.ToLower(r.name), r.size*8, i, r.typ)
+	}
+	b.WriteString("  </feature>\n</target>\n")
+	return b.String()
+}()
+
+// encode returns v as the hex digits of the register in byteOrder.
+func (r register) encode(v uint16) string {
+	buf := make([]byte, 2)
+	byteOrder.PutUint16(buf, v)
+	return hex.EncodeToString(buf[2-r.size:])
+}
+
+// decode parses the hex digits of the register in byteOrder.
+func (r register) decode(data string) (uint16, bool) {
+	b, e := hex.DecodeString(data)
+	if e != nil || len(b) != r.size {
+		return 0, false
+	}
+	buf := make([]byte, 2)
+	copy(buf[2-r.size:], b)
+	return byteOrder.Uint16(buf), true
+}
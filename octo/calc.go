@@ -0,0 +1,153 @@
+package octo
+
+import (
+	"math"
+
+	"github.com/masu-mi/gochip-8/asm"
+)
+
+// binaries are the binary operators of :calc.
+var binaries = map[string]func(a, b float64) float64{
+	"+":   func(a, b float64) float64 { return a + b },
+	"-":   func(a, b float64) float64 { return a - b },
+	"*":   func(a, b float64) float64 { return a * b },
+	"/":   func(a, b float64) float64 { return a / b },
+	"%":   func(a, b float64) float64 { return math.Mod(a, b) },
+	"&":   func(a, b float64) float64 { return float64(int64(a) & int64(b)) },
+	"|":   func(a, b float64) float64 { return float64(int64(a) | int64(b)) },
+	"^":   func(a, b float64) float64 { return float64(int64(a) ^ int64(b)) },
+	"<<":  func(a, b float64) float64 { return float64(int64(a) << (uint64(b) & 63)) },
+	">>":  func(a, b float64) float64 { return float64(int64(a) >> (uint64(b) & 63)) },
+	"pow": math.Pow,
+	"min": math.Min,
+	"max": math.Max,
+	"<":   func(a, b float64) float64 { return truth(a < b) },
+	">":   func(a, b float64) float64 { return truth(a > b) },
+	"<=":  func(a, b float64) float64 { return truth(a <= b) },
+	">=":  func(a, b float64) float64 { return truth(a >= b) },
+	"==":  func(a, b float64) float64 { return truth(a == b) },
+	"!=":  func(a, b float64) float64 { return truth(a != b) },
+}
+
+// unaries are the unary operators of :calc except `@`, which reads the ROM.
+var unaries = map[string]func(a float64) float64{
+	"-":     func(a float64) float64 { return -a },
+	"~":     func(a float64) float64 { return float64(^int64(a)) },
+	"!":     func(a float64) float64 { return truth(a == 0) },
+	"sin":   math.Sin,
+	"cos":   math.Cos,
+	"tan":   math.Tan,
+	"exp":   math.Exp,
+	"log":   math.Log,
+	"abs":   math.Abs,
+	"sqrt":  math.Sqrt,
+	"sign":  func(a float64) float64 { return truth(a > 0) - truth(a < 0) },
+	"ceil":  math.Ceil,
+	"floor": math.Floor,
+}
+
+func truth(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// calc evaluates the expression after `{` up to the closing `}`.
+//
+// As in Octo, operators have no precedence and are applied from the right: `2 * 3 + 1` is 8.
+// Names are constants, labels defined above, HERE, PI and E.
+func (c *compiler) calc() (float64, *asm.Error) {
+	v, e := c.calcExpr()
+	if e != nil {
+		return 0, e
+	}
+	t, e := c.read()
+	if e != nil {
+		return 0, e
+	}
+	if t.text != "}" {
+		return 0, errorAt(t, "expected `}` but got `%s`", t.text)
+	}
+	return v, nil
+}
+
+func (c *compiler) calcExpr() (float64, *asm.Error) {
+	lhs, e := c.calcTerm()
+	if e != nil {
+		return 0, e
+	}
+	t, ok := c.peek()
+	if !ok || t.str {
+		return lhs, nil
+	}
+	op, ok := binaries[t.text]
+	if !ok {
+		return lhs, nil
+	}
+	c.pos++
+	rhs, e := c.calcExpr()
+	if e != nil {
+		return 0, e
+	}
+	return op(lhs, rhs), nil
+}
+
+func (c *compiler) calcTerm() (float64, *asm.Error) {
+	t, e := c.read()
+	if e != nil {
+		return 0, e
+	}
+	if t.str {
+		return 0, errorAt(t, "strings can't be calculated")
+	}
+	if t.text == "(" {
+		v, e := c.calcExpr()
+		if e != nil {
+			return 0, e
+		}
+		p, e := c.read()
+		if e != nil {
+			return 0, e
+		}
+		if p.text != ")" {
+			return 0, errorAt(p, "expected `)` but got `%s`", p.text)
+		}
+		return v, nil
+	}
+	if op, ok := unaries[t.text]; ok {
+		v, e := c.calcTerm()
+		if e != nil {
+			return 0, e
+		}
+		return op(v), nil
+	}
+	switch t.text {
+	case "@":
+		v, e := c.calcTerm()
+		if e != nil {
+			return 0, e
+		}
+		a := int(v)
+		if a < 0 || a >= memorySize {
+			return 0, errorAt(t, "@ %d is out of the memory", a)
+		}
+		return float64(c.rom[a]), nil
+	case "HERE":
+		return float64(c.here), nil
+	case "PI":
+		return math.Pi, nil
+	case "E":
+		return math.E, nil
+	}
+	if v, ok := number(t.text); ok {
+		return float64(v), nil
+	}
+	if v, ok := c.consts[t.text]; ok {
+		return v, nil
+	}
+	if v, ok := c.labels[t.text]; ok {
+		return float64(v), nil
+	}
+	return 0, errorAt(t, "undefined `%s` in :calc", t.text)
+}
@@ -0,0 +1,135 @@
+package octo
+
+import (
+	"github.com/masu-mi/gochip-8/asm"
+)
+
+// cond is a condition of if and while.
+type cond struct {
+	tok token
+	x   uint16
+	op  string
+	// y is a register when reg is true and a byte otherwise.
+	y   uint16
+	reg bool
+}
+
+// negations are the opposites of the operators.
+var negations = map[string]string{
+	"==":   "!=",
+	"!=":   "==",
+	"<":    ">=",
+	">=":   "<",
+	">":    "<=",
+	"<=":   ">",
+	"key":  "-key",
+	"-key": "key",
+}
+
+func (cd cond) negate() cond {
+	cd.op = negations[cd.op]
+	return cd
+}
+
+// condition reads `vx op y`, `vx key` or `vx -key`.
+func (c *compiler) condition() (cond, *asm.Error) {
+	x, e := c.readRegister()
+	if e != nil {
+		return cond{}, e
+	}
+	op, e := c.read()
+	if e != nil {
+		return cond{}, e
+	}
+	cd := cond{tok: op, x: uint16(x), op: op.text}
+	if _, ok := negations[op.text]; !ok || op.str {
+		return cd, errorAt(op, "unknown comparison `%s`", op.text)
+	}
+	if op.text == "key" || op.text == "-key" {
+		return cd, nil
+	}
+	t, e := c.read()
+	if e != nil {
+		return cd, e
+	}
+	if y, ok := c.register(t); ok {
+		cd.y, cd.reg = uint16(y), true
+		return cd, nil
+	}
+	c.pos--
+	cd.y, e = c.byteValue()
+	return cd, e
+}
+
+// skipUnless emits the instructions which skip the next one unless cd holds.
+//
+// Octo compares with <, >, <= and >= by subtracting in vf:
+// `vf := y ; vf =- vx` sets vf to 1 when vx >= y and `vf := y ; vf -= vx` sets vf to 1 when y >= vx.
+func (c *compiler) skipUnless(cd cond) *asm.Error {
+	vx, vy := cd.x<<8, cd.y<<4
+	switch cd.op {
+	case "==":
+		if cd.reg {
+			return c.inst(0x9000 | vx | vy)
+		}
+		return c.inst(0x4000 | vx | cd.y)
+	case "!=":
+		if cd.reg {
+			return c.inst(0x5000 | vx | vy)
+		}
+		return c.inst(0x3000 | vx | cd.y)
+	case "key":
+		return c.inst(0xe0a1 | vx)
+	case "-key":
+		return c.inst(0xe09e | vx)
+	}
+	if cd.x == 0xf || cd.reg && cd.y == 0xf {
+		return errorAt(cd.tok, "vf can't be compared by `%s` as it's used for the comparison", cd.op)
+	}
+	if cd.reg {
+		if e := c.inst(0x8f00 | vy); e != nil {
+			return e
+		}
+	} else if e := c.inst(0x6f00 | cd.y); e != nil {
+		return e
+	}
+	switch cd.op {
+	case "<", ">=":
+		if e := c.inst(0x8f07 | cd.x<<4); e != nil {
+			return e
+		}
+	default:
+		if e := c.inst(0x8f05 | cd.x<<4); e != nil {
+			return e
+		}
+	}
+	// vf is 0 for < and > and 1 for >= and <=.
+	if cd.op == "<" || cd.op == ">" {
+		return c.inst(0x4f00)
+	}
+	return c.inst(0x3f00)
+}
+
+// ifStatement compiles `if cond then` and `if cond begin`.
+func (c *compiler) ifStatement(t token) *asm.Error {
+	cd, e := c.condition()
+	if e != nil {
+		return e
+	}
+	k, e := c.read()
+	if e != nil {
+		return e
+	}
+	switch k.text {
+	case "then":
+		return c.skipUnless(cd)
+	case "begin":
+		// the jump to else or end is skipped when the condition holds.
+		if e := c.skipUnless(cd.negate()); e != nil {
+			return e
+		}
+		c.blocks = append(c.blocks, &block{tok: t, jumps: []int{c.here}})
+		return c.inst(0x1000)
+	}
+	return errorAt(k, "expected `then` or `begin` but got `%s`", k.text)
+}
@@ -0,0 +1,57 @@
+package octo
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/masu-mi/gochip-8/asm"
+)
+
+// token is a word of the source separated by whitespace. Line and Col are 1-based.
+type token struct {
+	text string
+	// str is true for strings, whose text is unquoted.
+	str       bool
+	line, col int
+}
+
+// tokenize splits src into words. Comments start with `#` and strings are quoted by `"`.
+func tokenize(src string) ([]token, *asm.Error) {
+	var toks []token
+	for n, line := range strings.Split(src, "\n") {
+		for i := 0; i < len(line); {
+			c := line[i]
+			switch {
+			case c == ' ' || c == '\t' || c == '\r':
+				i++
+			case c == '#':
+				i = len(line)
+			case c == '"':
+				j := i + 1
+				for j < len(line) && line[j] != '"' {
+					if line[j] == '\\' {
+						j++
+					}
+					j++
+				}
+				if j >= len(line) {
+					return nil, &asm.Error{Line: n + 1, Col: i + 1, Msg: "unterminated string"}
+				}
+				s, e := strconv.Unquote(line[i : j+1])
+				if e != nil {
+					return nil, &asm.Error{Line: n + 1, Col: i + 1, Msg: "invalid escape in " + line[i:j+1]}
+				}
+				toks = append(toks, token{text: s, str: true, line: n + 1, col: i + 1})
+				i = j + 1
+			default:
+				j := i
+				for j < len(line) && !strings.ContainsRune(" \t\r", rune(line[j])) {
+					j++
+				}
+				toks = append(toks, token{text: line[i:j], line: n + 1, col: i + 1})
+				i = j
+			}
+		}
+	}
+	return toks, nil
+}
@@ -0,0 +1,475 @@
+// Package octo compiles Octo, the language of the Octo IDE, into ROMs.
+//
+// > ref. https://github.com/JohnEarnest/Octo/blob/gh-pages/docs/Manual.md
+//
+// It supports the statements of CHIP-8, SUPER-CHIP and XO-CHIP, labels, `:next`, `:alias`, `:const`, `:calc`,
+// `:macro`, `:byte`, `:pointer`, `:call`, `:unpack`, `:org`, `:assert`, `loop`/`while`/`again` and
+// `if ... then` / `if ... begin ... else ... end`. `:breakpoint` and `:monitor` are accepted and ignored.
+//
+// The program starts with a jump to `main` unless `: main` comes first.
+package octo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/masu-mi/gochip-8/asm"
+	"github.com/masu-mi/gochip-8/core"
+	"github.com/masu-mi/gochip-8/symbols"
+)
+
+// memorySize is the size of XO-CHIP's memory, the largest one.
+const memorySize = 0x10000
+
+// maxExpansion limits the tokens expanded by macros to stop recursive macros.
+const maxExpansion = 1 << 20
+
+// Compile compiles src of file into a ROM loaded at core.StartOfProgram.
+// The error is an asm.ErrorList of the first error in the source.
+func Compile(file string, src []byte) (*asm.Program, error) {
+	toks, e := tokenize(string(src))
+	if e == nil {
+		c := &compiler{
+			file:    file,
+			toks:    toks,
+			rom:     make([]byte, memorySize),
+			owner:   make([]bool, memorySize),
+			here:    core.StartOfProgram + 2,
+			end:     core.StartOfProgram + 2,
+			labels:  map[string]int{},
+			consts:  map[string]float64{},
+			aliases: map[string]uint8{},
+			macros:  map[string]*macro{},
+			fixups:  map[string][]fixup{},
+			table:   symbols.New(),
+			// the jump to main
+			elidable: true,
+		}
+		c.owner[core.StartOfProgram], c.owner[core.StartOfProgram+1] = true, true
+		if e = c.compile(); e == nil {
+			for name, addr := range c.labels {
+				c.table.Labels[name] = uint16(addr)
+			}
+			return &asm.Program{
+				Base:    core.StartOfProgram,
+				ROM:     c.rom[core.StartOfProgram:c.end],
+				Symbols: c.table,
+			}, nil
+		}
+	}
+	e.File = file
+	if lines := strings.Split(string(src), "\n"); e.Line-1 < len(lines) {
+		e.Text = strings.TrimRight(lines[e.Line-1], "\r")
+	}
+	return nil, asm.ErrorList{e}
+}
+
+func errorAt(t token, format string, args ...interface{}) *asm.Error {
+	return &asm.Error{Line: t.line, Col: t.col, Msg: fmt.Sprintf(format, args...)}
+}
+
+type compiler struct {
+	file string
+	toks []token
+	pos  int
+	// last is the last token read, where errors at the end are reported.
+	last token
+
+	rom   []byte
+	owner []bool
+	here  int
+	// end is the address after the last byte.
+	end int
+
+	labels  map[string]int
+	consts  map[string]float64
+	aliases map[string]uint8
+	macros  map[string]*macro
+	// fixups are the references to labels not defined yet.
+	fixups map[string][]fixup
+	// blocks are the open if/else and loop blocks.
+	blocks []*block
+	// next is the label of `:next` waiting for the next instruction.
+	next *token
+
+	// stmt is the first token of the current statement and lined tells that its line is in table.
+	stmt   token
+	lined  bool
+	table  *symbols.Table
+	calls  int
+	expand int
+	// elidable is true until something is placed before main. elided tells that the jump to main is removed.
+	elidable bool
+	elided   bool
+}
+
+type macro struct {
+	args []string
+	body []token
+}
+
+type fixupKind int
+
+const (
+	// fixupNNN fills nnn of the instruction.
+	fixupNNN fixupKind = iota
+	// fixupWord fills the 16 bits.
+	fixupWord
+	// fixupNibble fills the low nibble of the byte with the bits 8-11 for :unpack.
+	fixupNibble
+	// fixupHigh and fixupLow fill the byte with the high or the low byte of the address.
+	fixupHigh
+	fixupLow
+)
+
+type fixup struct {
+	kind fixupKind
+	addr int
+	tok  token
+}
+
+type block struct {
+	tok token
+	// loop is true for loop ... again, otherwise if ... else ... end.
+	loop bool
+	// start is the address of loop.
+	start int
+	// jumps are the jumps to the end of the block. Their nnn are filled at end or again.
+	jumps []int
+}
+
+// keywords can't be names of labels and constants.
+var keywords = map[string]bool{}
+
+func init() {
+	for _, k := range strings.Fields(`
+		: :next :alias :const :calc :macro :byte :pointer :call :unpack :org :assert :breakpoint :monitor :stringmode
+		; return clear hires lores exit scroll-down scroll-up scroll-left scroll-right audio bcd save load saveflags
+		loadflags sprite jump jump0 native plane delay buzzer pitch i if then begin else end loop again while
+		key -key random hex bighex long := += -= =- |= &= ^= >>= <<= == != < > <= >= - { } HERE PI E`) {
+		keywords[k] = true
+	}
+}
+
+func (c *compiler) peek() (token, bool) {
+	if c.pos < len(c.toks) {
+		return c.toks[c.pos], true
+	}
+	return token{}, false
+}
+
+func (c *compiler) read() (token, *asm.Error) {
+	t, ok := c.peek()
+	if !ok {
+		return t, errorAt(c.last, "unexpected end of source after `%s`", c.last.text)
+	}
+	c.pos++
+	c.last = t
+	return t, nil
+}
+
+// expect reads the token of text.
+func (c *compiler) expect(text string) *asm.Error {
+	t, e := c.read()
+	if e != nil {
+		return e
+	}
+	if t.str || t.text != text {
+		return errorAt(t, "expected `%s` but got `%s`", text, t.text)
+	}
+	return nil
+}
+
+func (c *compiler) compile() *asm.Error {
+	for c.pos < len(c.toks) {
+		t, _ := c.read()
+		c.stmt, c.lined = t, false
+		if e := c.statement(t); e != nil {
+			return e
+		}
+	}
+	if len(c.blocks) > 0 {
+		b := c.blocks[len(c.blocks)-1]
+		if b.loop {
+			return errorAt(b.tok, "`loop` without `again`")
+		}
+		return errorAt(b.tok, "`if ... begin` without `end`")
+	}
+	if c.next != nil {
+		return errorAt(*c.next, "`:next` without an instruction")
+	}
+	for name, fs := range c.fixups {
+		return errorAt(fs[0].tok, "undefined `%s`", name)
+	}
+	main, ok := c.labels["main"]
+	if !ok {
+		return &asm.Error{Line: 1, Col: 1, Msg: "no `main` label"}
+	}
+	if !c.elided {
+		if main > 0xfff {
+			return &asm.Error{Line: 1, Col: 1, Msg: fmt.Sprintf("main 0x%X is out of the reach of the jump", main)}
+		}
+		c.rom[core.StartOfProgram] = byte(0x10 | main>>8)
+		c.rom[core.StartOfProgram+1] = byte(main)
+	}
+	return nil
+}
+
+// emit places the bytes of a statement at here.
+func (c *compiler) emit(bs ...byte) *asm.Error {
+	if c.next != nil {
+		n := *c.next
+		c.next = nil
+		if e := c.define(n, c.here+1); e != nil {
+			return e
+		}
+	}
+	if !c.lined {
+		c.table.AddLine(uint16(c.here), c.file, c.stmt.line)
+		c.lined = true
+	}
+	c.elidable = false
+	for _, b := range bs {
+		if c.here >= memorySize {
+			return errorAt(c.stmt, "the program exceeds the memory")
+		}
+		if c.owner[c.here] {
+			return errorAt(c.stmt, "0x%03X is already used", c.here)
+		}
+		c.owner[c.here] = true
+		c.rom[c.here] = b
+		c.here++
+	}
+	if c.here > c.end {
+		c.end = c.here
+	}
+	return nil
+}
+
+func (c *compiler) inst(op uint16) *asm.Error {
+	return c.emit(byte(op>>8), byte(op))
+}
+
+// define defines the label at addr and fills the references to it.
+func (c *compiler) define(t token, addr int) *asm.Error {
+	if e := c.checkName(t); e != nil {
+		return e
+	}
+	c.labels[t.text] = addr
+	for _, f := range c.fixups[t.text] {
+		if e := c.fill(f, addr); e != nil {
+			return e
+		}
+	}
+	delete(c.fixups, t.text)
+	return nil
+}
+
+// checkName checks t can be the name of a new label or constant.
+func (c *compiler) checkName(t token) *asm.Error {
+	if t.str || keywords[t.text] {
+		return errorAt(t, "`%s` can't be a name", t.text)
+	}
+	if _, ok := c.register(t); ok {
+		return errorAt(t, "register `%s` can't be a name", t.text)
+	}
+	if _, ok := number(t.text); ok {
+		return errorAt(t, "number `%s` can't be a name", t.text)
+	}
+	_, label := c.labels[t.text]
+	_, constant := c.consts[t.text]
+	_, m := c.macros[t.text]
+	if label || constant || m {
+		return errorAt(t, "`%s` is already defined", t.text)
+	}
+	return nil
+}
+
+func (c *compiler) fill(f fixup, v int) *asm.Error {
+	switch f.kind {
+	case fixupNNN, fixupNibble:
+		if v > 0xfff {
+			return errorAt(f.tok, "`%s` at 0x%X is out of the reach of 12 bits", f.tok.text, v)
+		}
+	}
+	switch f.kind {
+	case fixupNNN:
+		c.rom[f.addr] |= byte(v >> 8 & 0xf)
+		c.rom[f.addr+1] = byte(v)
+	case fixupWord:
+		c.rom[f.addr], c.rom[f.addr+1] = byte(v>>8), byte(v)
+	case fixupNibble:
+		c.rom[f.addr] |= byte(v >> 8 & 0xf)
+	case fixupHigh:
+		c.rom[f.addr] = byte(v >> 8)
+	case fixupLow:
+		c.rom[f.addr] = byte(v)
+	}
+	return nil
+}
+
+// number parses decimal, 0x and 0b numbers of Octo.
+func number(s string) (int, bool) {
+	neg := strings.HasPrefix(s, "-")
+	digits := strings.TrimPrefix(s, "-")
+	base := 10
+	switch {
+	case strings.HasPrefix(digits, "0x") || strings.HasPrefix(digits, "0X"):
+		base, digits = 16, digits[2:]
+	case strings.HasPrefix(digits, "0b") || strings.HasPrefix(digits, "0B"):
+		base, digits = 2, digits[2:]
+	}
+	if digits == "" || digits[0] == '+' || digits[0] == '-' {
+		return 0, false
+	}
+	v, e := strconv.ParseInt(digits, base, 64)
+	if e != nil {
+		return 0, false
+	}
+	if neg {
+		v = -v
+	}
+	return int(v), true
+}
+
+// register returns the number of Vx or its alias.
+func (c *compiler) register(t token) (uint8, bool) {
+	if t.str {
+		return 0, false
+	}
+	if r, ok := c.aliases[t.text]; ok {
+		return r, true
+	}
+	if len(t.text) != 2 || t.text[0] != 'v' && t.text[0] != 'V' {
+		return 0, false
+	}
+	i := strings.IndexByte("0123456789abcdef", strings.ToLower(t.text)[1])
+	if i < 0 {
+		return 0, false
+	}
+	return uint8(i), true
+}
+
+func (c *compiler) readRegister() (uint8, *asm.Error) {
+	t, e := c.read()
+	if e != nil {
+		return 0, e
+	}
+	r, ok := c.register(t)
+	if !ok {
+		return 0, errorAt(t, "expected a register but got `%s`", t.text)
+	}
+	return r, nil
+}
+
+// constant reads a number, a constant, a label defined above or `{ calc }`.
+func (c *compiler) constant() (float64, token, *asm.Error) {
+	t, e := c.read()
+	if e != nil {
+		return 0, t, e
+	}
+	v, ok, e := c.valueOf(t)
+	if e != nil {
+		return 0, t, e
+	}
+	if !ok {
+		return 0, t, errorAt(t, "undefined `%s`", t.text)
+	}
+	return v, t, nil
+}
+
+// valueOf returns the value of t, which is false for the names not defined yet.
+func (c *compiler) valueOf(t token) (float64, bool, *asm.Error) {
+	if t.str {
+		return 0, false, errorAt(t, "unexpected string")
+	}
+	if t.text == "{" {
+		v, e := c.calc()
+		return v, e == nil, e
+	}
+	if v, ok := number(t.text); ok {
+		return float64(v), true, nil
+	}
+	if v, ok := c.consts[t.text]; ok {
+		return v, true, nil
+	}
+	if v, ok := c.labels[t.text]; ok {
+		return float64(v), true, nil
+	}
+	return 0, false, nil
+}
+
+// integer reads a constant as an integer in [min, max].
+func (c *compiler) integer(min, max int, what string) (int, *asm.Error) {
+	f, t, e := c.constant()
+	if e != nil {
+		return 0, e
+	}
+	v := int(f)
+	if f < 0 && float64(v) != f {
+		// floor like Octo
+		v--
+	}
+	if v < min || v > max {
+		return 0, errorAt(t, "%d doesn't fit in %s", v, what)
+	}
+	return v, nil
+}
+
+func (c *compiler) byteValue() (uint16, *asm.Error) {
+	v, e := c.integer(-0x80, 0xff, "a byte")
+	return uint16(v) & 0xff, e
+}
+
+func (c *compiler) nibble() (uint16, *asm.Error) {
+	v, e := c.integer(0, 0xf, "a nibble")
+	return uint16(v), e
+}
+
+// address emits op with nnn of an address, which may be a label defined below.
+func (c *compiler) address(op uint16) *asm.Error {
+	t, e := c.read()
+	if e != nil {
+		return e
+	}
+	return c.addressOf(t, op)
+}
+
+func (c *compiler) addressOf(t token, op uint16) *asm.Error {
+	v, ok, e := c.valueOf(t)
+	if e != nil {
+		return e
+	}
+	if !ok {
+		if e := c.checkName(t); e != nil {
+			return e
+		}
+		c.fixups[t.text] = append(c.fixups[t.text], fixup{kind: fixupNNN, addr: c.here, tok: t})
+		return c.inst(op)
+	}
+	if v < 0 || v > 0xfff {
+		return errorAt(t, "0x%X is out of the reach of 12 bits", int(v))
+	}
+	return c.inst(op | uint16(v))
+}
+
+// reference returns the address of the label or the constant, adding fixups at the offsets of here if it's not defined yet.
+func (c *compiler) reference(t token, fs ...fixup) (int, *asm.Error) {
+	v, ok, e := c.valueOf(t)
+	if e != nil {
+		return 0, e
+	}
+	if ok {
+		return int(v), nil
+	}
+	if e := c.checkName(t); e != nil {
+		return 0, e
+	}
+	for _, f := range fs {
+		f.addr += c.here
+		f.tok = t
+		c.fixups[t.text] = append(c.fixups[t.text], f)
+	}
+	return 0, nil
+}
@@ -0,0 +1,104 @@
+package octo
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestStatements tests a statement of each instruction, in the order of the forms of the asm package.
+func TestStatements(t *testing.T) {
+	for _, tc := range []struct {
+		src  string
+		want []byte
+	}{
+		{"clear", []byte{0x00, 0xe0}},
+		{"return", []byte{0x00, 0xee}},
+		{"native 0x123", []byte{0x01, 0x23}},
+		{"jump 0x345", []byte{0x13, 0x45}},
+		{"jump0 0x345", []byte{0xb3, 0x45}},
+		{":call 0x345", []byte{0x23, 0x45}},
+		// Octo's if runs the statement when the condition holds, so it's the negation of the skip.
+		{"if v1 != 0x22 then clear", []byte{0x31, 0x22, 0x00, 0xe0}},
+		{"if v1 != v2 then clear", []byte{0x51, 0x20, 0x00, 0xe0}},
+		{"if v1 == 0x22 then clear", []byte{0x41, 0x22, 0x00, 0xe0}},
+		{"if v1 == v2 then clear", []byte{0x91, 0x20, 0x00, 0xe0}},
+		{"v1 := 0x22", []byte{0x61, 0x22}},
+		{"v1 := v2", []byte{0x81, 0x20}},
+		{"i := 0x345", []byte{0xa3, 0x45}},
+		{"i := long 0x1234", []byte{0xf0, 0x00, 0x12, 0x34}},
+		{"v1 := delay", []byte{0xf1, 0x07}},
+		{"v1 := key", []byte{0xf1, 0x0a}},
+		{"delay := v1", []byte{0xf1, 0x15}},
+		{"buzzer := v1", []byte{0xf1, 0x18}},
+		{"i := hex v1", []byte{0xf1, 0x29}},
+		{"i := bighex v1", []byte{0xf1, 0x30}},
+		{"bcd v1", []byte{0xf1, 0x33}},
+		{"save v1", []byte{0xf1, 0x55}},
+		{"load v1", []byte{0xf1, 0x65}},
+		{"saveflags v1", []byte{0xf1, 0x75}},
+		{"loadflags v1", []byte{0xf1, 0x85}},
+		{"v1 += 0x22", []byte{0x71, 0x22}},
+		{"v1 += v2", []byte{0x81, 0x24}},
+		{"i += v1", []byte{0xf1, 0x1e}},
+		{"v1 |= v2", []byte{0x81, 0x21}},
+		{"v1 &= v2", []byte{0x81, 0x22}},
+		{"v1 ^= v2", []byte{0x81, 0x23}},
+		{"v1 -= v2", []byte{0x81, 0x25}},
+		{"v1 >>= v1", []byte{0x81, 0x16}},
+		{"v1 >>= v2", []byte{0x81, 0x26}},
+		{"v1 =- v2", []byte{0x81, 0x27}},
+		{"v1 <<= v1", []byte{0x81, 0x1e}},
+		{"v1 <<= v2", []byte{0x81, 0x2e}},
+		{"v1 := random 0x22", []byte{0xc1, 0x22}},
+		{"sprite v1 v2 5", []byte{0xd1, 0x25}},
+		{"if v1 -key then clear", []byte{0xe1, 0x9e, 0x00, 0xe0}},
+		{"if v1 key then clear", []byte{0xe1, 0xa1, 0x00, 0xe0}},
+		{"scroll-down 4", []byte{0x00, 0xc4}},
+		{"scroll-right", []byte{0x00, 0xfb}},
+		{"scroll-left", []byte{0x00, 0xfc}},
+		{"exit", []byte{0x00, 0xfd}},
+		{"lores", []byte{0x00, 0xfe}},
+		{"hires", []byte{0x00, 0xff}},
+		{"scroll-up 4", []byte{0x00, 0xd4}},
+		{"save v1 - v3", []byte{0x51, 0x32}},
+		{"load v1 - v3", []byte{0x51, 0x33}},
+		{"plane 3", []byte{0xf3, 0x01}},
+		{"audio", []byte{0xf0, 0x02}},
+		{"pitch := v1", []byte{0xf1, 0x3a}},
+	} {
+		p, e := Compile("test.8o", []byte(": main\n\t"+tc.src+"\n"))
+		if e != nil {
+			t.Errorf("%s: %v", tc.src, e)
+			continue
+		}
+		if !bytes.Equal(p.ROM, tc.want) {
+			t.Errorf("%s = % x; want % x", tc.src, p.ROM, tc.want)
+		}
+	}
+}
+
+func TestConstructs(t *testing.T) {
+	for _, tc := range []struct {
+		src  string
+		want []byte
+	}{
+		{": main\n\tloop\n\t\tv0 += 1\n\tagain", []byte{0x70, 0x01, 0x12, 0x00}},
+		{": main\n\tloop\n\t\twhile v0 != 3\n\t\tv0 += 1\n\tagain", []byte{0x40, 0x03, 0x12, 0x08, 0x70, 0x01, 0x12, 0x00}},
+		{": main\n\tif v0 == 1 begin\n\t\tclear\n\telse\n\t\texit\n\tend", []byte{0x30, 0x01, 0x12, 0x08, 0x00, 0xe0, 0x12, 0x0a, 0x00, 0xfd}},
+		{":const N 3\n: main\n\tv0 := N", []byte{0x60, 0x03}},
+		// :calc applies the operators from the right.
+		{":calc N { 2 * 3 + 1 }\n: main\n\tv0 := N", []byte{0x60, 0x08}},
+		{": main\n\tsub\n: sub\n\treturn", []byte{0x22, 0x02, 0x00, 0xee}},
+		{": sub\n\treturn\n: main\n\tsub", []byte{0x12, 0x04, 0x00, 0xee, 0x22, 0x02}},
+		{": main\n\ti := data\n: data\n\t0x12 0x34", []byte{0xa2, 0x02, 0x12, 0x34}},
+	} {
+		p, e := Compile("test.8o", []byte(tc.src+"\n"))
+		if e != nil {
+			t.Errorf("%q: %v", tc.src, e)
+			continue
+		}
+		if !bytes.Equal(p.ROM, tc.want) {
+			t.Errorf("%q = % x; want % x", tc.src, p.ROM, tc.want)
+		}
+	}
+}
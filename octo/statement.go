@@ -0,0 +1,571 @@
+package octo
+
+import (
+	"strconv"
+
+	"github.com/masu-mi/gochip-8/asm"
+	"github.com/masu-mi/gochip-8/core"
+)
+
+// simple are the statements without operands.
+var simple = map[string]uint16{
+	";":            0x00ee,
+	"return":       0x00ee,
+	"clear":        0x00e0,
+	"scroll-right": 0x00fb,
+	"scroll-left":  0x00fc,
+	"exit":         0x00fd,
+	"lores":        0x00fe,
+	"hires":        0x00ff,
+	"audio":        0xf002,
+}
+
+// unary are the statements of a register.
+var unary = map[string]uint16{
+	"bcd":       0xf033,
+	"save":      0xf055,
+	"load":      0xf065,
+	"saveflags": 0xf075,
+	"loadflags": 0xf085,
+}
+
+// timers are the statements of `name := vx`.
+var timers = map[string]uint16{
+	"delay":  0xf015,
+	"buzzer": 0xf018,
+	"pitch":  0xf03a,
+}
+
+// alu are the operators of `vx op vy`.
+var alu = map[string]uint16{
+	":=":  0x8000,
+	"|=":  0x8001,
+	"&=":  0x8002,
+	"^=":  0x8003,
+	"+=":  0x8004,
+	"-=":  0x8005,
+	">>=": 0x8006,
+	"=-":  0x8007,
+	"<<=": 0x800e,
+}
+
+func (c *compiler) statement(t token) *asm.Error {
+	if t.str {
+		return errorAt(t, "unexpected string")
+	}
+	if op, ok := simple[t.text]; ok {
+		return c.inst(op)
+	}
+	if op, ok := unary[t.text]; ok {
+		x, e := c.readRegister()
+		if e != nil {
+			return e
+		}
+		// save vx - vy and load vx - vy of XO-CHIP
+		if n, ok := c.peek(); ok && n.text == "-" && (t.text == "save" || t.text == "load") {
+			c.pos++
+			y, e := c.readRegister()
+			if e != nil {
+				return e
+			}
+			op = 0x5002
+			if t.text == "load" {
+				op = 0x5003
+			}
+			return c.inst(op | uint16(x)<<8 | uint16(y)<<4)
+		}
+		return c.inst(op | uint16(x)<<8)
+	}
+	if op, ok := timers[t.text]; ok {
+		if e := c.expect(":="); e != nil {
+			return e
+		}
+		x, e := c.readRegister()
+		if e != nil {
+			return e
+		}
+		return c.inst(op | uint16(x)<<8)
+	}
+	if x, ok := c.register(t); ok {
+		return c.registerStatement(uint16(x))
+	}
+	switch t.text {
+	case ":":
+		n, e := c.read()
+		if e != nil {
+			return e
+		}
+		return c.label(n)
+	case ":next":
+		n, e := c.read()
+		if e != nil {
+			return e
+		}
+		if e := c.checkName(n); e != nil {
+			return e
+		}
+		c.next = &n
+		return nil
+	case ":alias":
+		n, e := c.read()
+		if e != nil {
+			return e
+		}
+		if _, ok := c.aliases[n.text]; !ok {
+			if e := c.checkName(n); e != nil {
+				return e
+			}
+		}
+		r, e := c.readRegister()
+		if e != nil {
+			return e
+		}
+		c.aliases[n.text] = r
+		return nil
+	case ":const", ":calc":
+		n, e := c.read()
+		if e != nil {
+			return e
+		}
+		if e := c.checkName(n); e != nil {
+			return e
+		}
+		if t.text == ":calc" {
+			if e := c.expect("{"); e != nil {
+				return e
+			}
+			v, e := c.calc()
+			if e != nil {
+				return e
+			}
+			c.consts[n.text] = v
+			return nil
+		}
+		v, _, e := c.constant()
+		if e != nil {
+			return e
+		}
+		c.consts[n.text] = v
+		return nil
+	case ":macro":
+		return c.defineMacro()
+	case ":byte":
+		v, e := c.byteValue()
+		if e != nil {
+			return e
+		}
+		return c.emit(byte(v))
+	case ":pointer":
+		n, e := c.read()
+		if e != nil {
+			return e
+		}
+		v, e := c.reference(n, fixup{kind: fixupWord})
+		if e != nil {
+			return e
+		}
+		if v < 0 || v > 0xffff {
+			return errorAt(n, "0x%X doesn't fit in 16 bits", v)
+		}
+		return c.emit(byte(v>>8), byte(v))
+	case ":call":
+		return c.address(0x2000)
+	case ":unpack":
+		return c.unpack()
+	case ":org":
+		v, e := c.integer(core.StartOfProgram, memorySize-1, "the memory after 0x200")
+		if e != nil {
+			return e
+		}
+		c.here, c.elidable = v, false
+		return nil
+	case ":assert":
+		n, e := c.read()
+		if e != nil {
+			return e
+		}
+		msg := "assertion failed"
+		if n.str {
+			msg = n.text
+			if n, e = c.read(); e != nil {
+				return e
+			}
+		}
+		if n.text != "{" {
+			return errorAt(n, "expected `{` but got `%s`", n.text)
+		}
+		v, e := c.calc()
+		if e != nil {
+			return e
+		}
+		if v == 0 {
+			return errorAt(t, "%s", msg)
+		}
+		return nil
+	case ":breakpoint":
+		_, e := c.read()
+		return e
+	case ":monitor":
+		for i := 0; i < 2; i++ {
+			if _, e := c.read(); e != nil {
+				return e
+			}
+		}
+		return nil
+	case ":stringmode":
+		return errorAt(t, "`:stringmode` isn't supported")
+	case "scroll-down", "scroll-up":
+		n, e := c.nibble()
+		if e != nil {
+			return e
+		}
+		op := uint16(0x00c0)
+		if t.text == "scroll-up" {
+			op = 0x00d0
+		}
+		return c.inst(op | n)
+	case "plane":
+		n, e := c.nibble()
+		if e != nil {
+			return e
+		}
+		return c.inst(0xf001 | n<<8)
+	case "sprite":
+		x, e := c.readRegister()
+		if e != nil {
+			return e
+		}
+		y, e := c.readRegister()
+		if e != nil {
+			return e
+		}
+		n, e := c.nibble()
+		if e != nil {
+			return e
+		}
+		return c.inst(0xd000 | uint16(x)<<8 | uint16(y)<<4 | n)
+	case "jump":
+		return c.address(0x1000)
+	case "jump0":
+		return c.address(0xb000)
+	case "native":
+		return c.address(0x0000)
+	case "i":
+		return c.indexStatement()
+	case "if":
+		return c.ifStatement(t)
+	case "else":
+		b := c.top()
+		if b == nil || b.loop || b.tok.text == "else" {
+			return errorAt(t, "`else` without `if ... begin`")
+		}
+		jump := c.here
+		if e := c.inst(0x1000); e != nil {
+			return e
+		}
+		if e := c.close(b, c.here); e != nil {
+			return e
+		}
+		b.tok, b.jumps = t, []int{jump}
+		return nil
+	case "end":
+		b := c.top()
+		if b == nil || b.loop {
+			return errorAt(t, "`end` without `if ... begin`")
+		}
+		c.blocks = c.blocks[:len(c.blocks)-1]
+		return c.close(b, c.here)
+	case "loop":
+		c.elidable = false
+		c.blocks = append(c.blocks, &block{tok: t, loop: true, start: c.here})
+		return nil
+	case "while":
+		var b *block
+		for i := len(c.blocks) - 1; i >= 0; i-- {
+			if c.blocks[i].loop {
+				b = c.blocks[i]
+				break
+			}
+		}
+		if b == nil {
+			return errorAt(t, "`while` without `loop`")
+		}
+		cond, e := c.condition()
+		if e != nil {
+			return e
+		}
+		// the jump out of the loop is skipped while the condition holds.
+		if e := c.skipUnless(cond.negate()); e != nil {
+			return e
+		}
+		b.jumps = append(b.jumps, c.here)
+		return c.inst(0x1000)
+	case "again":
+		b := c.top()
+		if b == nil || !b.loop {
+			return errorAt(t, "`again` without `loop`")
+		}
+		c.blocks = c.blocks[:len(c.blocks)-1]
+		if b.start > 0xfff {
+			return errorAt(b.tok, "`loop` at 0x%X is out of the reach of the jump", b.start)
+		}
+		if e := c.inst(0x1000 | uint16(b.start)); e != nil {
+			return e
+		}
+		return c.close(b, c.here)
+	case "then", "begin", ":=", "+=", "-=", "=-", "|=", "&=", "^=", ">>=", "<<=", "{", "}":
+		return errorAt(t, "unexpected `%s`", t.text)
+	}
+	if m, ok := c.macros[t.text]; ok {
+		return c.expandMacro(t, m)
+	}
+	// numbers are bytes of data.
+	if v, ok := number(t.text); ok {
+		if v < -0x80 || v > 0xff {
+			return errorAt(t, "%s doesn't fit in a byte", t.text)
+		}
+		return c.emit(byte(v))
+	}
+	// names call the subroutines.
+	return c.addressOf(t, 0x2000)
+}
+
+func (c *compiler) top() *block {
+	if len(c.blocks) == 0 {
+		return nil
+	}
+	return c.blocks[len(c.blocks)-1]
+}
+
+// close fills the jumps of b with addr.
+func (c *compiler) close(b *block, addr int) *asm.Error {
+	if addr > 0xfff {
+		return errorAt(b.tok, "0x%X is out of the reach of the jump", addr)
+	}
+	for _, j := range b.jumps {
+		c.rom[j] |= byte(addr >> 8)
+		c.rom[j+1] = byte(addr)
+	}
+	return nil
+}
+
+func (c *compiler) label(n token) *asm.Error {
+	addr := c.here
+	if n.text == "main" && c.elidable {
+		// main comes first and needs no jump.
+		c.elided = true
+		c.owner[core.StartOfProgram], c.owner[core.StartOfProgram+1] = false, false
+		c.here, c.end, addr = core.StartOfProgram, core.StartOfProgram, core.StartOfProgram
+	}
+	c.elidable = false
+	return c.define(n, addr)
+}
+
+// registerStatement compiles `vx op ...`.
+func (c *compiler) registerStatement(x uint16) *asm.Error {
+	op, e := c.read()
+	if e != nil {
+		return e
+	}
+	vx := x << 8
+	rhs, e := c.read()
+	if e != nil {
+		return e
+	}
+	if y, ok := c.register(rhs); ok {
+		code, ok := alu[op.text]
+		if !ok {
+			return errorAt(op, "unknown operator `%s` for registers", op.text)
+		}
+		return c.inst(code | vx | uint16(y)<<4)
+	}
+	switch op.text {
+	case ":=":
+		switch rhs.text {
+		case "key":
+			return c.inst(0xf00a | vx)
+		case "delay":
+			return c.inst(0xf007 | vx)
+		case "random":
+			kk, e := c.byteValue()
+			if e != nil {
+				return e
+			}
+			return c.inst(0xc000 | vx | kk)
+		}
+		c.pos--
+		kk, e := c.byteValue()
+		if e != nil {
+			return e
+		}
+		return c.inst(0x6000 | vx | kk)
+	case "+=", "-=":
+		c.pos--
+		kk, e := c.byteValue()
+		if e != nil {
+			return e
+		}
+		if op.text == "-=" {
+			kk = -kk & 0xff
+		}
+		return c.inst(0x7000 | vx | kk)
+	}
+	return errorAt(op, "unknown operator `%s` for a register and a number", op.text)
+}
+
+// indexStatement compiles `i := ...` and `i += vx`.
+func (c *compiler) indexStatement() *asm.Error {
+	op, e := c.read()
+	if e != nil {
+		return e
+	}
+	switch op.text {
+	case "+=":
+		x, e := c.readRegister()
+		if e != nil {
+			return e
+		}
+		return c.inst(0xf01e | uint16(x)<<8)
+	case ":=":
+	default:
+		return errorAt(op, "unknown operator `%s` for i", op.text)
+	}
+	t, e := c.read()
+	if e != nil {
+		return e
+	}
+	switch t.text {
+	case "hex", "bighex":
+		x, e := c.readRegister()
+		if e != nil {
+			return e
+		}
+		if t.text == "hex" {
+			return c.inst(0xf029 | uint16(x)<<8)
+		}
+		return c.inst(0xf030 | uint16(x)<<8)
+	case "long":
+		n, e := c.read()
+		if e != nil {
+			return e
+		}
+		v, e := c.reference(n, fixup{kind: fixupWord, addr: 2})
+		if e != nil {
+			return e
+		}
+		if v < 0 || v > 0xffff {
+			return errorAt(n, "0x%X doesn't fit in 16 bits", v)
+		}
+		return c.emit(0xf0, 0x00, byte(v>>8), byte(v))
+	}
+	return c.addressOf(t, 0xa000)
+}
+
+// unpack compiles `:unpack n name` and `:unpack long name` into v0 and v1.
+func (c *compiler) unpack() *asm.Error {
+	t, ok := c.peek()
+	long := ok && t.text == "long"
+	var hi uint16
+	if long {
+		c.pos++
+	} else {
+		n, e := c.nibble()
+		if e != nil {
+			return e
+		}
+		hi = n << 4
+	}
+	n, e := c.read()
+	if e != nil {
+		return e
+	}
+	var v int
+	if long {
+		v, e = c.reference(n, fixup{kind: fixupHigh, addr: 1}, fixup{kind: fixupLow, addr: 3})
+	} else {
+		v, e = c.reference(n, fixup{kind: fixupNibble, addr: 1}, fixup{kind: fixupLow, addr: 3})
+	}
+	if e != nil {
+		return e
+	}
+	if !long && v > 0xfff {
+		return errorAt(n, "0x%X is out of the reach of 12 bits", v)
+	}
+	if long {
+		hi = uint16(v>>8) & 0xff
+	} else {
+		hi |= uint16(v>>8) & 0xf
+	}
+	if e := c.inst(0x6000 | hi); e != nil {
+		return e
+	}
+	return c.inst(0x6100 | uint16(v)&0xff)
+}
+
+func (c *compiler) defineMacro() *asm.Error {
+	n, e := c.read()
+	if e != nil {
+		return e
+	}
+	if e := c.checkName(n); e != nil {
+		return e
+	}
+	m := &macro{}
+	for {
+		t, e := c.read()
+		if e != nil {
+			return e
+		}
+		if t.text == "{" && !t.str {
+			break
+		}
+		m.args = append(m.args, t.text)
+	}
+	for depth := 1; ; {
+		t, e := c.read()
+		if e != nil {
+			return e
+		}
+		if !t.str {
+			switch t.text {
+			case "{":
+				depth++
+			case "}":
+				depth--
+			}
+		}
+		if depth == 0 {
+			break
+		}
+		m.body = append(m.body, t)
+	}
+	c.macros[n.text] = m
+	return nil
+}
+
+// expandMacro replaces the invocation with the body where the arguments and CALLS are substituted.
+func (c *compiler) expandMacro(t token, m *macro) *asm.Error {
+	args := map[string]string{"CALLS": strconv.Itoa(c.calls)}
+	c.calls++
+	for _, a := range m.args {
+		v, e := c.read()
+		if e != nil {
+			return e
+		}
+		args[a] = v.text
+	}
+	c.expand += len(m.body)
+	if c.expand > maxExpansion {
+		return errorAt(t, "too many expansions of macros")
+	}
+	body := make([]token, len(m.body))
+	for i, b := range m.body {
+		if v, ok := args[b.text]; ok && !b.str {
+			b.text = v
+		}
+		body[i] = b
+	}
+	rest := append(body, c.toks[c.pos:]...)
+	c.toks = append(c.toks[:c.pos], rest...)
+	return nil
+}
@@ -0,0 +1,98 @@
+// Package symbols maps addresses of a ROM to labels and lines of its source.
+//
+// Tables are written by the assemblers next to the ROM and read by the debuggers.
+package symbols
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"sort"
+)
+
+// Table is the symbol table of a ROM.
+type Table struct {
+	// Labels are addresses by name.
+	Labels map[string]uint16 `json:"labels"`
+	// Lines are the source lines of instructions and data in the order of Addr.
+	Lines []Line `json:"lines"`
+}
+
+// Line is the source line emitting the bytes at Addr.
+type Line struct {
+	Addr uint16 `json:"addr"`
+	File string `json:"file"`
+	Line int    `json:"line"`
+}
+
+// PathFor returns the path of the table written next to rom.
+func PathFor(rom string) string {
+	return rom + ".sym"
+}
+
+func New() *Table {
+	return &Table{Labels: map[string]uint16{}}
+}
+
+// Read reads the table written by Write.
+func Read(r io.Reader) (*Table, error) {
+	t := New()
+	if e := json.NewDecoder(r).Decode(t); e != nil {
+		return nil, e
+	}
+	t.sort()
+	return t, nil
+}
+
+func (t *Table) Write(w io.Writer) error {
+	t.sort()
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(t)
+}
+
+// AddLine records that the source line emitted the bytes at addr.
+func (t *Table) AddLine(addr uint16, file string, line int) {
+	t.Lines = append(t.Lines, Line{Addr: addr, File: file, Line: line})
+}
+
+func (t *Table) sort() {
+	sort.SliceStable(t.Lines, func(i, j int) bool { return t.Lines[i].Addr < t.Lines[j].Addr })
+}
+
+// LineOf returns the source line of addr.
+func (t *Table) LineOf(addr uint16) (Line, bool) {
+	i := sort.Search(len(t.Lines), func(i int) bool { return t.Lines[i].Addr > addr })
+	if i == 0 || t.Lines[i-1].Addr != addr {
+		return Line{}, false
+	}
+	return t.Lines[i-1], true
+}
+
+// AddrOf returns the first address emitted by the line of file, or the following line with code.
+// Files are compared by their base names as the table is moved with the ROM.
+func (t *Table) AddrOf(file string, line int) (uint16, int, bool) {
+	best := Line{}
+	found := false
+	for _, l := range t.Lines {
+		if filepath.Base(l.File) != filepath.Base(file) || l.Line < line {
+			continue
+		}
+		if !found || l.Line < best.Line || l.Line == best.Line && l.Addr < best.Addr {
+			best, found = l, true
+		}
+	}
+	return best.Addr, best.Line, found
+}
+
+// LabelOf returns the name of the label at addr.
+func (t *Table) LabelOf(addr uint16) (string, bool) {
+	name, found := "", false
+	for n, a := range t.Labels {
+		// names are compared to be deterministic for aliases.
+		if a == addr && (!found || n < name) {
+			name, found = n, true
+		}
+	}
+	return name, found
+}